@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+	"github.com/spf13/cobra"
+)
+
+var (
+	degreeDaysDevice int
+	degreeDaysStart  string
+	degreeDaysEnd    string
+)
+
+// degreeDaysCmd represents the degree-days command
+var degreeDaysCmd = &cobra.Command{
+	Use:   "degree-days",
+	Short: "Print heating, cooling and growing degree days",
+	Long: `Print the heating, cooling and growing degree days of each station-local day kept in the store, from the high and
+low of its daily rollup, and their total. By default it prints the season to date.
+
+WEATHERSTATION_DEGREE_DAYS is a comma separated list of name=value settings over the default of
+unit=f,heating=65,cooling=65,growing=50,upper=86,method=average,season=01-01. unit is f or c, heating, cooling and
+growing are the base temperatures, upper is the temperature growing stops increasing at, method is how growing degree
+days are computed, average, capped or sine, and season is the month and day seasons start on.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		st, err := openStore()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if st == nil {
+			log.Fatal(errors.New("the store is disabled by WEATHERSTATION_STORE=false"))
+		}
+		defer st.Close()
+
+		rollups, err := newAggregator(st)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		config, err := degreeDayConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		calculator := degreedays.New(rollups, config)
+
+		device := degreeDaysDevice
+		if devices := st.Devices(); device == 0 && len(devices) > 0 {
+			device = devices[0]
+		}
+
+		now := time.Now().In(rollups.Location())
+		start, end := calculator.SeasonStart(now), time.Time{}
+		for _, d := range []struct {
+			value string
+			t     *time.Time
+		}{{degreeDaysStart, &start}, {degreeDaysEnd, &end}} {
+			if d.value == "" {
+				continue
+			}
+			if *d.t, err = time.ParseInLocation(time.DateOnly, d.value, rollups.Location()); err != nil {
+				log.Fatalf("invalid date %q: expected YYYY-MM-DD", d.value)
+			}
+		}
+
+		days, err := calculator.Days(ctx, device, start, end)
+		if err != nil {
+			log.Fatal(err)
+		}
+		total, err := calculator.Total(ctx, device, start, end)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("device %d, %s\n\n", device, config)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "date\thigh\tlow\thdd\tcdd\tgdd\t\n")
+		for _, d := range days {
+			fmt.Fprintf(w, "%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n", d.Date.Format(time.DateOnly), d.High, d.Low, d.Heating, d.Cooling, d.Growing)
+		}
+		fmt.Fprintf(w, "total of %d days\t\t\t%.1f\t%.1f\t%.1f\t\n", total.Days, total.Heating, total.Cooling, total.Growing)
+		w.Flush()
+	},
+}
+
+func init() {
+	degreeDaysCmd.Flags().IntVar(&degreeDaysDevice, "device", 0, "device to print, the first stored device by default")
+	degreeDaysCmd.Flags().StringVar(&degreeDaysStart, "start", "", "first day to print as YYYY-MM-DD, the start of the season by default")
+	degreeDaysCmd.Flags().StringVar(&degreeDaysEnd, "end", "", "day to stop before as YYYY-MM-DD, none by default")
+	rootCmd.AddCommand(degreeDaysCmd)
+}
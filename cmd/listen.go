@@ -2,14 +2,15 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kdwils/weatherstation/pkg/api"
-	"github.com/kdwils/weatherstation/pkg/connection"
 	"github.com/kdwils/weatherstation/pkg/tempest"
 	"github.com/spf13/cobra"
 )
@@ -20,33 +21,38 @@ var listenCmd = &cobra.Command{
 	Short: "example: listen on tempest events",
 	Long:  `example: listen on tempest events`,
 	Run: func(cmd *cobra.Command, args []string) {
-		scheme := getEnvOrDefault("WEATHERSTATION_TEMPEST_SCHEME", "wss")
-		host := getEnvOrDefault("WEATHERSTATION_TEMPEST_HOST", "")
-		path := getEnvOrDefault("WEATHERSTATION_TEMPEST_PATH", "")
-		token := getEnvOrDefault("WEATHERSTATION_TEMPEST_TOKEN", "")
 		device := getEnvIntOrDefault("WEATHERSTATION_TEMPEST_DEVICE_ID", 0)
 
 		ctx := context.Background()
-		conn, err := connection.NewConnection(ctx, scheme, host, path, token)
+		conn, err := newConnection(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		listener := tempest.NewEventListener(conn, tempest.ListenGroupStart, device)
+		opts, closeOptions, err := listenerOptions(ctx, api.NewClient())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer closeOptions()
+
+		opts = append(opts,
+			// errors of single messages and handlers are logged, only an error ending Listen is fatal
+			tempest.WithErrorHandler(func(ctx context.Context, err error) {
+				log.Printf("error handling message: %v", err)
+			}),
+			tempest.WithUnhandledHandler(func(ctx context.Context, b []byte) {
+				e, _ := tempest.EventFromContext(ctx)
+				log.Printf("received %s: %s", e, b)
+			}),
+		)
+		listener := tempest.NewEventListener(conn, tempest.ListenGroupStart, device, opts...)
 
 		listener.RegisterHandler(tempest.EventConnectionOpened, func(ctx context.Context, b []byte) {
 			log.Printf("connection opened: %s", b)
 		})
 
-		listener.RegisterHandler(tempest.EventObservationTempest, func(ctx context.Context, b []byte) {
-			var obs api.ObservationTempest
-			err = json.Unmarshal(b, &obs)
-			if err != nil {
-				log.Fatal(err)
-				return
-			}
-
-			log.Printf("received observation: %+v", obs)
+		tempest.On(listener, func(ctx context.Context, obs api.ObservationTempest) {
+			log.Printf("received observation from device %d: %+v", obs.Device, obs)
 		})
 
 		go func(ctx context.Context, device int) {
@@ -84,6 +90,38 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	return value
 }
 
+// getEnvIntsOrDefault parses a comma separated list of integers, returning an error naming the variable and the first invalid value
+func getEnvIntsOrDefault(key string, defaultValue []int) ([]int, error) {
+	strValue := os.Getenv(key)
+	if strValue == "" {
+		return defaultValue, nil
+	}
+
+	values := make([]int, 0)
+	for _, v := range strings.Split(strValue, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q is not an integer", key, strings.TrimSpace(v))
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// getEnvDurationOrDefault parses a duration such as "30s", returning an error naming the variable when it is invalid
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	strValue := os.Getenv(key)
+	if strValue == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(strValue)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q is not a duration", key, strValue)
+	}
+	return value, nil
+}
+
 func init() {
 	rootCmd.AddCommand(listenCmd)
 }
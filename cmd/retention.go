@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var retentionDryRun bool
+
+// retentionCmd represents the retention command
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Delete stored history past its retention",
+	Long: `Delete stored history past the retention set in WEATHERSTATION_RETENTION, rolling raw observations up before they are
+deleted, then compact the store. serve does this daily, stop it before running this against the same store.
+
+WEATHERSTATION_RETENTION is a comma separated list of class=age pairs over the default of
+raw=30d,minute=30d,hour=2y,day=forever. The classes are raw, minute, hour and day, and an age is a number of days,
+weeks or years such as 30d, 8w or 2y, a go duration, or forever.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		st, err := openStore()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if st == nil {
+			log.Fatal(errors.New("the store is disabled by WEATHERSTATION_STORE=false"))
+		}
+		defer st.Close()
+
+		rollups, err := newAggregator(st)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		retainer, err := newRetainer(st, rollups)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		apply := retainer.Apply
+		if retentionDryRun {
+			apply = retainer.Plan
+		}
+
+		report, err := apply(ctx, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.WriteTo(os.Stdout)
+
+		if !retentionDryRun && len(report.Expired) > 0 {
+			if err := st.Compact(ctx); err != nil {
+				log.Fatalf("compacting store: %v", err)
+			}
+		}
+	},
+}
+
+func init() {
+	retentionCmd.Flags().BoolVar(&retentionDryRun, "dry-run", false, "report what would be deleted without deleting it")
+	rootCmd.AddCommand(retentionCmd)
+}
@@ -1,11 +1,28 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/backfill"
+	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+	"github.com/kdwils/weatherstation/pkg/rain"
+	"github.com/kdwils/weatherstation/pkg/retention"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest"
 	"github.com/spf13/cobra"
 )
 
+// source overrides the environment configured connection, see newConnection
+var source string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "weatherstation",
@@ -21,6 +38,189 @@ func Execute() {
 	}
 }
 
+// newConnection creates the tempest connection configured by the environment.
+// The --source flag takes precedence: "-" reads newline delimited json from stdin and "exec:<command>" reads the stdout of a shell command.
+func newConnection(ctx context.Context) (connection.Connection, error) {
+	scheme := getEnvOrDefault("WEATHERSTATION_TEMPEST_SCHEME", "wss")
+	host := getEnvOrDefault("WEATHERSTATION_TEMPEST_HOST", "")
+	path := getEnvOrDefault("WEATHERSTATION_TEMPEST_PATH", "")
+	token := getEnvOrDefault("WEATHERSTATION_TEMPEST_TOKEN", "")
+
+	switch {
+	case source == "":
+	case source == "-":
+		scheme = "stdin"
+	case strings.HasPrefix(source, "exec:"):
+		scheme = "exec"
+		path = strings.TrimPrefix(source, "exec:")
+	default:
+		return nil, fmt.Errorf("unsupported source %q: expected - or exec:<command>", source)
+	}
+
+	return connection.NewConnection(ctx, scheme, host, path, token)
+}
+
+// listenerOptions returns the listener options shared by every command. Acks are awaited for websocket connections,
+// so a misconfigured device fails fast. WEATHERSTATION_TEMPEST_ACK_TIMEOUT overrides the timeout, "0" disables it.
+// WEATHERSTATION_TEMPEST_MALFORMED sets the malformed message policy and WEATHERSTATION_TEMPEST_DEAD_LETTER_FILE
+// appends rejected messages to a file. Duplicate messages within WEATHERSTATION_TEMPEST_DEDUP_WINDOW, 10 minutes by default, are discarded,
+// with the serial numbers of a hub's udp broadcasts mapped to device ids from the station metadata of client, see mapSerials.
+// WEATHERSTATION_TEMPEST_DEVICE_IDS and WEATHERSTATION_TEMPEST_STATION_IDS listen to more devices and stations.
+// An invalid value is an error naming its variable. The returned func closes the dead letter file and is called on shutdown.
+func listenerOptions(ctx context.Context, client api.Client) ([]tempest.Option, func(), error) {
+	devices, err := getEnvIntsOrDefault("WEATHERSTATION_TEMPEST_DEVICE_IDS", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	stations, err := getEnvIntsOrDefault("WEATHERSTATION_TEMPEST_STATION_IDS", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timeout := time.Duration(0)
+	scheme := strings.ToLower(getEnvOrDefault("WEATHERSTATION_TEMPEST_SCHEME", "wss"))
+	if source == "" && (scheme == "wss" || scheme == "ws") {
+		timeout = 10 * time.Second
+	}
+
+	timeout, err = getEnvDurationOrDefault("WEATHERSTATION_TEMPEST_ACK_TIMEOUT", timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policy, err := tempest.ParseMalformedPolicy(getEnvOrDefault("WEATHERSTATION_TEMPEST_MALFORMED", "fail"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid WEATHERSTATION_TEMPEST_MALFORMED: %w", err)
+	}
+
+	opts := []tempest.Option{
+		tempest.WithAckTimeout(timeout),
+		tempest.WithMalformedPolicy(policy),
+		tempest.WithDevices(devices...),
+		tempest.WithStations(stations...),
+	}
+
+	window, err := getEnvDurationOrDefault("WEATHERSTATION_TEMPEST_DEDUP_WINDOW", 10*time.Minute)
+	if err != nil {
+		return nil, nil, err
+	}
+	if window > 0 {
+		dedup := tempest.NewDeduplicator(window)
+		mapSerials(ctx, dedup, client)
+		opts = append(opts, tempest.WithDeduplicator(dedup))
+	}
+
+	cleanup := func() {}
+	if path := os.Getenv("WEATHERSTATION_TEMPEST_DEAD_LETTER_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening dead letter file: %w", err)
+		}
+		opts = append(opts, tempest.WithDeadLetterSink(tempest.NewDeadLetterWriter(f)))
+		cleanup = func() {
+			if err := f.Close(); err != nil {
+				log.Printf("closing dead letter file: %v", err)
+			}
+		}
+	}
+
+	return opts, cleanup, nil
+}
+
+// mapSerials maps the serial numbers of the devices of the configured token's stations to their device ids, so a hub's udp
+// broadcasts de-duplicate against the websocket api's messages. It is skipped without a token or when the --source flag is set,
+// and a failed request is logged rather than failing startup, leaving serial numbers unmapped.
+func mapSerials(ctx context.Context, dedup *tempest.Deduplicator, client api.Client) {
+	token := getEnvOrDefault("WEATHERSTATION_TEMPEST_TOKEN", "")
+	if source != "" || token == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	meta, err := client.GetStationMetadata(ctx, token)
+	if err != nil {
+		log.Printf("fetching station metadata to map serial numbers: %v", err)
+		return
+	}
+	dedup.MapStations(meta.Stations...)
+}
+
+// registerBackfill backfills gaps in the observations of a listener from the REST api. It is enabled when a token is configured
+// and the --source flag is not set, WEATHERSTATION_TEMPEST_BACKFILL=false disables it.
+func registerBackfill(listener tempest.Listener) {
+	token := getEnvOrDefault("WEATHERSTATION_TEMPEST_TOKEN", "")
+	if source != "" || token == "" || strings.EqualFold(os.Getenv("WEATHERSTATION_TEMPEST_BACKFILL"), "false") {
+		return
+	}
+
+	backfill.New(listener, api.NewClient(), token)
+}
+
+// openStore opens the observation store in WEATHERSTATION_STORE_DIR, ./data by default. It returns nil when
+// WEATHERSTATION_STORE=false disables it.
+func openStore() (*store.Store, error) {
+	if strings.EqualFold(os.Getenv("WEATHERSTATION_STORE"), "false") {
+		return nil, nil
+	}
+
+	st, err := store.Open(getEnvOrDefault("WEATHERSTATION_STORE_DIR", "data"))
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+	return st, nil
+}
+
+// newAggregator creates the rollup aggregator, bucketing by the time zone in WEATHERSTATION_TIMEZONE, the local time zone by
+// default. Rollups are persisted to st when it is not nil.
+func newAggregator(st *store.Store) (*rollup.Aggregator, error) {
+	loc, err := time.LoadLocation(getEnvOrDefault("WEATHERSTATION_TIMEZONE", "Local"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEATHERSTATION_TIMEZONE: %w", err)
+	}
+
+	opts := []rollup.Option{rollup.WithLocation(loc)}
+	if st != nil {
+		opts = append(opts, rollup.WithStore(st))
+	}
+	return rollup.New(opts...), nil
+}
+
+// newRainLedger totals the rain of the rollups and keeps rain events in a store, with water years starting in the month
+// numbered by WEATHERSTATION_WATER_YEAR_START
+func newRainLedger(st *store.Store, rollups *rollup.Aggregator) (*rain.Ledger, error) {
+	month := getEnvIntOrDefault("WEATHERSTATION_WATER_YEAR_START", int(time.October))
+	if month < 1 || month > 12 {
+		return nil, fmt.Errorf("invalid WEATHERSTATION_WATER_YEAR_START: %d is not a month", month)
+	}
+
+	opts := []rain.Option{rain.WithWaterYearStart(time.Month(month))}
+	if st != nil {
+		opts = append(opts, rain.WithStore(st))
+	}
+	return rain.New(rollups, opts...), nil
+}
+
+// degreeDayConfig returns how degree days are computed, as configured by WEATHERSTATION_DEGREE_DAYS, see degreedays.ParseConfig
+func degreeDayConfig() (degreedays.Config, error) {
+	config, err := degreedays.ParseConfig(getEnvOrDefault("WEATHERSTATION_DEGREE_DAYS", ""))
+	if err != nil {
+		return config, fmt.Errorf("invalid WEATHERSTATION_DEGREE_DAYS: %w", err)
+	}
+	return config, nil
+}
+
+// newRetainer applies the retention policy in WEATHERSTATION_RETENTION to a store and the rollups kept in it, see retention.ParsePolicy
+func newRetainer(st *store.Store, rollups *rollup.Aggregator) (*retention.Retainer, error) {
+	policy, err := retention.ParsePolicy(getEnvOrDefault("WEATHERSTATION_RETENTION", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEATHERSTATION_RETENTION: %w", err)
+	}
+	return retention.New(st, rollups, policy), nil
+}
+
 func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().StringVar(&source, "source", "", `read tempest json from "-" (stdin) or "exec:<command>" instead of the configured connection`)
 }
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/api/mocks"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+	"github.com/kdwils/weatherstation/pkg/tempest/tempesttest"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListenerOptionsMapSerials(t *testing.T) {
+	// the same observation from the websocket api and from a hub's udp broadcast
+	const (
+		cloud = `{"type":"obs_st","device_id":1,"obs":[[1000,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0,0,0,0,2.41,1,0,0,0,0]]}`
+		local = `{"type":"obs_st","serial_number":"ST-00000001","hub_sn":"HB-00000001","obs":[[1000,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0,0,0,0,2.41,1,0,0,0,0]]}`
+	)
+
+	metadata := api.StationMetadata{Stations: []api.Station{{Devices: []api.Device{{DeviceID: 1, SerialNumber: "ST-00000001"}}}}}
+
+	tests := []struct {
+		name   string
+		token  string
+		expect func(client *mocks.MockClient)
+		want   int32
+	}{
+		{
+			name:  "serial numbers are mapped from the station metadata",
+			token: "token",
+			expect: func(client *mocks.MockClient) {
+				client.EXPECT().GetStationMetadata(gomock.Any(), "token").Return(metadata, nil)
+			},
+			want: 1,
+		},
+		{
+			name:  "a failed metadata request leaves serial numbers unmapped",
+			token: "token",
+			expect: func(client *mocks.MockClient) {
+				client.EXPECT().GetStationMetadata(gomock.Any(), "token").Return(api.StationMetadata{}, errors.New("unauthorized"))
+			},
+			want: 2,
+		},
+		{
+			name:   "station metadata is not requested without a token",
+			expect: func(client *mocks.MockClient) {},
+			want:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEATHERSTATION_TEMPEST_TOKEN", tt.token)
+			t.Setenv("WEATHERSTATION_TEMPEST_ACK_TIMEOUT", "0")
+
+			ctrl := gomock.NewController(t)
+			client := mocks.NewMockClient(ctrl)
+			tt.expect(client)
+
+			ctx := context.Background()
+			opts, closeOptions, err := listenerOptions(ctx, client)
+			if err != nil {
+				t.Fatalf("listenerOptions() error = %v", err)
+			}
+			defer closeOptions()
+
+			conn := tempesttest.NewConn(2)
+			listener := tempest.NewEventListener(conn, tempest.ListenGroupStart, 0, opts...)
+
+			var handled atomic.Int32
+			listener.RegisterHandler(tempest.EventObservationTempest, func(ctx context.Context, b []byte) {
+				handled.Add(1)
+			})
+
+			conn.Send(ctx, cloud)
+			conn.Send(ctx, local)
+			conn.End()
+			listener.Listen(ctx)
+
+			if got := handled.Load(); got != tt.want {
+				t.Errorf("handled %d observations, want %d", got, tt.want)
+			}
+		})
+	}
+}
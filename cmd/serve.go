@@ -4,11 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/kdwils/weatherstation/pkg/api"
-	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+	"github.com/kdwils/weatherstation/pkg/retention"
+	"github.com/kdwils/weatherstation/pkg/store"
 	"github.com/kdwils/weatherstation/pkg/tempest"
 	"github.com/kdwils/weatherstation/server"
 	"github.com/spf13/cobra"
@@ -26,32 +33,176 @@ var serveCmd = &cobra.Command{
 	Short: "Serve the weather station dashboard",
 	Long:  `Serve the weather station dashboard`,
 	Run: func(cmd *cobra.Command, args []string) {
-		scheme := getEnvOrDefault("WEATHERSTATION_TEMPEST_SCHEME", "wss")
-		host := getEnvOrDefault("WEATHERSTATION_TEMPEST_HOST", "")
-		path := getEnvOrDefault("WEATHERSTATION_TEMPEST_PATH", "")
-		token := getEnvOrDefault("WEATHERSTATION_TEMPEST_TOKEN", "")
-		device := getEnvIntOrDefault("WEATHERSTATION_TEMPEST_DEVICE_ID", 0)
-		serverPort := getEnvIntOrDefault("WEATHERSTATION_SERVER_PORT", 8080)
-
-		ctx := context.Background()
-		conn, err := connection.NewConnection(ctx, scheme, host, path, token)
-		if err != nil {
+		if err := runServe(); err != nil {
 			log.Fatal(err)
 		}
+	},
+}
+
+// runServe serves the dashboard until an interrupt or termination signal, then shuts the server down and waits for the
+// listeners, so the store and the dead letter file are closed by the deferred calls
+func runServe() error {
+	device := getEnvIntOrDefault("WEATHERSTATION_TEMPEST_DEVICE_ID", 0)
+	serverPort := getEnvIntOrDefault("WEATHERSTATION_SERVER_PORT", 8080)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := newConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts, closeOptions, err := listenerOptions(ctx, api.NewClient())
+	if err != nil {
+		return err
+	}
+	defer closeOptions()
+
+	listener := tempest.NewEventListener(conn, tempest.ListenGroupStart, device, opts...)
+	registerBackfill(listener)
+
+	// the dashboard consumes a hub, so other in-process consumers can share the connection
+	hub := tempest.NewHub(listener)
+	dashboard := hub.Subscribe()
 
-		listener := tempest.NewEventListener(conn, tempest.ListenGroupStart, device)
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	if st != nil {
+		defer st.Close()
+	}
 
-		srv := server.New(listener, serverPort)
+	rollups, err := newAggregator(st)
+	if err != nil {
+		return err
+	}
 
-		http.HandleFunc("/", server.CORSMiddleware(srv.HandleHome()))
-		http.HandleFunc("/events", server.CORSMiddleware(srv.HandleEvents()))
-		fs := http.StripPrefix("/static/", http.FileServer(http.Dir("static")))
+	ledger, err := newRainLedger(st, rollups)
+	if err != nil {
+		return err
+	}
 
-		http.Handle("/static/", server.CORSMiddleware(fs))
+	config, err := degreeDayConfig()
+	if err != nil {
+		return err
+	}
+	degreeDays := degreedays.New(rollups, config)
+
+	// the recorder keeps history, rollups and rain events, sharing the connection with the dashboard
+	recorder := hub.Subscribe()
+	rollups.Attach(recorder)
+	ledger.Attach(recorder)
+
+	// the goroutines are waited for before returning, so the recorder and retention are done with the store when it is closed
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	serverOpts := []server.Option{server.WithRollups(rollups), server.WithRain(ledger), server.WithDegreeDays(degreeDays)}
+	if st != nil {
+		serverOpts = append(serverOpts, server.WithStore(st))
+		st.Attach(recorder)
+
+		retainer, err := newRetainer(st, rollups)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			maintainStore(ctx, st, retainer)
+		}()
+	}
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if err := recorder.Listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("recorder listener error: %v", err)
+		}
+	}()
 
+	srv := server.New(dashboard, serverPort, serverOpts...)
+
+	go func() {
+		defer wg.Done()
+		if err := dashboard.Listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("dashboard listener error: %v", err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		if err := hub.Run(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("global listener error: %v", err)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.CORSMiddleware(srv.HandleHome()))
+	mux.HandleFunc("/events", server.CORSMiddleware(srv.HandleEvents()))
+	mux.HandleFunc("/events/wind", server.CORSMiddleware(srv.HandleWindEvents()))
+	mux.HandleFunc("/rollups", server.CORSMiddleware(srv.HandleRollups()))
+	mux.HandleFunc("/almanac", server.CORSMiddleware(srv.HandleAlmanac()))
+	mux.HandleFunc("/rain", server.CORSMiddleware(srv.HandleRain()))
+	mux.HandleFunc("/degree-days", server.CORSMiddleware(srv.HandleDegreeDays()))
+	mux.HandleFunc("/history", server.CORSMiddleware(srv.HandleHistory()))
+	mux.HandleFunc("/health", server.CORSMiddleware(srv.HandleHealth()))
+	mux.HandleFunc("/metrics", srv.HandleMetrics())
+	fs := http.StripPrefix("/static/", http.FileServer(http.Dir("static")))
+
+	mux.Handle("/static/", server.CORSMiddleware(fs))
+
+	// requests share the signal context, so streaming handlers end on shutdown instead of holding it up
+	httpServer := &http.Server{
+		Addr:        fmt.Sprintf(":%d", serverPort),
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
 		log.Printf("Serving on port %d", serverPort)
-		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", serverPort), nil))
-	},
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		stop()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("received signal to terminate")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// maintainStore applies the retention policy and compacts the store once a day
+func maintainStore(ctx context.Context, st *store.Store, retainer *retention.Retainer) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := retainer.Apply(ctx, time.Now())
+			if err != nil {
+				log.Printf("applying retention: %v", err)
+			}
+			for _, e := range report.Expired {
+				log.Printf("retention: deleted %d %s records of device %d before %s", e.Records, e.Class, e.Device, e.Before.Format(time.RFC3339))
+			}
+
+			if err := st.Compact(ctx); err != nil {
+				log.Printf("compacting store: %v", err)
+			}
+		}
+	}
 }
 
 func init() {
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/simulate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simulateOutput    string
+	simulateAddr      string
+	simulateBroadcast string
+	simulateSeed      uint64
+	simulateSpeed     float64
+	simulateDuration  time.Duration
+	simulateStart     string
+	simulateDevice    int
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Generate traffic from a synthetic tempest station",
+	Long: `Generate obs_st, rapid_wind, evt_precip and evt_strike traffic from a synthetic tempest station, with a daily temperature
+and solar cycle, passing fronts and afternoon thunderstorms.
+
+The output is "udp" to imitate a hub broadcasting in its own format, "ws" to serve the websocket api, or a file path to write newline delimited json, "-" for stdout.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		start := time.Now()
+		if simulateStart != "" {
+			t, err := time.Parse(time.RFC3339, simulateStart)
+			if err != nil {
+				log.Fatalf("invalid start: %v", err)
+			}
+			start = t
+		}
+
+		seed := simulateSeed
+		if seed == 0 {
+			seed = uint64(time.Now().UnixNano())
+		}
+
+		device := simulateDevice
+		if device == 0 {
+			device = getEnvIntOrDefault("WEATHERSTATION_TEMPEST_DEVICE_ID", 1)
+		}
+
+		send, closeOutput, err := simulateSink(simulateOutput)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer closeOutput()
+
+		log.Printf("simulating device %d from %s with seed %d at %gx speed", device, start.Format(time.RFC3339), seed, simulateSpeed)
+
+		station := simulate.New(start, simulate.WithSeed(seed), simulate.WithDevice(device))
+		err = station.Run(ctx, simulateSpeed, simulateDuration, func(ctx context.Context, m simulate.Message) error {
+			return send(ctx, m.Event)
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatal(err)
+		}
+	},
+}
+
+// simulateSink opens the output of the simulate command, returning a function that sends a message to it
+func simulateSink(output string) (func(ctx context.Context, v any) error, func(), error) {
+	switch output {
+	case "udp":
+		addr := simulateAddr
+		if addr == "" {
+			addr = ":54000"
+		}
+
+		var opts []simulate.UDPOption
+		if simulateBroadcast != "" {
+			opts = append(opts, simulate.WithBroadcast(simulateBroadcast))
+		}
+
+		hub, err := simulate.NewUDPHub(addr, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("hub listening on udp %s", hub.Addr())
+
+		send := func(ctx context.Context, v any) error {
+			if err := hub.Send(v); err != nil {
+				log.Printf("sending to hub receivers: %v", err)
+			}
+			return nil
+		}
+		return send, func() { hub.Close() }, nil
+	case "ws":
+		addr := simulateAddr
+		if addr == "" {
+			addr = "localhost:8081"
+		}
+
+		// bind before serving, so an address in use fails the command before the simulation starts
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		srv := simulate.NewServer()
+		mux := http.NewServeMux()
+		mux.Handle(srv.Path, srv)
+		httpServer := &http.Server{Handler: mux}
+
+		go func() {
+			if err := httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("serving the websocket api: %v", err)
+			}
+		}()
+		log.Printf("serving the websocket api on ws://%s%s", ln.Addr(), srv.Path)
+
+		send := func(ctx context.Context, v any) error {
+			if err := srv.Send(ctx, v); err != nil {
+				log.Printf("sending to websocket clients: %v", err)
+			}
+			return nil
+		}
+		closeServer := func() {
+			srv.Disconnect()
+			httpServer.Close()
+		}
+		return send, closeServer, nil
+	case "":
+		return nil, nil, fmt.Errorf("an output is required")
+	default:
+		// stdout is not closed, only a file this command created
+		var w io.Writer = os.Stdout
+		closeFile := func() {}
+		if output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return nil, nil, err
+			}
+			w = f
+			closeFile = func() { f.Close() }
+		}
+
+		encoder := json.NewEncoder(w)
+		send := func(ctx context.Context, v any) error {
+			return encoder.Encode(v)
+		}
+		return send, closeFile, nil
+	}
+}
+
+func init() {
+	simulateCmd.Flags().StringVar(&simulateOutput, "output", "udp", `"udp", "ws", or a file path to write newline delimited json to, "-" for stdout`)
+	simulateCmd.Flags().StringVar(&simulateAddr, "addr", "", "address the udp hub binds to, :54000 by default, or the websocket api listens on, localhost:8081 by default")
+	simulateCmd.Flags().StringVar(&simulateBroadcast, "broadcast", "255.255.255.255:50222", "address the udp hub also broadcasts to, empty to disable")
+	simulateCmd.Flags().Uint64Var(&simulateSeed, "seed", 0, "seed of the simulated weather, random when 0")
+	simulateCmd.Flags().Float64Var(&simulateSpeed, "speed", 1, "how many times faster than real time to run, 0 runs as fast as possible")
+	simulateCmd.Flags().DurationVar(&simulateDuration, "duration", 0, "how much simulated time to generate, forever when 0")
+	simulateCmd.Flags().StringVar(&simulateStart, "start", "", "RFC3339 time the simulation starts at, now by default")
+	simulateCmd.Flags().IntVar(&simulateDevice, "device", 0, "device id of the simulated station, WEATHERSTATION_TEMPEST_DEVICE_ID or 1 by default")
+	rootCmd.AddCommand(simulateCmd)
+}
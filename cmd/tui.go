@@ -7,7 +7,7 @@ import (
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/api"
 	"github.com/kdwils/weatherstation/tui"
 	"github.com/spf13/cobra"
 )
@@ -18,27 +18,48 @@ var tuiCmd = &cobra.Command{
 	Short: "Display weather data in a terminal UI",
 	Long:  `Display weather data in a terminal user interface using Bubble Tea`,
 	Run: func(cmd *cobra.Command, args []string) {
-		scheme := getEnvOrDefault("WEATHERSTATION_TEMPEST_SCHEME", "wss")
-		host := getEnvOrDefault("WEATHERSTATION_TEMPEST_HOST", "")
-		path := getEnvOrDefault("WEATHERSTATION_TEMPEST_PATH", "")
-		token := getEnvOrDefault("WEATHERSTATION_TEMPEST_TOKEN", "")
 		device := getEnvIntOrDefault("WEATHERSTATION_TEMPEST_DEVICE_ID", 0)
 
 		ctx := context.Background()
-		conn, err := connection.NewConnection(ctx, scheme, host, path, token)
+		conn, err := newConnection(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		m := tui.InitialModel(conn, device)
+		listenerOpts, closeOptions, err := listenerOptions(ctx, api.NewClient())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer closeOptions()
+
+		m := tui.InitialModel(conn, device, listenerOpts...)
+
+		rollups, err := newAggregator(nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.SetRollups(rollups)
+
+		config, err := degreeDayConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.SetDegreeDays(config)
+		registerBackfill(m.Listener())
 
 		go m.StartListener()
 
-		p := tea.NewProgram(
-			m,
+		opts := []tea.ProgramOption{
 			tea.WithAltScreen(),
 			tea.WithMouseCellMotion(),
-		)
+		}
+
+		// stdin carries the weather data, so keyboard input is read from the terminal instead
+		if source == "-" {
+			opts = append(opts, tea.WithInputTTY())
+		}
+
+		p := tea.NewProgram(m, opts...)
 
 		if _, err := p.Run(); err != nil {
 			fmt.Printf("Error running program: %v", err)
@@ -0,0 +1,175 @@
+// Package almanac summarizes the day rollups of a device into the day's highs and lows and the records of its month, year and all time.
+package almanac
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+// Period is the span a summary covers
+type Period string
+
+const (
+	Day     Period = "day"
+	Month   Period = "month"
+	Year    Period = "year"
+	AllTime Period = "all"
+)
+
+// Periods are every period, shortest first
+var Periods = []Period{Day, Month, Year, AllTime}
+
+// ParsePeriod parses a period name
+func ParsePeriod(s string) (Period, error) {
+	switch p := Period(s); p {
+	case Day, Month, Year, AllTime:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown period %q: expected day, month, year or all", s)
+}
+
+// Start returns the start of the period containing t in loc, the zero time for all time
+func (p Period) Start(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch p {
+	case Day:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case Year:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Time{}
+	}
+}
+
+// End returns the start of the period after the one starting at start, the zero time for all time
+func (p Period) End(start time.Time) time.Time {
+	switch p {
+	case Day:
+		return start.AddDate(0, 0, 1)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	case Year:
+		return start.AddDate(1, 0, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// Extreme is the highest or lowest value of a field over a period and when it was first observed. A zero Time is a value
+// that was not observed, or was aggregated before the times of extremes were kept.
+type Extreme struct {
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// Gust is the peak gust over a period in m/s and the direction of the wind when it was observed
+type Gust struct {
+	Speed     float64   `json:"speed"`
+	Direction float64   `json:"direction"`
+	Time      time.Time `json:"time"`
+}
+
+// Summary is the extremes of a device over a period. For a day they are its highs and lows, and for longer periods its
+// records with the time each was set. Temperatures are in °C, rain rates in mm per hour and pressures in mb.
+type Summary struct {
+	Device int       `json:"device_id"`
+	Period Period    `json:"period"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	// Days is the number of days with observations
+	Days int `json:"days"`
+
+	High        Extreme `json:"high"`
+	Low         Extreme `json:"low"`
+	PeakGust    Gust    `json:"peak_gust"`
+	MaxRainRate Extreme `json:"max_rain_rate"`
+	MaxUV       Extreme `json:"max_uv"`
+	MinPressure Extreme `json:"min_pressure"`
+	MaxPressure Extreme `json:"max_pressure"`
+}
+
+// Almanac summarizes the day rollups of an aggregator, bucketed in its time zone
+type Almanac struct {
+	rollups *rollup.Aggregator
+}
+
+// New creates an almanac of the day rollups of an aggregator
+func New(rollups *rollup.Aggregator) *Almanac {
+	return &Almanac{rollups: rollups}
+}
+
+// Summary returns the summary of a device over the period containing t
+func (a *Almanac) Summary(ctx context.Context, device int, p Period, t time.Time) (Summary, error) {
+	start := p.Start(t, a.rollups.Location())
+	s := Summary{Device: device, Period: p, Start: start, End: p.End(start)}
+
+	days, err := a.rollups.Query(ctx, rollup.Query{Device: device, Resolution: rollup.Day, Start: s.Start, End: s.End})
+	if err != nil {
+		return s, err
+	}
+	for _, b := range days {
+		s.add(b, a.rollups.Location())
+	}
+	return s, nil
+}
+
+// Summaries returns the summary of a device for each period containing t, shortest first
+func (a *Almanac) Summaries(ctx context.Context, device int, t time.Time) ([]Summary, error) {
+	summaries := make([]Summary, 0, len(Periods))
+	for _, p := range Periods {
+		s, err := a.Summary(ctx, device, p, t)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, nil
+}
+
+// Devices returns the devices with rollups in memory
+func (a *Almanac) Devices() []int {
+	return a.rollups.Devices()
+}
+
+// add merges the extremes of a day bucket into the summary, keeping the earlier time on ties
+func (s *Summary) add(b rollup.Bucket, loc *time.Location) {
+	if b.Observations == 0 {
+		return
+	}
+	first := s.Days == 0
+	s.Days++
+
+	at := func(epoch int64) time.Time {
+		if epoch == 0 {
+			return time.Time{}
+		}
+		return time.Unix(epoch, 0).In(loc)
+	}
+	higher := func(e *Extreme, v float64, epoch int64) {
+		if first || v > e.Value || (v == e.Value && at(epoch).Before(e.Time)) {
+			*e = Extreme{Value: v, Time: at(epoch)}
+		}
+	}
+	lower := func(e *Extreme, v float64, epoch int64) {
+		if first || v < e.Value || (v == e.Value && at(epoch).Before(e.Time)) {
+			*e = Extreme{Value: v, Time: at(epoch)}
+		}
+	}
+
+	higher(&s.High, b.AirTemperature.Max, b.AirTemperature.MaxEpoch)
+	lower(&s.Low, b.AirTemperature.Min, b.AirTemperature.MinEpoch)
+	higher(&s.MaxRainRate, b.RainRate.Max, b.RainRate.MaxEpoch)
+	higher(&s.MaxUV, b.UVIndex.Max, b.UVIndex.MaxEpoch)
+	lower(&s.MinPressure, b.StationPressure.Min, b.StationPressure.MinEpoch)
+	higher(&s.MaxPressure, b.StationPressure.Max, b.StationPressure.MaxEpoch)
+
+	gust := at(b.WindGust.MaxEpoch)
+	if first || b.WindGust.Max > s.PeakGust.Speed || (b.WindGust.Max == s.PeakGust.Speed && gust.Before(s.PeakGust.Time)) {
+		s.PeakGust = Gust{Speed: b.WindGust.Max, Direction: b.GustDirection, Time: gust}
+	}
+}
@@ -0,0 +1,109 @@
+package almanac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+func observation(t time.Time, data api.ObservationTempestData) api.ObservationTempest {
+	data.TimeEpoch = int(t.Unix())
+	return api.ObservationTempest{Type: "obs_st", Device: 1, Data: data}
+}
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	loc := time.FixedZone("station", -6*3600)
+	rollups := rollup.New(rollup.WithLocation(loc))
+	a := New(rollups)
+
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, loc)
+	}
+	for _, obs := range []api.ObservationTempest{
+		observation(at(6, 30, 15), api.ObservationTempestData{AirTemperature: 35, WindGust: 20, WindDirectionDegrees: 270, UltraviolentIndex: 9, StationPressure: 1000, ReportInterval: 1}),
+		observation(at(7, 1, 6), api.ObservationTempestData{AirTemperature: 18, WindGust: 3, WindDirectionDegrees: 90, StationPressure: 1012, ReportInterval: 1}),
+		observation(at(7, 1, 15), api.ObservationTempestData{AirTemperature: 30, WindGust: 12, WindDirectionDegrees: 200, RainAccumulated: 0.5, UltraviolentIndex: 8, StationPressure: 1008, ReportInterval: 1}),
+		// ties the high later in the day
+		observation(at(7, 1, 17), api.ObservationTempestData{AirTemperature: 30, WindGust: 4, WindDirectionDegrees: 220, UltraviolentIndex: 6, StationPressure: 1009, ReportInterval: 1}),
+		observation(at(7, 2, 14), api.ObservationTempestData{AirTemperature: 33, WindGust: 8, WindDirectionDegrees: 180, RainAccumulated: 0.1, UltraviolentIndex: 10, StationPressure: 1005, ReportInterval: 1}),
+	} {
+		rollups.Add(ctx, obs)
+	}
+
+	tests := []struct {
+		name   string
+		period Period
+		t      time.Time
+		want   Summary
+	}{
+		{
+			name:   "a day's highs and lows",
+			period: Day,
+			t:      at(7, 1, 23),
+			want: Summary{
+				Days:        1,
+				High:        Extreme{Value: 30, Time: at(7, 1, 15)},
+				Low:         Extreme{Value: 18, Time: at(7, 1, 6)},
+				PeakGust:    Gust{Speed: 12, Direction: 200, Time: at(7, 1, 15)},
+				MaxRainRate: Extreme{Value: 30, Time: at(7, 1, 15)},
+				MaxUV:       Extreme{Value: 8, Time: at(7, 1, 15)},
+				MinPressure: Extreme{Value: 1008, Time: at(7, 1, 15)},
+				MaxPressure: Extreme{Value: 1012, Time: at(7, 1, 6)},
+			},
+		},
+		{
+			name:   "the month's records",
+			period: Month,
+			t:      at(7, 15, 0),
+			want: Summary{
+				Days:        2,
+				High:        Extreme{Value: 33, Time: at(7, 2, 14)},
+				Low:         Extreme{Value: 18, Time: at(7, 1, 6)},
+				PeakGust:    Gust{Speed: 12, Direction: 200, Time: at(7, 1, 15)},
+				MaxRainRate: Extreme{Value: 30, Time: at(7, 1, 15)},
+				MaxUV:       Extreme{Value: 10, Time: at(7, 2, 14)},
+				MinPressure: Extreme{Value: 1005, Time: at(7, 2, 14)},
+				MaxPressure: Extreme{Value: 1012, Time: at(7, 1, 6)},
+			},
+		},
+		{
+			name:   "all time records",
+			period: AllTime,
+			t:      at(7, 15, 0),
+			want: Summary{
+				Days:        3,
+				High:        Extreme{Value: 35, Time: at(6, 30, 15)},
+				Low:         Extreme{Value: 18, Time: at(7, 1, 6)},
+				PeakGust:    Gust{Speed: 20, Direction: 270, Time: at(6, 30, 15)},
+				MaxRainRate: Extreme{Value: 30, Time: at(7, 1, 15)},
+				MaxUV:       Extreme{Value: 10, Time: at(7, 2, 14)},
+				MinPressure: Extreme{Value: 1000, Time: at(6, 30, 15)},
+				MaxPressure: Extreme{Value: 1012, Time: at(7, 1, 6)},
+			},
+		},
+		{
+			name:   "a day without observations",
+			period: Day,
+			t:      at(7, 3, 12),
+			want:   Summary{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Summary(ctx, 1, tt.period, tt.t)
+			if err != nil {
+				t.Fatalf("Summary() error = %v", err)
+			}
+
+			got.Device, got.Period, got.Start, got.End = 0, "", time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("Summary() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"time"
 )
 
 type Client interface {
 	GetStationMetadata(ctx context.Context, token string) (StationMetadata, error)
 	GetLatestStationObservation(ctx context.Context, stationID, token string) (ObservationReport, error)
 	GetLatestDeviceObservation(ctx context.Context, deviceID, token string) (ObservationTempest, error)
+	GetDeviceObservations(ctx context.Context, deviceID, token string, start, end time.Time) (DeviceObservations, error)
 }
 
 type Status struct {
@@ -147,6 +149,7 @@ type Observation struct {
 	Type    string `json:"type"`
 	Device  int    `json:"device_id,omitempty"`
 	Station int    `json:"station_id,omitempty"`
+	Source  string `json:"source,omitempty"`
 }
 
 // ObservationTempest describes the event payload for a 'obs_st' event
@@ -207,6 +210,11 @@ func (o *LightningStrike) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON writes the strike as an array, the same shape it is received in
+func (o LightningStrike) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{o.TimeEpoch, o.DistanceInKM, o.Energy})
+}
+
 func (o *ObservationTempestData) UnmarshalJSON(b []byte) error {
 	data := make([][]any, 0)
 	err := json.Unmarshal(b, &data)
@@ -218,40 +226,71 @@ func (o *ObservationTempestData) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("no observation data in payload")
 	}
 
-	const (
-		totalObservationFields = 22
-	)
+	return o.parse(data[0])
+}
 
-	obs := data[0]
-	if len(obs) != totalObservationFields {
+const (
+	totalObservationFields = 22
+	// hubObservationFields are the values of a row a hub broadcasts over udp, without the rain fields the cloud computes
+	hubObservationFields = 18
+)
+
+// parse reads an observation row. Values the station did not report are null and read as zero, as are the rain fields the
+// cloud computes when the row is a hub's udp broadcast.
+func (o *ObservationTempestData) parse(obs []any) error {
+	if len(obs) != totalObservationFields && len(obs) != hubObservationFields {
 		return fmt.Errorf("observation data is missing: %d total, expected %d", len(obs), totalObservationFields)
 	}
 
-	o.TimeEpoch = int(obs[0].(float64))
-	o.WindLull = obs[1].(float64)
-	o.WindAverage = obs[2].(float64)
-	o.WindGust = obs[3].(float64)
-	o.WindDirectionDegrees = obs[4].(float64)
-	o.WindSampleInterval = int(obs[5].(float64))
-	o.StationPressure = obs[6].(float64)
-	o.AirTemperature = obs[7].(float64)
-	o.RelativeHumidity = int(obs[8].(float64))
-	o.Illuminance = int(obs[9].(float64))
-	o.UltraviolentIndex = obs[10].(float64)
-	o.SolarRadiation = int(obs[11].(float64))
-	o.RainAccumulated = obs[12].(float64)
-	o.PrecipitationType = int(obs[13].(float64))
-	o.LightningStrikeAverageDistance = obs[14].(float64)
-	o.LightningStrikeCount = int(obs[15].(float64))
-	o.BatteryVolts = obs[16].(float64)
-	o.ReportInterval = int(obs[17].(float64))
-	o.LocalDailyRainAccumulation = obs[18].(float64)
-	o.RainAccumulationFinalCheck = obs[19].(float64)
-	o.LocalRainAccumulationFinalCheck = obs[20].(float64)
-	o.PrecipitationAnalysisType = int(obs[21].(float64))
+	o.TimeEpoch = int(number(obs[0]))
+	o.WindLull = number(obs[1])
+	o.WindAverage = number(obs[2])
+	o.WindGust = number(obs[3])
+	o.WindDirectionDegrees = number(obs[4])
+	o.WindSampleInterval = int(number(obs[5]))
+	o.StationPressure = number(obs[6])
+	o.AirTemperature = number(obs[7])
+	o.RelativeHumidity = int(number(obs[8]))
+	o.Illuminance = int(number(obs[9]))
+	o.UltraviolentIndex = number(obs[10])
+	o.SolarRadiation = int(number(obs[11]))
+	o.RainAccumulated = number(obs[12])
+	o.PrecipitationType = int(number(obs[13]))
+	o.LightningStrikeAverageDistance = number(obs[14])
+	o.LightningStrikeCount = int(number(obs[15]))
+	o.BatteryVolts = number(obs[16])
+	o.ReportInterval = int(number(obs[17]))
+	if len(obs) == hubObservationFields {
+		return nil
+	}
+	o.LocalDailyRainAccumulation = number(obs[18])
+	o.RainAccumulationFinalCheck = number(obs[19])
+	o.LocalRainAccumulationFinalCheck = number(obs[20])
+	o.PrecipitationAnalysisType = int(number(obs[21]))
 	return nil
 }
 
+// MarshalJSON writes the observation as a single row, the same shape it is received in
+func (o ObservationTempestData) MarshalJSON() ([]byte, error) {
+	return json.Marshal([][]any{o.row()})
+}
+
+func (o ObservationTempestData) row() []any {
+	return []any{
+		o.TimeEpoch, o.WindLull, o.WindAverage, o.WindGust, o.WindDirectionDegrees, o.WindSampleInterval,
+		o.StationPressure, o.AirTemperature, o.RelativeHumidity, o.Illuminance, o.UltraviolentIndex, o.SolarRadiation,
+		o.RainAccumulated, o.PrecipitationType, o.LightningStrikeAverageDistance, o.LightningStrikeCount, o.BatteryVolts,
+		o.ReportInterval, o.LocalDailyRainAccumulation, o.RainAccumulationFinalCheck, o.LocalRainAccumulationFinalCheck,
+		o.PrecipitationAnalysisType,
+	}
+}
+
+// number returns a json number as a float64, or zero for null
+func number(v any) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
 type ObservationTempestSummary struct {
 	PressureTrend                  string  `json:"pressure_trend"`
 	StrikeCountOneHour             int     `json:"strike_count_1h"`
@@ -277,42 +316,35 @@ func (o ObservationTempest) IsRaining() bool {
 }
 
 func (o ObservationTempest) WindDirection() string {
-	var (
-		compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
-	)
-
-	degrees := math.Mod((o.Data.WindDirectionDegrees + 360), 360)
-	degreeStep := 360.0 / float64(len(compassPoints))
-	index := int((degrees/degreeStep)+0.5) % len(compassPoints)
-	return compassPoints[index]
+	return CompassDirection(o.Data.WindDirectionDegrees)
 }
 
 func (o ObservationTempest) WindSpeedGustMPH() float64 {
-	return metersPerSecondToMilesPerHour(o.Data.WindGust)
+	return MetersPerSecondToMilesPerHour(o.Data.WindGust)
 }
 
 func (o ObservationTempest) WindSpeedAverageMPH() float64 {
-	return metersPerSecondToMilesPerHour(o.Data.WindAverage)
+	return MetersPerSecondToMilesPerHour(o.Data.WindAverage)
 }
 
 func (o ObservationTempest) RainfallInInches() float64 {
-	return millimetersToInches(o.Data.RainAccumulated)
+	return MillimetersToInches(o.Data.RainAccumulated)
 }
 
 func (o ObservationTempest) RainfallYesterdayInInches() float64 {
-	return millimetersToInches(float64(o.Summary.PrecipMinutesLocalYesterday))
+	return MillimetersToInches(float64(o.Summary.PrecipMinutesLocalYesterday))
 }
 
 func (o ObservationTempest) TemperatureInFarneheit() float64 {
-	return celsiusToFahrenheit(o.Data.AirTemperature)
+	return CelsiusToFahrenheit(o.Data.AirTemperature)
 }
 
 func (o ObservationTempest) FeelsLikeFarenheit() float64 {
-	return celsiusToFahrenheit(o.Summary.FeelsLike)
+	return CelsiusToFahrenheit(o.Summary.FeelsLike)
 }
 
 func (o ObservationTempest) DewPointFarenheit() float64 {
-	return celsiusToFahrenheit(o.Summary.DewPoint)
+	return CelsiusToFahrenheit(o.Summary.DewPoint)
 }
 
 func (o ObservationTempest) PrecipitationType() string {
@@ -330,17 +362,32 @@ func (o ObservationTempest) AverageLightningStrikeDistanceInMiles() float64 {
 	return kilometersToMiles(o.Data.LightningStrikeAverageDistance)
 }
 
-func metersPerSecondToMilesPerHour(mps float64) float64 {
+// CompassDirection returns the 16 point compass direction for a bearing in degrees
+func CompassDirection(bearing float64) string {
+	var (
+		compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+	)
+
+	degrees := math.Mod((bearing + 360), 360)
+	degreeStep := 360.0 / float64(len(compassPoints))
+	index := int((degrees/degreeStep)+0.5) % len(compassPoints)
+	return compassPoints[index]
+}
+
+// MetersPerSecondToMilesPerHour converts a speed in m/s, the unit tempest devices report, to mph
+func MetersPerSecondToMilesPerHour(mps float64) float64 {
 	const conversion = 2.23694
 	return mps * conversion
 }
 
-func millimetersToInches(mm float64) float64 {
+// MillimetersToInches converts a length of rain in mm to inches
+func MillimetersToInches(mm float64) float64 {
 	const conversion = 0.03937
 	return mm * conversion
 }
 
-func celsiusToFahrenheit(celsius float64) float64 {
+// CelsiusToFahrenheit converts a temperature in °C to °F
+func CelsiusToFahrenheit(celsius float64) float64 {
 	return celsius*9/5 + 32
 }
 
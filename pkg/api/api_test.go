@@ -1,6 +1,9 @@
 package api
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 func TestObservationTempest_Raining(t *testing.T) {
 	type fields struct {
@@ -60,3 +63,68 @@ func TestObservationTempest_Raining(t *testing.T) {
 		})
 	}
 }
+
+func TestObservationTempestData_MarshalJSON(t *testing.T) {
+	payload := `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0,0,0,0,2.41,1,0,0,0,0]]}`
+
+	var want ObservationTempest
+	if err := json.Unmarshal([]byte(payload), &want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got ObservationTempest
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("marshaled observation does not unmarshal: %v", err)
+	}
+	if got.Data != want.Data {
+		t.Errorf("round trip = %+v, want %+v", got.Data, want.Data)
+	}
+}
+
+func TestObservationTempestData_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    ObservationTempestData
+		wantErr bool
+	}{
+		{
+			name:    "websocket api row",
+			payload: `[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0.1,1,0,0,2.41,1,0.5,0.6,0.7,1]]`,
+			want: ObservationTempestData{TimeEpoch: 1588948614, WindLull: 0.18, WindAverage: 0.22, WindGust: 0.27, WindDirectionDegrees: 144,
+				WindSampleInterval: 6, StationPressure: 1017.57, AirTemperature: 22.37, RelativeHumidity: 50, Illuminance: 328,
+				UltraviolentIndex: 0.03, SolarRadiation: 3, RainAccumulated: 0.1, PrecipitationType: 1, BatteryVolts: 2.41, ReportInterval: 1,
+				LocalDailyRainAccumulation: 0.5, RainAccumulationFinalCheck: 0.6, LocalRainAccumulationFinalCheck: 0.7, PrecipitationAnalysisType: 1},
+		},
+		{
+			name:    "hub udp row",
+			payload: `[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0.1,1,0,0,2.41,1]]`,
+			want: ObservationTempestData{TimeEpoch: 1588948614, WindLull: 0.18, WindAverage: 0.22, WindGust: 0.27, WindDirectionDegrees: 144,
+				WindSampleInterval: 6, StationPressure: 1017.57, AirTemperature: 22.37, RelativeHumidity: 50, Illuminance: 328,
+				UltraviolentIndex: 0.03, SolarRadiation: 3, RainAccumulated: 0.1, PrecipitationType: 1, BatteryVolts: 2.41, ReportInterval: 1},
+		},
+		{
+			name:    "truncated row",
+			payload: `[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50,328]]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ObservationTempestData
+			err := json.Unmarshal([]byte(tt.payload), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
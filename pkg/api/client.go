@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultBaseURL is the base url of the tempest REST api
+const DefaultBaseURL = "https://swd.weatherflow.com/swd/rest"
+
+// SourceBackfill marks observations recovered from the REST api after they were missed on a live connection
+const SourceBackfill = "backfill"
+
+// DeviceObservations describes the response of the device observations endpoint
+type DeviceObservations struct {
+	Status       Status                 `json:"status"`
+	Device       int                    `json:"device_id"`
+	Type         string                 `json:"type"`
+	Source       string                 `json:"source"`
+	Observations ObservationTempestRows `json:"obs"`
+}
+
+// ObservationTempestRows is a list of observation rows, as returned by the REST api
+type ObservationTempestRows []ObservationTempestData
+
+func (o *ObservationTempestRows) UnmarshalJSON(b []byte) error {
+	data := make([][]any, 0)
+	err := json.Unmarshal(b, &data)
+	if err != nil {
+		return fmt.Errorf("invalid observations: %v", err)
+	}
+
+	rows := make(ObservationTempestRows, len(data))
+	for i, row := range data {
+		if err := rows[i].parse(row); err != nil {
+			return err
+		}
+	}
+
+	*o = rows
+	return nil
+}
+
+func (o ObservationTempestRows) MarshalJSON() ([]byte, error) {
+	rows := make([][]any, len(o))
+	for i, data := range o {
+		rows[i] = data.row()
+	}
+	return json.Marshal(rows)
+}
+
+// Tempest returns each row as a tempest observation of the device
+func (d DeviceObservations) Tempest() []ObservationTempest {
+	observations := make([]ObservationTempest, len(d.Observations))
+	for i, data := range d.Observations {
+		observations[i] = ObservationTempest{
+			Status: d.Status,
+			Type:   "obs_st",
+			Source: d.Source,
+			Data:   data,
+			Device: d.Device,
+		}
+	}
+	return observations
+}
+
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// Option configures optional behavior of the REST client
+type Option func(*client)
+
+// WithBaseURL sets the base url requests are made to. Defaults to DefaultBaseURL.
+func WithBaseURL(u string) Option {
+	return func(c *client) {
+		c.baseURL = u
+	}
+}
+
+// WithHTTPClient sets the http client used to make requests
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *client) {
+		c.http = h
+	}
+}
+
+// NewClient creates a client for the tempest REST api
+func NewClient(opts ...Option) Client {
+	c := &client{
+		baseURL: DefaultBaseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *client) GetStationMetadata(ctx context.Context, token string) (StationMetadata, error) {
+	var m StationMetadata
+	err := c.get(ctx, "/stations", url.Values{"token": {token}}, &m, &m.Status)
+	return m, err
+}
+
+func (c *client) GetLatestStationObservation(ctx context.Context, stationID, token string) (ObservationReport, error) {
+	var r ObservationReport
+	err := c.get(ctx, "/observations/station/"+url.PathEscape(stationID), url.Values{"token": {token}}, &r, &r.Status)
+	return r, err
+}
+
+func (c *client) GetLatestDeviceObservation(ctx context.Context, deviceID, token string) (ObservationTempest, error) {
+	var o ObservationTempest
+	err := c.get(ctx, "/observations/device/"+url.PathEscape(deviceID), url.Values{"token": {token}}, &o, &o.Status)
+	return o, err
+}
+
+func (c *client) GetDeviceObservations(ctx context.Context, deviceID, token string, start, end time.Time) (DeviceObservations, error) {
+	query := url.Values{
+		"token":      {token},
+		"time_start": {strconv.FormatInt(start.Unix(), 10)},
+		"time_end":   {strconv.FormatInt(end.Unix(), 10)},
+	}
+
+	var d DeviceObservations
+	err := c.get(ctx, "/observations/device/"+url.PathEscape(deviceID), query, &d, &d.Status)
+	return d, err
+}
+
+// get decodes the response of a request into v. A response whose status has a non-zero code is an error.
+func (c *client) get(ctx context.Context, path string, query url.Values, v any, status *Status) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	if status.StatusCode != 0 {
+		return fmt.Errorf("GET %s: status %d: %s", path, status.StatusCode, status.StatusMessage)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_GetDeviceObservations(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		status   int
+		want     []int
+		wantErr  bool
+	}{
+		{
+			name: "rows with null values",
+			response: `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":1,"type":"obs_st","source":"db","obs":[
+				[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,1,0,null,null,0],
+				[1588948674,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,1,0,null,null,0]]}`,
+			status: http.StatusOK,
+			want:   []int{1588948614, 1588948674},
+		},
+		{
+			name:     "no observations",
+			response: `{"status":{"status_code":0,"status_message":"SUCCESS"},"device_id":1,"type":"obs_st","obs":null}`,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "error status",
+			response: `{"status":{"status_code":404,"status_message":"NOT FOUND"}}`,
+			status:   http.StatusOK,
+			wantErr:  true,
+		},
+		{
+			name:    "unauthorized",
+			status:  http.StatusUnauthorized,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/observations/device/1" {
+					t.Errorf("path = %s", r.URL.Path)
+				}
+				q := r.URL.Query()
+				if q.Get("token") != "token" || q.Get("time_start") != "1588948600" || q.Get("time_end") != "1588948700" {
+					t.Errorf("query = %s", r.URL.RawQuery)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			c := NewClient(WithBaseURL(srv.URL))
+			got, err := c.GetDeviceObservations(context.Background(), "1", "token", time.Unix(1588948600, 0), time.Unix(1588948700, 0))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDeviceObservations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			observations := got.Tempest()
+			if len(observations) != len(tt.want) {
+				t.Fatalf("observations = %d, want %d", len(observations), len(tt.want))
+			}
+			for i, o := range observations {
+				if o.Data.TimeEpoch != tt.want[i] || o.Device != 1 || o.Type != "obs_st" {
+					t.Errorf("observation %d = %+v", i, o)
+				}
+			}
+		})
+	}
+}
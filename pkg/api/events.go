@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is implemented by the typed payload of each tempest message. EventType must not depend on the value it is called on.
+type Event interface {
+	EventType() string
+}
+
+// Ack describes the payload of an 'ack' message sent in response to a listen request
+type Ack struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ConnectionOpened describes the payload of a 'connection_opened' message
+type ConnectionOpened struct {
+	Type string `json:"type"`
+}
+
+// RapidWind describes the event payload for a 'rapid_wind' event
+type RapidWind struct {
+	Type   string        `json:"type"`
+	Data   RapidWindData `json:"ob"`
+	Device int           `json:"device_id"`
+}
+
+type RapidWindData struct {
+	TimeEpoch            int     `json:"time_epoch"`
+	WindSpeed            float64 `json:"wind_speed"`
+	WindDirectionDegrees float64 `json:"wind_direction"`
+}
+
+// LightningStrikeEvent describes the event payload for a 'evt_strike' event
+type LightningStrikeEvent struct {
+	Type   string          `json:"type"`
+	Strike LightningStrike `json:"evt"`
+	Device int             `json:"device_id"`
+}
+
+// PrecipitationEvent describes the event payload for a 'evt_precip' event, sent when rain starts
+type PrecipitationEvent struct {
+	Type   string            `json:"type"`
+	Data   PrecipitationData `json:"evt"`
+	Device int               `json:"device_id"`
+}
+
+type PrecipitationData struct {
+	TimeEpoch int `json:"time_epoch"`
+}
+
+// DeviceOnline describes the event payload for a 'evt_device_online' event
+type DeviceOnline struct {
+	Type   string `json:"type"`
+	Device int    `json:"device_id"`
+}
+
+// DeviceOffline describes the event payload for a 'evt_device_offline' event. Synthetic is set when the event was
+// raised locally because the device stopped reporting, rather than sent by the server.
+type DeviceOffline struct {
+	Type      string `json:"type"`
+	Device    int    `json:"device_id"`
+	Synthetic bool   `json:"synthetic,omitempty"`
+}
+
+// DeviceStale describes the payload of a synthetic 'evt_device_stale' event, raised when a device's observations are overdue
+type DeviceStale struct {
+	Type          string `json:"type"`
+	Device        int    `json:"device_id"`
+	LastSeenEpoch int64  `json:"last_seen_epoch"`
+}
+
+// StationOnline describes the event payload for a 'evt_station_online' event
+type StationOnline struct {
+	Type    string `json:"type"`
+	Station int    `json:"station_id"`
+}
+
+// StationOffline describes the event payload for a 'evt_station_offline' event
+type StationOffline struct {
+	Type    string `json:"type"`
+	Station int    `json:"station_id"`
+}
+
+func (Ack) EventType() string                  { return "ack" }
+func (ConnectionOpened) EventType() string     { return "connection_opened" }
+func (ObservationTempest) EventType() string   { return "obs_st" }
+func (RapidWind) EventType() string            { return "rapid_wind" }
+func (LightningStrikeEvent) EventType() string { return "evt_strike" }
+func (PrecipitationEvent) EventType() string   { return "evt_precip" }
+func (DeviceOnline) EventType() string         { return "evt_device_online" }
+func (DeviceOffline) EventType() string        { return "evt_device_offline" }
+func (DeviceStale) EventType() string          { return "evt_device_stale" }
+func (StationOnline) EventType() string        { return "evt_station_online" }
+func (StationOffline) EventType() string       { return "evt_station_offline" }
+
+func (o *RapidWindData) UnmarshalJSON(b []byte) error {
+	data := make([]float64, 0)
+	err := json.Unmarshal(b, &data)
+	if err != nil {
+		return fmt.Errorf("invalid rapid wind event: %v", err)
+	}
+
+	if len(data) < 3 {
+		return fmt.Errorf("no rapid wind data in payload")
+	}
+
+	o.TimeEpoch = int(data[0])
+	o.WindSpeed = data[1]
+	o.WindDirectionDegrees = data[2]
+	return nil
+}
+
+func (o *PrecipitationData) UnmarshalJSON(b []byte) error {
+	data := make([]int, 0)
+	err := json.Unmarshal(b, &data)
+	if err != nil {
+		return fmt.Errorf("invalid precipitation event: %v", err)
+	}
+
+	if len(data) < 1 {
+		return fmt.Errorf("no precipitation data in payload")
+	}
+
+	o.TimeEpoch = data[0]
+	return nil
+}
+
+// MarshalJSON writes the rapid wind data as an array, the same shape it is received in
+func (o RapidWindData) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{o.TimeEpoch, o.WindSpeed, o.WindDirectionDegrees})
+}
+
+// MarshalJSON writes the precipitation data as an array, the same shape it is received in
+func (o PrecipitationData) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{o.TimeEpoch})
+}
+
+func (o RapidWind) WindDirection() string {
+	return CompassDirection(o.Data.WindDirectionDegrees)
+}
+
+func (o RapidWind) WindSpeedMPH() float64 {
+	return MetersPerSecondToMilesPerHour(o.Data.WindSpeed)
+}
+
+func (o LightningStrikeEvent) DistanceInMiles() float64 {
+	return kilometersToMiles(float64(o.Strike.DistanceInKM))
+}
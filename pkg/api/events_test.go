@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRapidWind_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    RapidWindData
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			payload: `{"type":"rapid_wind","device_id":1,"ob":[1588948614,0.27,144]}`,
+			want:    RapidWindData{TimeEpoch: 1588948614, WindSpeed: 0.27, WindDirectionDegrees: 144},
+		},
+		{
+			name:    "missing fields",
+			payload: `{"type":"rapid_wind","device_id":1,"ob":[1588948614]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RapidWind
+			err := json.Unmarshal([]byte(tt.payload), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Data != tt.want {
+				t.Errorf("Unmarshal() = %+v, want %+v", got.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestLightningStrikeEvent_UnmarshalJSON(t *testing.T) {
+	var got LightningStrikeEvent
+	if err := json.Unmarshal([]byte(`{"type":"evt_strike","device_id":1,"evt":[1493322445,27,3848]}`), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := LightningStrike{TimeEpoch: 1493322445, DistanceInKM: 27, Energy: 3848}
+	if got.Strike != want {
+		t.Errorf("strike = %+v, want %+v", got.Strike, want)
+	}
+}
+
+func TestPrecipitationEvent_UnmarshalJSON(t *testing.T) {
+	var got PrecipitationEvent
+	if err := json.Unmarshal([]byte(`{"type":"evt_precip","device_id":1,"evt":[1493322445]}`), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Data.TimeEpoch != 1493322445 {
+		t.Errorf("time epoch = %d, want 1493322445", got.Data.TimeEpoch)
+	}
+}
+
+func TestEvents_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "rapid wind",
+			v:    RapidWind{Type: "rapid_wind", Device: 1, Data: RapidWindData{TimeEpoch: 1588948614, WindSpeed: 0.27, WindDirectionDegrees: 144}},
+			want: `{"type":"rapid_wind","ob":[1588948614,0.27,144],"device_id":1}`,
+		},
+		{
+			name: "lightning strike",
+			v:    LightningStrikeEvent{Type: "evt_strike", Device: 1, Strike: LightningStrike{TimeEpoch: 1493322445, DistanceInKM: 27, Energy: 3848}},
+			want: `{"type":"evt_strike","evt":[1493322445,27,3848],"device_id":1}`,
+		},
+		{
+			name: "precipitation",
+			v:    PrecipitationEvent{Type: "evt_precip", Device: 1, Data: PrecipitationData{TimeEpoch: 1493322445}},
+			want: `{"type":"evt_precip","evt":[1493322445],"device_id":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	api "github.com/kdwils/weatherstation/pkg/api"
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +42,21 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// GetDeviceObservations mocks base method.
+func (m *MockClient) GetDeviceObservations(ctx context.Context, deviceID, token string, start, end time.Time) (api.DeviceObservations, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceObservations", ctx, deviceID, token, start, end)
+	ret0, _ := ret[0].(api.DeviceObservations)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceObservations indicates an expected call of GetDeviceObservations.
+func (mr *MockClientMockRecorder) GetDeviceObservations(ctx, deviceID, token, start, end any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceObservations", reflect.TypeOf((*MockClient)(nil).GetDeviceObservations), ctx, deviceID, token, start, end)
+}
+
 // GetLatestDeviceObservation mocks base method.
 func (m *MockClient) GetLatestDeviceObservation(ctx context.Context, deviceID, token string) (api.ObservationTempest, error) {
 	m.ctrl.T.Helper()
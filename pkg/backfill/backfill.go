@@ -0,0 +1,145 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+const (
+	defaultMaxGap  = 24 * time.Hour
+	defaultTimeout = time.Minute
+)
+
+// Backfiller watches the epochs of obs_st observations for gaps longer than the report interval. The missing range is fetched
+// from the REST api and the observations are injected into the listener in order, with their Source set to api.SourceBackfill.
+// A gap is only seen once the live observation after it arrives, so handlers receive the backfilled observations after that
+// newer observation, out of order. Observations without a device id, such as a hub's udp broadcasts, are not backfilled
+// since the REST api is queried by device.
+type Backfiller struct {
+	listener tempest.Listener
+	client   api.Client
+	token    string
+	maxGap   time.Duration
+	mu       sync.Mutex
+	last     map[int]int
+	// fillMu serializes backfills, so the observations of one gap are injected before those of the next
+	fillMu sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// Option configures optional behavior of a Backfiller
+type Option func(*Backfiller)
+
+// WithMaxGap limits how far back a gap is backfilled. Defaults to 24 hours.
+func WithMaxGap(d time.Duration) Option {
+	return func(b *Backfiller) {
+		if d > 0 {
+			b.maxGap = d
+		}
+	}
+}
+
+// New creates a backfiller and registers its handler on the listener. The token authenticates requests to the REST api.
+func New(l tempest.Listener, client api.Client, token string, opts ...Option) *Backfiller {
+	b := &Backfiller{
+		listener: l,
+		client:   client,
+		token:    token,
+		maxGap:   defaultMaxGap,
+		last:     make(map[int]int),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	tempest.On(l, b.handleObservation)
+	return b
+}
+
+// Wait blocks until every backfill in progress is done
+func (b *Backfiller) Wait() {
+	b.wg.Wait()
+}
+
+func (b *Backfiller) handleObservation(ctx context.Context, obs api.ObservationTempest) {
+	if obs.Source == api.SourceBackfill || obs.Device == 0 {
+		return
+	}
+
+	epoch := obs.Data.TimeEpoch
+	interval := max(obs.Data.ReportInterval, 1) * 60
+
+	b.mu.Lock()
+	prev, ok := b.last[obs.Device]
+	if epoch > prev {
+		b.last[obs.Device] = epoch
+	}
+	b.mu.Unlock()
+
+	// allow half an interval of jitter before treating a late observation as a gap
+	if !ok || epoch-prev <= interval+interval/2 {
+		return
+	}
+
+	start := time.Unix(int64(prev)+1, 0)
+	end := time.Unix(int64(epoch)-1, 0)
+	if end.Sub(start) > b.maxGap {
+		start = end.Add(-b.maxGap)
+	}
+
+	// fetch in the background, injecting from the handler would wait on the queue the handler is running on
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.fill(obs.Device, start, end)
+	}()
+}
+
+// fill fetches the observations of a device between start and end and injects them in order
+func (b *Backfiller) fill(device int, start, end time.Time) {
+	b.fillMu.Lock()
+	defer b.fillMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	d, err := b.client.GetDeviceObservations(ctx, strconv.Itoa(device), b.token, start, end)
+	if err != nil {
+		log.Printf("backfill: fetching observations of device %d from %s to %s: %v", device, start, end, err)
+		return
+	}
+
+	observations := d.Tempest()
+	slices.SortFunc(observations, func(a, b api.ObservationTempest) int {
+		return a.Data.TimeEpoch - b.Data.TimeEpoch
+	})
+
+	for _, o := range observations {
+		if o.Data.TimeEpoch < int(start.Unix()) || o.Data.TimeEpoch > int(end.Unix()) {
+			continue
+		}
+
+		o.Device = device
+		o.Source = api.SourceBackfill
+
+		msg, err := json.Marshal(o)
+		if err != nil {
+			log.Printf("backfill: encoding observation: %v", err)
+			continue
+		}
+
+		if err := b.listener.Inject(context.Background(), msg); err != nil {
+			log.Printf("backfill: injecting observation: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/api/mocks"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+	"github.com/kdwils/weatherstation/pkg/tempest/tempesttest"
+	"go.uber.org/mock/gomock"
+)
+
+func observation(epoch int) string {
+	return fmt.Sprintf(`{"type":"obs_st","device_id":1,"obs":[[%d,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0,0,0,0,2.41,1,0,0,0,0]]}`, epoch)
+}
+
+// serialObservation names its device by serial number instead of device id, as a hub's udp broadcasts do
+func serialObservation(epoch int) string {
+	return fmt.Sprintf(`{"serial_number":"ST-00000512","type":"obs_st","hub_sn":"HB-00013030","obs":[[%d,0.18,0.22,0.27,144,6,1017.57,22.37,50,328,0.03,3,0,0,0,0,2.41,1,0,0,0,0]]}`, epoch)
+}
+
+func TestBackfillGap(t *testing.T) {
+	tests := []struct {
+		name    string
+		message func(epoch int) string
+		epochs  []int
+		fetch   bool
+		want    []int
+	}{
+		{
+			name:   "no gap",
+			epochs: []int{1000, 1060, 1150},
+		},
+		{
+			name:   "gap is backfilled in order",
+			epochs: []int{1000, 1240},
+			fetch:  true,
+			want:   []int{1060, 1120, 1180},
+		},
+		{
+			name:    "udp broadcasts without a device id are not backfilled",
+			message: serialObservation,
+			epochs:  []int{1000, 1240},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := mocks.NewMockClient(ctrl)
+			if tt.fetch {
+				client.EXPECT().
+					GetDeviceObservations(gomock.Any(), "1", "token", time.Unix(1001, 0), time.Unix(1239, 0)).
+					Return(api.DeviceObservations{
+						Device: 1,
+						Observations: api.ObservationTempestRows{
+							{TimeEpoch: 1180, ReportInterval: 1},
+							{TimeEpoch: 1060, ReportInterval: 1},
+							{TimeEpoch: 1120, ReportInterval: 1},
+						},
+					}, nil)
+			}
+
+			conn := tempesttest.NewConn(0)
+			l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1)
+			b := New(l, client, "token")
+
+			var mu sync.Mutex
+			var got []int
+			live := make(chan struct{}, len(tt.epochs))
+			tempest.On(l, func(ctx context.Context, obs api.ObservationTempest) {
+				if obs.Source != api.SourceBackfill {
+					live <- struct{}{}
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, obs.Data.TimeEpoch)
+			})
+
+			done := make(chan error)
+			go func() { done <- l.Listen(context.Background()) }()
+
+			message := observation
+			if tt.message != nil {
+				message = tt.message
+			}
+			for _, epoch := range tt.epochs {
+				if err := conn.Send(context.Background(), message(epoch)); err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+
+			// the backfiller's handler runs first, so any backfill has started once every live observation reached this one
+			for range tt.epochs {
+				<-live
+			}
+			b.Wait()
+			conn.End()
+			<-done
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("backfilled epochs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,13 +2,21 @@ package connection
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"os"
 	"strings"
 
 	"github.com/coder/websocket"
 )
 
+// ErrReadOnly is returned by Write on connections that cannot send requests, such as an MQTT subscription without a
+// publish topic. The data is not sent.
+var ErrReadOnly = errors.New("connection is read only")
+
 // Connection is a generic interface for a connection to a tempest device
 type Connection interface {
 	Write(context.Context, any) error
@@ -17,11 +25,24 @@ type Connection interface {
 }
 
 const (
-	wss = "wss"
-	udp = "udp"
+	wss   = "wss"
+	ws    = "ws"
+	udp   = "udp"
+	mqtt  = "mqtt"
+	mqtts = "mqtts"
+	stdin = "stdin"
+	execs = "exec"
+
+	defaultMQTTPort  = "1883"
+	defaultMQTTSPort = "8883"
 )
 
-// NewConnection determines the connection type via the passed tempest scheme. Supports websockets or UDP client connections.
+// NewConnection determines the connection type via the passed tempest scheme. Supports websockets, UDP, MQTT, stdin or process output connections.
+// The unencrypted ws scheme is accepted for local servers, such as the tempesttest fake. Websocket and MQTT connections
+// reconnect with DefaultReconnect when they fail.
+//
+// For MQTT the path is a comma separated list of topics to subscribe to and the token holds the broker credentials as username:password.
+// For stdin ("-" is accepted as an alias) newline delimited json is read from standard input. For exec the path is a shell command whose stdout is read line by line.
 func NewConnection(ctx context.Context, scheme, host, path, token string) (Connection, error) {
 	u := &url.URL{
 		Host:   host,
@@ -29,16 +50,53 @@ func NewConnection(ctx context.Context, scheme, host, path, token string) (Conne
 	}
 
 	switch strings.ToLower(scheme) {
-	case wss:
+	case wss, ws:
 		qps := make(url.Values)
 		qps.Set("token", token)
 		u.RawQuery = qps.Encode()
 		u.Path = path
 
-		return NewWebsocket(ctx, u.String(), nil)
+		return NewReconnectingWebsocket(ctx, u.String(), nil, DefaultReconnect)
 	case udp:
-		return NewUDP(ctx, u.String())
+		return NewUDP(ctx, host)
+	case mqtt, mqtts:
+		return newMQTTFromParams(ctx, strings.ToLower(scheme), host, path, token)
+	case stdin, "-":
+		return NewReader(os.Stdin), nil
+	case execs:
+		return NewExec(ctx, path)
 	}
 
 	return nil, fmt.Errorf("unsupported connection protocol: %s", scheme)
 }
+
+// newMQTTFromParams builds mqtt options from the generic connection parameters
+func newMQTTFromParams(ctx context.Context, scheme, host, path, token string) (Connection, error) {
+	opts := MQTTOptions{Reconnect: DefaultReconnect}
+	for _, topic := range strings.Split(path, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			opts.Topics = append(opts.Topics, topic)
+		}
+	}
+
+	if token != "" {
+		opts.Username, opts.Password, _ = strings.Cut(token, ":")
+	}
+
+	port := defaultMQTTPort
+	if scheme == mqtts {
+		port = defaultMQTTSPort
+	}
+
+	addr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		addr = net.JoinHostPort(host, port)
+	}
+
+	if scheme == mqtts {
+		hostname, _, _ := net.SplitHostPort(addr)
+		opts.TLS = &tls.Config{ServerName: hostname}
+	}
+
+	return NewMQTT(ctx, addr, opts)
+}
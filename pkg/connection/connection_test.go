@@ -0,0 +1,83 @@
+package connection
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestNewConnectionUDP(t *testing.T) {
+	hub, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+
+	ctx := context.Background()
+	conn, err := NewConnection(ctx, "udp", hub.LocalAddr().String(), "", "")
+	if err != nil {
+		t.Fatalf("NewConnection() error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := conn.Write(ctx, map[string]string{"type": "listen_start"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	hub.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, err := hub.Read(buf)
+	if err != nil {
+		t.Fatalf("hub did not receive the request: %v", err)
+	}
+	if got := strings.TrimSpace(string(buf[:n])); got != `{"type":"listen_start"}` {
+		t.Errorf("hub received %s, want the listen request", got)
+	}
+}
+
+func TestNewConnectionWebsocket(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.Write(r.Context(), websocket.MessageText, []byte(`{"type":"connection_opened"}`))
+		c.CloseNow()
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	conn, err := NewConnection(ctx, "ws", host, "/swd/data", "secret")
+	if err != nil {
+		t.Fatalf("NewConnection() error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	r := <-requests
+	if r.URL.Path != "/swd/data" || r.URL.Query().Get("token") != "secret" {
+		t.Errorf("dialed %s, want /swd/data with the token", r.URL)
+	}
+
+	b, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(b) != `{"type":"connection_opened"}` {
+		t.Errorf("Read() = %s, want connection_opened", b)
+	}
+}
+
+func TestNewConnectionUnsupportedScheme(t *testing.T) {
+	if _, err := NewConnection(context.Background(), "http", "localhost", "", ""); err == nil {
+		t.Error("NewConnection() with the http scheme succeeded")
+	}
+}
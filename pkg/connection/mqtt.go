@@ -0,0 +1,568 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
+
+// MQTT control packet types
+const (
+	mqttConnect    byte = 1
+	mqttConnack    byte = 2
+	mqttPublish    byte = 3
+	mqttPuback     byte = 4
+	mqttSubscribe  byte = 8
+	mqttSuback     byte = 9
+	mqttPingreq    byte = 12
+	mqttPingresp   byte = 13
+	mqttDisconnect byte = 14
+)
+
+const (
+	// mqttProtocolLevel is the protocol level for MQTT 3.1.1
+	mqttProtocolLevel    byte = 4
+	defaultMQTTKeepAlive      = 30 * time.Second
+	mqttMessageBuffer         = 64
+)
+
+// ErrMQTTClosed is returned when reading from a closed MQTT connection
+var ErrMQTTClosed = errors.New("mqtt connection closed")
+
+// MQTTOptions configures an MQTT connection
+type MQTTOptions struct {
+	// TLS enables TLS when set
+	TLS      *tls.Config
+	ClientID string
+	Username string
+	Password string
+	// PublishTopic is the topic written messages are published to. Writes fail with ErrReadOnly when empty.
+	PublishTopic string
+	// Topics are the topic filters to subscribe to. Each message payload is yielded unchanged.
+	Topics []string
+	// KeepAlive is the interval pings are sent at. Defaults to 30 seconds.
+	KeepAlive time.Duration
+	// QoS is the quality of service used for subscriptions, either 0 or 1
+	QoS byte
+	// Reconnect configures redialing the broker and resubscribing when the connection fails. It never reconnects by default.
+	Reconnect Reconnect
+}
+
+// MQTT satisfies the connection interface for a subscription to an MQTT broker carrying tempest json payloads
+type MQTT struct {
+	// connMu guards conn, which is replaced when reconnecting
+	connMu   sync.Mutex
+	conn     net.Conn
+	opts     MQTTOptions
+	addr     string
+	messages chan []byte
+	subacks  chan []byte
+	done     chan struct{}
+	stats    *Counters
+	writeMu  sync.Mutex
+	packetID atomic.Uint32
+	err      error
+	errOnce  sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewMQTT connects to the broker at addr and subscribes to the configured topics
+func NewMQTT(ctx context.Context, addr string, opts MQTTOptions) (Connection, error) {
+	if len(opts.Topics) == 0 {
+		return nil, errors.New("mqtt: at least one topic is required")
+	}
+	if opts.QoS > 1 {
+		return nil, fmt.Errorf("mqtt: unsupported qos %d", opts.QoS)
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = defaultMQTTKeepAlive
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "weatherstation-" + uuid.New().String()[:8]
+	}
+
+	m := &MQTT{
+		addr:     addr,
+		opts:     opts,
+		messages: make(chan []byte, mqttMessageBuffer),
+		subacks:  make(chan []byte, 1),
+		done:     make(chan struct{}),
+		stats:    NewCounters(StateConnecting),
+	}
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.conn = conn
+
+	m.wg.Add(2)
+	go m.readLoop()
+	go m.keepAlive()
+
+	if err := m.subscribe(ctx); err != nil {
+		m.shutdown(err)
+		return nil, err
+	}
+
+	m.stats.SetState(StateOpen)
+	return m, nil
+}
+
+// dial opens a connection to the broker and connects to it
+func (m *MQTT) dial(ctx context.Context) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.opts.TLS != nil {
+		d := &tls.Dialer{Config: m.opts.TLS}
+		conn, err = d.DialContext(ctx, "tcp", m.addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", m.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.connect(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// connect sends the CONNECT packet and waits for the broker to accept it, for at most the keep alive interval so a broker
+// that accepts the connection but never answers does not hang
+func (m *MQTT) connect(ctx context.Context, conn net.Conn) error {
+	deadline := time.Now().Add(m.opts.KeepAlive)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	defer conn.SetDeadline(time.Time{})
+
+	var flags byte = 0x02 // clean session
+	if m.opts.Username != "" {
+		flags |= 0x80
+	}
+	if m.opts.Password != "" {
+		flags |= 0x40
+	}
+
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, mqttProtocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(m.opts.KeepAlive/time.Second))
+	body = appendMQTTString(body, m.opts.ClientID)
+	if m.opts.Username != "" {
+		body = appendMQTTString(body, m.opts.Username)
+	}
+	if m.opts.Password != "" {
+		body = appendMQTTString(body, m.opts.Password)
+	}
+
+	if err := writeMQTTPacket(conn, mqttConnect<<4, body); err != nil {
+		return err
+	}
+
+	header, ack, err := readMQTTPacket(bufio.NewReader(io.LimitReader(conn, 4)))
+	if err != nil {
+		return fmt.Errorf("mqtt: reading connack: %w", err)
+	}
+	if header>>4 != mqttConnack || len(ack) != 2 {
+		return fmt.Errorf("mqtt: unexpected packet type %d waiting for connack", header>>4)
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused with return code %d", ack[1])
+	}
+
+	return nil
+}
+
+// subscribe subscribes to each topic and waits for the broker to acknowledge them
+func (m *MQTT) subscribe(ctx context.Context) error {
+	if err := m.writePacket(mqttSubscribe<<4|0x02, m.subscription()); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.done:
+		return m.err
+	case codes := <-m.subacks:
+		for i, code := range codes {
+			if code == 0x80 {
+				return fmt.Errorf("mqtt: subscription to %q was rejected", m.opts.Topics[i])
+			}
+		}
+		return nil
+	}
+}
+
+// subscription returns the body of a SUBSCRIBE packet for the configured topics
+func (m *MQTT) subscription() []byte {
+	body := binary.BigEndian.AppendUint16(nil, m.nextPacketID())
+	for _, topic := range m.opts.Topics {
+		body = appendMQTTString(body, topic)
+		body = append(body, m.opts.QoS)
+	}
+	return body
+}
+
+// readLoop reads packets from the broker until the connection is closed, or fails and cannot be reconnected
+func (m *MQTT) readLoop() {
+	defer m.wg.Done()
+
+	conn := m.currentConn()
+	for {
+		err := m.readPackets(bufio.NewReader(conn))
+		if m.isDone() || !m.opts.Reconnect.enabled() {
+			m.shutdown(err)
+			return
+		}
+
+		conn.Close()
+		if conn, err = m.redial(err); err != nil {
+			m.shutdown(err)
+			return
+		}
+	}
+}
+
+// redial reconnects to the broker after the connection failed and resubscribes to the topics. The suback is handled by
+// the read loop like any other packet.
+func (m *MQTT) redial(cause error) (net.Conn, error) {
+	m.stats.SetState(StateConnecting)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var conn net.Conn
+	err := m.opts.Reconnect.retry(ctx, func() error {
+		c, err := m.dial(ctx)
+		if err != nil {
+			return err
+		}
+		if err := writeMQTTPacket(c, mqttSubscribe<<4|0x02, m.subscription()); err != nil {
+			c.Close()
+			return err
+		}
+
+		m.connMu.Lock()
+		defer m.connMu.Unlock()
+		if m.isDone() {
+			c.Close()
+			return ErrMQTTClosed
+		}
+		m.conn = c
+		conn = c
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: reconnecting after %v: %w", cause, err)
+	}
+
+	m.stats.RecordReconnect()
+	m.stats.SetState(StateOpen)
+	return conn, nil
+}
+
+// readPackets reads packets from the broker until reading fails or the connection is shut down
+func (m *MQTT) readPackets(r *bufio.Reader) error {
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return err
+		}
+
+		switch header >> 4 {
+		case mqttPublish:
+			payload, id, err := parseMQTTPublish(header, body)
+			if err != nil {
+				return err
+			}
+
+			if header&0x06 != 0 {
+				if err := m.writePacket(mqttPuback<<4, binary.BigEndian.AppendUint16(nil, id)); err != nil {
+					return err
+				}
+			}
+
+			m.stats.RecordRead(len(payload))
+			select {
+			case m.messages <- payload:
+			case <-m.done:
+				return nil
+			}
+		case mqttSuback:
+			if len(body) < 2 {
+				return errors.New("mqtt: malformed suback")
+			}
+			select {
+			case m.subacks <- body[2:]:
+			default:
+			}
+		case mqttPingresp, mqttPuback:
+		default:
+			return fmt.Errorf("mqtt: unexpected packet type %d", header>>4)
+		}
+	}
+}
+
+// keepAlive pings the broker so the connection is not dropped while idle
+func (m *MQTT) keepAlive() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.opts.KeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if err := m.writePacket(mqttPingreq<<4, nil); err != nil {
+				if !m.opts.Reconnect.enabled() {
+					m.shutdown(err)
+					return
+				}
+				// the read loop notices the closed connection and reconnects
+				m.currentConn().Close()
+			}
+		}
+	}
+}
+
+// shutdown closes the connection once, recording the error that caused it
+func (m *MQTT) shutdown(err error) {
+	m.errOnce.Do(func() {
+		if err == nil || errors.Is(err, net.ErrClosed) {
+			err = ErrMQTTClosed
+		}
+		m.err = err
+		m.stats.SetState(StateClosed)
+
+		m.connMu.Lock()
+		defer m.connMu.Unlock()
+		close(m.done)
+		m.conn.Close()
+	})
+}
+
+func (m *MQTT) isDone() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *MQTT) currentConn() net.Conn {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+	return m.conn
+}
+
+func (m *MQTT) writePacket(header byte, body []byte) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	return writeMQTTPacket(m.currentConn(), header, body)
+}
+
+func (m *MQTT) nextPacketID() uint16 {
+	id := uint16(m.packetID.Add(1))
+	if id == 0 {
+		id = uint16(m.packetID.Add(1))
+	}
+	return id
+}
+
+// Write publishes the json encoded data to the publish topic. It returns ErrReadOnly when no publish topic is configured.
+func (m *MQTT) Write(ctx context.Context, data any) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.done:
+		return m.err
+	default:
+	}
+
+	if m.opts.PublishTopic == "" {
+		return fmt.Errorf("mqtt: no publish topic is configured: %w", ErrReadOnly)
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	body := appendMQTTString(nil, m.opts.PublishTopic)
+	body = append(body, b...)
+	if err := m.writePacket(mqttPublish<<4, body); err != nil {
+		return err
+	}
+
+	m.stats.RecordWrite(len(b))
+	return nil
+}
+
+// Read returns the next message payload received from the subscribed topics
+func (m *MQTT) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case b := <-m.messages:
+		return b, nil
+	case <-m.done:
+		select {
+		case b := <-m.messages:
+			return b, nil
+		default:
+			return nil, m.err
+		}
+	}
+}
+
+// Close disconnects from the broker
+func (m *MQTT) Close(ctx context.Context, _ ...websocket.StatusCode) error {
+	select {
+	case <-m.done:
+		return nil
+	default:
+	}
+
+	err := m.writePacket(mqttDisconnect<<4, nil)
+	m.shutdown(nil)
+	m.wg.Wait()
+	return err
+}
+
+// Stats returns the activity of the mqtt connection
+func (m *MQTT) Stats() Stats {
+	return m.stats.Stats()
+}
+
+// RecordDecodeFailure counts a payload that could not be decoded
+func (m *MQTT) RecordDecodeFailure() {
+	m.stats.RecordDecodeFailure()
+}
+
+// RecordDuplicate counts a payload that was discarded as a duplicate
+func (m *MQTT) RecordDuplicate() {
+	m.stats.RecordDuplicate()
+}
+
+// appendMQTTString appends a length prefixed utf-8 string
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTString reads a length prefixed utf-8 string, returning the remaining bytes
+func readMQTTString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("mqtt: malformed string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("mqtt: malformed string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// writeMQTTPacket writes a fixed header, the variable length encoded remaining length and the body
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+
+	_, err := w.Write(append(packet, body...))
+	return err
+}
+
+// readMQTTPacket reads a single control packet, returning its fixed header byte and body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var (
+		length     int
+		multiplier = 1
+	)
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
+
+// parseMQTTPublish returns the payload and packet id of a PUBLISH packet
+func parseMQTTPublish(header byte, body []byte) ([]byte, uint16, error) {
+	_, rest, err := readMQTTString(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var id uint16
+	if header&0x06 != 0 {
+		if len(rest) < 2 {
+			return nil, 0, errors.New("mqtt: malformed publish")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+
+	return rest, id, nil
+}
@@ -0,0 +1,471 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeBroker is a minimal in-process MQTT broker that serves one client at a time
+type fakeBroker struct {
+	t         *testing.T
+	ln        net.Listener
+	username  string
+	password  string
+	published chan []byte
+	connected chan net.Conn
+	pubacks   chan uint16
+}
+
+func newFakeBroker(t *testing.T, ln net.Listener) *fakeBroker {
+	b := &fakeBroker{
+		t:         t,
+		ln:        ln,
+		published: make(chan []byte, 1),
+		connected: make(chan net.Conn, 1),
+		pubacks:   make(chan uint16, 1),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.handle(conn)
+	}
+}
+
+// handle serves one client connection until it is closed
+func (b *fakeBroker) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case mqttConnect:
+			code := byte(0)
+			if !b.validCredentials(body) {
+				code = 5
+			}
+			writeMQTTPacket(conn, mqttConnack<<4, []byte{0, code})
+			if code != 0 {
+				return
+			}
+		case mqttSubscribe:
+			id := body[:2]
+			codes := make([]byte, 0)
+			rest := body[2:]
+			for len(rest) > 0 {
+				_, r, err := readMQTTString(rest)
+				if err != nil {
+					return
+				}
+				codes = append(codes, r[0])
+				rest = r[1:]
+			}
+			writeMQTTPacket(conn, mqttSuback<<4, append(append([]byte{}, id...), codes...))
+			b.connected <- conn
+		case mqttPublish:
+			payload, _, err := parseMQTTPublish(header, body)
+			if err != nil {
+				return
+			}
+			b.published <- payload
+		case mqttPuback:
+			b.pubacks <- binary.BigEndian.Uint16(body)
+		case mqttPingreq:
+			writeMQTTPacket(conn, mqttPingresp<<4, nil)
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+// validCredentials checks the username and password in a CONNECT packet body
+func (b *fakeBroker) validCredentials(body []byte) bool {
+	_, rest, err := readMQTTString(body)
+	if err != nil || len(rest) < 4 {
+		return false
+	}
+
+	flags := rest[1]
+	rest = rest[4:]
+
+	_, rest, err = readMQTTString(rest)
+	if err != nil {
+		return false
+	}
+
+	var username, password string
+	if flags&0x80 != 0 {
+		if username, rest, err = readMQTTString(rest); err != nil {
+			return false
+		}
+	}
+	if flags&0x40 != 0 {
+		if password, _, err = readMQTTString(rest); err != nil {
+			return false
+		}
+	}
+
+	return username == b.username && password == b.password
+}
+
+// publish sends a PUBLISH packet to the connected client
+func publish(conn net.Conn, topic string, qos byte, id uint16, payload []byte) error {
+	body := appendMQTTString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return writeMQTTPacket(conn, mqttPublish<<4|qos<<1, append(body, payload...))
+}
+
+func listenTCP(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	return ln
+}
+
+func TestNewMQTT(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     MQTTOptions
+		username string
+		password string
+		wantErr  bool
+	}{
+		{
+			name: "anonymous",
+			opts: MQTTOptions{Topics: []string{"tempest/#"}},
+		},
+		{
+			name:     "username and password",
+			opts:     MQTTOptions{Topics: []string{"tempest/#"}, Username: "user", Password: "secret"},
+			username: "user",
+			password: "secret",
+		},
+		{
+			name:     "bad credentials",
+			opts:     MQTTOptions{Topics: []string{"tempest/#"}, Username: "user", Password: "wrong"},
+			username: "user",
+			password: "secret",
+			wantErr:  true,
+		},
+		{
+			name:    "no topics",
+			opts:    MQTTOptions{},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported qos",
+			opts:    MQTTOptions{Topics: []string{"tempest/#"}, QoS: 2},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln := listenTCP(t)
+			b := newFakeBroker(t, ln)
+			b.username = tt.username
+			b.password = tt.password
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, err := NewMQTT(ctx, ln.Addr().String(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMQTT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if conn != nil {
+				conn.Close(ctx)
+			}
+		})
+	}
+}
+
+func TestMQTTRead(t *testing.T) {
+	tests := []struct {
+		name string
+		qos  byte
+	}{
+		{
+			name: "qos 0",
+			qos:  0,
+		},
+		{
+			name: "qos 1 is acknowledged",
+			qos:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln := listenTCP(t)
+			b := newFakeBroker(t, ln)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, err := NewMQTT(ctx, ln.Addr().String(), MQTTOptions{Topics: []string{"tempest/#"}, QoS: tt.qos})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer conn.Close(ctx)
+
+			want := `{"type":"obs_st","device_id":1}`
+			if err := publish(<-b.connected, "tempest/obs", tt.qos, 7, []byte(want)); err != nil {
+				t.Fatalf("failed to publish: %v", err)
+			}
+
+			got, err := conn.Read(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("Read() = %s, want %s", got, want)
+			}
+
+			if tt.qos > 0 {
+				select {
+				case id := <-b.pubacks:
+					if id != 7 {
+						t.Errorf("puback id = %d, want 7", id)
+					}
+				case <-ctx.Done():
+					t.Fatal("expected puback")
+				}
+			}
+
+			stats, _ := StatsOf(conn)
+			if stats.MessagesRead != 1 {
+				t.Errorf("messages read = %d, want 1", stats.MessagesRead)
+			}
+		})
+	}
+}
+
+func TestMQTTWrite(t *testing.T) {
+	ln := listenTCP(t)
+	b := newFakeBroker(t, ln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewMQTT(ctx, ln.Addr().String(), MQTTOptions{Topics: []string{"tempest/#"}, PublishTopic: "tempest/requests"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := conn.Write(ctx, map[string]string{"type": "listen_start"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case got := <-b.published:
+		if string(got) != `{"type":"listen_start"}` {
+			t.Errorf("published = %s", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected published message")
+	}
+}
+
+func TestMQTTWriteWithoutPublishTopic(t *testing.T) {
+	ln := listenTCP(t)
+	newFakeBroker(t, ln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewMQTT(ctx, ln.Addr().String(), MQTTOptions{Topics: []string{"tempest/#"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := conn.Write(ctx, map[string]string{"type": "listen_start"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write() error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestMQTTReadAfterBrokerClose(t *testing.T) {
+	ln := listenTCP(t)
+	b := newFakeBroker(t, ln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewMQTT(ctx, ln.Addr().String(), MQTTOptions{Topics: []string{"tempest/#"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	(<-b.connected).Close()
+
+	if _, err := conn.Read(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Read() error = %v, want connection error", err)
+	}
+}
+
+func TestMQTTReconnect(t *testing.T) {
+	ln := listenTCP(t)
+	b := newFakeBroker(t, ln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewMQTT(ctx, ln.Addr().String(), MQTTOptions{
+		Topics:    []string{"tempest/#"},
+		Reconnect: Reconnect{Attempts: 3, Backoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewMQTT() error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	(<-b.connected).Close()
+
+	// the client reconnects and resubscribes
+	var broker net.Conn
+	select {
+	case broker = <-b.connected:
+	case <-ctx.Done():
+		t.Fatal("client did not resubscribe")
+	}
+	if err := publish(broker, "tempest/obs", 0, 0, []byte(`{"type":"obs_st"}`)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	got, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(got) != `{"type":"obs_st"}` {
+		t.Errorf("Read() = %s", got)
+	}
+
+	stats := conn.(StatsReporter).Stats()
+	if stats.Reconnects != 1 || stats.State != StateOpen {
+		t.Errorf("stats = %+v, want 1 reconnect and open", stats)
+	}
+}
+
+func TestMQTTSilentBroker(t *testing.T) {
+	ln := listenTCP(t)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		// accepts the connection and never answers
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewMQTT(context.Background(), ln.Addr().String(), MQTTOptions{Topics: []string{"tempest/#"}, KeepAlive: 100 * time.Millisecond})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("NewMQTT() error = %v, want a deadline exceeded waiting for connack", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("NewMQTT() hung waiting for connack")
+	}
+}
+
+func TestMQTTTLS(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b := newFakeBroker(t, ln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewMQTT(ctx, ln.Addr().String(), MQTTOptions{
+		Topics: []string{"tempest/#"},
+		TLS:    &tls.Config{RootCAs: pool, ServerName: "localhost"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := publish(<-b.connected, "tempest/obs", 0, 0, []byte(`{}`)); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	if _, err := conn.Read(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
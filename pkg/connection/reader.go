@@ -0,0 +1,222 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/coder/websocket"
+)
+
+const (
+	// maxLineSize is the longest line a reader connection accepts
+	maxLineSize = 1024 * 1024
+	// maxStderrSize is how much of a process's stderr is kept for error reporting
+	maxStderrSize = 4096
+)
+
+// Reader satisfies the connection interface for newline delimited tempest json read from a stream, such as stdin or the output of a process.
+// Writes are discarded since the stream has no way to send requests upstream.
+type Reader struct {
+	lines chan []byte
+	done  chan struct{}
+	stats *Counters
+	close func() error
+	wait  func() error
+	err   error
+	once  sync.Once
+}
+
+// NewReader reads newline delimited messages from r. Blank lines are skipped.
+// If r is an io.Closer it is closed when the connection is closed.
+func NewReader(r io.Reader) Connection {
+	closeFn := func() error { return nil }
+	if c, ok := r.(io.Closer); ok {
+		closeFn = c.Close
+	}
+
+	return newReader(r, closeFn, nil)
+}
+
+// NewExec runs the command with the shell and reads newline delimited messages from its stdout.
+// The process is killed when the connection is closed or the context is cancelled.
+func NewExec(ctx context.Context, command string) (Connection, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, errors.New("exec: command is required")
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr := &limitedBuffer{max: maxStderrSize}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	closeFn := func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		err := cmd.Process.Kill()
+		if errors.Is(err, os.ErrProcessDone) {
+			return nil
+		}
+		return err
+	}
+
+	waitFn := func() error {
+		if err := cmd.Wait(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("exec %q: %w: %s", command, err, msg)
+			}
+			return fmt.Errorf("exec %q: %w", command, err)
+		}
+		return io.EOF
+	}
+
+	return newReader(stdout, closeFn, waitFn), nil
+}
+
+func newReader(r io.Reader, closeFn, waitFn func() error) *Reader {
+	rd := &Reader{
+		lines: make(chan []byte),
+		done:  make(chan struct{}),
+		stats: NewCounters(StateOpen),
+		close: closeFn,
+		wait:  waitFn,
+	}
+
+	go rd.scan(r)
+	return rd
+}
+
+// scan reads lines until the stream ends or the connection is closed
+func (r *Reader) scan(rd io.Reader) {
+	var err error
+	defer func() {
+		if r.wait != nil {
+			if werr := r.wait(); err == nil {
+				err = werr
+			}
+		}
+		if err == nil {
+			err = io.EOF
+		}
+
+		r.err = err
+		r.stats.SetState(StateClosed)
+		close(r.lines)
+	}()
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		b := make([]byte, len(line))
+		copy(b, line)
+
+		select {
+		case r.lines <- b:
+		case <-r.done:
+			return
+		}
+	}
+
+	err = scanner.Err()
+}
+
+// Write discards the data, the stream is read only
+func (r *Reader) Write(ctx context.Context, data any) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// Read returns the next line from the stream. io.EOF is returned once the stream ends.
+func (r *Reader) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-r.done:
+		return nil, io.EOF
+	case b, ok := <-r.lines:
+		if !ok {
+			return nil, r.err
+		}
+		r.stats.RecordRead(len(b))
+		return b, nil
+	}
+}
+
+// Close stops reading from the stream
+func (r *Reader) Close(ctx context.Context, _ ...websocket.StatusCode) error {
+	var err error
+	r.once.Do(func() {
+		close(r.done)
+		r.stats.SetState(StateClosed)
+		err = r.close()
+	})
+	return err
+}
+
+// Stats returns the activity of the reader connection
+func (r *Reader) Stats() Stats {
+	return r.stats.Stats()
+}
+
+// RecordDecodeFailure counts a line that could not be decoded
+func (r *Reader) RecordDecodeFailure() {
+	r.stats.RecordDecodeFailure()
+}
+
+// RecordDuplicate counts a line that was discarded as a duplicate
+func (r *Reader) RecordDuplicate() {
+	r.stats.RecordDuplicate()
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if remaining := b.max - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
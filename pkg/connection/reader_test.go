@@ -0,0 +1,150 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaderRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "multiple lines",
+			input: "{\"type\":\"obs_st\"}\n{\"type\":\"rapid_wind\"}\n",
+			want:  []string{`{"type":"obs_st"}`, `{"type":"rapid_wind"}`},
+		},
+		{
+			name:  "blank lines and missing trailing newline",
+			input: "\n  \n{\"type\":\"evt_precip\"}\r\n\n{\"type\":\"evt_strike\"}",
+			want:  []string{`{"type":"evt_precip"}`, `{"type":"evt_strike"}`},
+		},
+		{
+			name:  "empty input",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			conn := NewReader(strings.NewReader(tt.input))
+			defer conn.Close(ctx)
+
+			for _, want := range tt.want {
+				got, err := conn.Read(ctx)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(got) != want {
+					t.Errorf("Read() = %s, want %s", got, want)
+				}
+			}
+
+			if _, err := conn.Read(ctx); !errors.Is(err, io.EOF) {
+				t.Errorf("Read() error = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestReaderCancelledContext(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	conn := NewReader(r)
+	defer conn.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := conn.Read(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Read() error = %v, want context canceled", err)
+	}
+}
+
+func TestNewExec(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		want      []string
+		wantErr   bool
+		wantEOF   bool
+		errSubstr string
+	}{
+		{
+			name:    "reads stdout",
+			command: `printf '{"type":"obs_st"}\n{"type":"rapid_wind"}\n'`,
+			want:    []string{`{"type":"obs_st"}`, `{"type":"rapid_wind"}`},
+			wantEOF: true,
+		},
+		{
+			name:      "failing command reports stderr",
+			command:   `echo boom >&2; exit 3`,
+			errSubstr: "boom",
+		},
+		{
+			name:    "empty command",
+			command: " ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, err := NewExec(ctx, tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewExec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer conn.Close(ctx)
+
+			for _, want := range tt.want {
+				got, err := conn.Read(ctx)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(got) != want {
+					t.Errorf("Read() = %s, want %s", got, want)
+				}
+			}
+
+			_, err = conn.Read(ctx)
+			if tt.wantEOF && !errors.Is(err, io.EOF) {
+				t.Errorf("Read() error = %v, want EOF", err)
+			}
+			if tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)) {
+				t.Errorf("Read() error = %v, want error containing %q", err, tt.errSubstr)
+			}
+		})
+	}
+}
+
+func TestExecClose(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewExec(ctx, "sleep 30")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := conn.Close(ctx); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := conn.Read(ctx); !errors.Is(err, io.EOF) {
+		t.Errorf("Read() error = %v, want EOF", err)
+	}
+}
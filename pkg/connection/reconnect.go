@@ -0,0 +1,55 @@
+package connection
+
+import (
+	"context"
+	"time"
+)
+
+// Reconnect configures how a connection redials after its transport fails. The zero value never reconnects.
+type Reconnect struct {
+	// Attempts is how many times to redial before giving up, a negative value retries until the context is done
+	Attempts int
+	// Backoff is the wait before the first attempt, it doubles after each failed attempt. Defaults to a second.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts. Defaults to a minute.
+	MaxBackoff time.Duration
+}
+
+// DefaultReconnect retries until the connection is closed, waiting from a second up to a minute between attempts
+var DefaultReconnect = Reconnect{Attempts: -1, Backoff: time.Second, MaxBackoff: time.Minute}
+
+func (r Reconnect) enabled() bool {
+	return r.Attempts != 0
+}
+
+// retry calls dial until it succeeds, the attempts are used up or the context is done, returning the last error
+func (r Reconnect) retry(ctx context.Context, dial func() error) error {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+
+	var err error
+	for attempt := 0; r.Attempts < 0 || attempt < r.Attempts; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return err
+		case <-timer.C:
+		}
+
+		if err = dial(); err == nil {
+			return nil
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+	return err
+}
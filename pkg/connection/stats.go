@@ -0,0 +1,128 @@
+package connection
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// State describes the lifecycle state of a connection
+type State string
+
+const (
+	StateConnecting State = "connecting"
+	StateOpen       State = "open"
+	StateClosed     State = "closed"
+)
+
+// Stats is a point in time snapshot of connection activity
+type Stats struct {
+	LastMessage     time.Time `json:"last_message"`
+	State           State     `json:"state"`
+	MessagesRead    uint64    `json:"messages_read"`
+	MessagesWritten uint64    `json:"messages_written"`
+	BytesRead       uint64    `json:"bytes_read"`
+	BytesWritten    uint64    `json:"bytes_written"`
+	DecodeFailures  uint64    `json:"decode_failures"`
+	Duplicates      uint64    `json:"duplicates"`
+	Reconnects      uint64    `json:"reconnects"`
+}
+
+// StatsReporter is an optional interface implemented by connections that track their activity
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// DecodeFailureRecorder is an optional interface implemented by connections that count messages consumers could not decode
+type DecodeFailureRecorder interface {
+	RecordDecodeFailure()
+}
+
+// DuplicateRecorder is an optional interface implemented by connections that count messages consumers discarded as duplicates
+type DuplicateRecorder interface {
+	RecordDuplicate()
+}
+
+// StatsOf returns the stats of a connection if it implements StatsReporter
+func StatsOf(c Connection) (Stats, bool) {
+	r, ok := c.(StatsReporter)
+	if !ok {
+		return Stats{}, false
+	}
+	return r.Stats(), true
+}
+
+// Counters records connection activity. It is safe for concurrent use.
+type Counters struct {
+	messagesRead    atomic.Uint64
+	messagesWritten atomic.Uint64
+	bytesRead       atomic.Uint64
+	bytesWritten    atomic.Uint64
+	decodeFailures  atomic.Uint64
+	duplicates      atomic.Uint64
+	reconnects      atomic.Uint64
+	lastMessage     atomic.Int64
+	state           atomic.Value
+}
+
+// NewCounters creates counters in the given state
+func NewCounters(state State) *Counters {
+	c := &Counters{}
+	c.SetState(state)
+	return c
+}
+
+// RecordRead records a message of n bytes read from the connection
+func (c *Counters) RecordRead(n int) {
+	c.messagesRead.Add(1)
+	c.bytesRead.Add(uint64(n))
+	c.lastMessage.Store(time.Now().UnixNano())
+}
+
+// RecordWrite records a message of n bytes written to the connection
+func (c *Counters) RecordWrite(n int) {
+	c.messagesWritten.Add(1)
+	c.bytesWritten.Add(uint64(n))
+}
+
+// RecordDecodeFailure records a message that could not be decoded
+func (c *Counters) RecordDecodeFailure() {
+	c.decodeFailures.Add(1)
+}
+
+// RecordDuplicate records a message that was discarded as a duplicate
+func (c *Counters) RecordDuplicate() {
+	c.duplicates.Add(1)
+}
+
+// RecordReconnect records a reconnect of the underlying transport
+func (c *Counters) RecordReconnect() {
+	c.reconnects.Add(1)
+}
+
+// SetState sets the current connection state
+func (c *Counters) SetState(s State) {
+	c.state.Store(s)
+}
+
+// Stats returns a snapshot of the recorded activity
+func (c *Counters) Stats() Stats {
+	s := Stats{
+		MessagesRead:    c.messagesRead.Load(),
+		MessagesWritten: c.messagesWritten.Load(),
+		BytesRead:       c.bytesRead.Load(),
+		BytesWritten:    c.bytesWritten.Load(),
+		DecodeFailures:  c.decodeFailures.Load(),
+		Duplicates:      c.duplicates.Load(),
+		Reconnects:      c.reconnects.Load(),
+	}
+
+	if state, ok := c.state.Load().(State); ok {
+		s.State = state
+	}
+
+	if last := c.lastMessage.Load(); last != 0 {
+		s.LastMessage = time.Unix(0, last)
+	}
+
+	return s
+}
@@ -0,0 +1,64 @@
+package connection
+
+import "testing"
+
+func TestCounters(t *testing.T) {
+	c := NewCounters(StateOpen)
+	c.RecordRead(10)
+	c.RecordRead(5)
+	c.RecordWrite(7)
+	c.RecordDecodeFailure()
+	c.RecordDuplicate()
+	c.RecordReconnect()
+
+	got := c.Stats()
+	if got.MessagesRead != 2 || got.BytesRead != 15 {
+		t.Errorf("read = %d messages %d bytes, want 2 messages 15 bytes", got.MessagesRead, got.BytesRead)
+	}
+	if got.MessagesWritten != 1 || got.BytesWritten != 7 {
+		t.Errorf("written = %d messages %d bytes, want 1 message 7 bytes", got.MessagesWritten, got.BytesWritten)
+	}
+	if got.DecodeFailures != 1 {
+		t.Errorf("decode failures = %d, want 1", got.DecodeFailures)
+	}
+	if got.Duplicates != 1 {
+		t.Errorf("duplicates = %d, want 1", got.Duplicates)
+	}
+	if got.Reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", got.Reconnects)
+	}
+	if got.LastMessage.IsZero() {
+		t.Error("last message should be set after a read")
+	}
+	if got.State != StateOpen {
+		t.Errorf("state = %s, want %s", got.State, StateOpen)
+	}
+
+	c.SetState(StateClosed)
+	if got := c.Stats().State; got != StateClosed {
+		t.Errorf("state = %s, want %s", got, StateClosed)
+	}
+}
+
+func TestStatsOf(t *testing.T) {
+	conn, err := NewUDP(t.Context(), "localhost:8080")
+	if err != nil {
+		t.Fatalf("failed to create UDP connection: %v", err)
+	}
+	defer conn.Close(t.Context())
+
+	if err := conn.Write(t.Context(), map[string]string{"test": "data"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats, ok := StatsOf(conn)
+	if !ok {
+		t.Fatal("expected UDP connection to report stats")
+	}
+	if stats.MessagesWritten != 1 {
+		t.Errorf("messages written = %d, want 1", stats.MessagesWritten)
+	}
+	if stats.State != StateOpen {
+		t.Errorf("state = %s, want %s", stats.State, StateOpen)
+	}
+}
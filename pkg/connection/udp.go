@@ -10,8 +10,9 @@ import (
 )
 
 type UDP struct {
-	conn *net.UDPConn
-	addr *net.UDPAddr
+	conn  *net.UDPConn
+	addr  *net.UDPAddr
+	stats *Counters
 }
 
 // NewUDP dials a new udp connection
@@ -27,8 +28,9 @@ func NewUDP(ctx context.Context, uri string) (Connection, error) {
 	}
 
 	return &UDP{
-		addr: addr,
-		conn: c,
+		addr:  addr,
+		conn:  c,
+		stats: NewCounters(StateOpen),
 	}, nil
 }
 
@@ -44,8 +46,13 @@ func (u *UDP) Write(ctx context.Context, data any) error {
 			return err
 		}
 
-		_, err := u.conn.Write(buf.Bytes())
-		return err
+		n, err := u.conn.Write(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		u.stats.RecordWrite(n)
+		return nil
 	}
 }
 
@@ -60,6 +67,7 @@ func (u *UDP) Read(ctx context.Context) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
+		u.stats.RecordRead(n)
 		return buffer[:n], nil
 	}
 }
@@ -70,6 +78,22 @@ func (u *UDP) Close(ctx context.Context, _ ...websocket.StatusCode) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
+		u.stats.SetState(StateClosed)
 		return u.conn.Close()
 	}
 }
+
+// Stats returns the activity of the udp connection
+func (u *UDP) Stats() Stats {
+	return u.stats.Stats()
+}
+
+// RecordDecodeFailure counts a datagram that could not be decoded
+func (u *UDP) RecordDecodeFailure() {
+	u.stats.RecordDecodeFailure()
+}
+
+// RecordDuplicate counts a datagram that was discarded as a duplicate, such as a retransmission
+func (u *UDP) RecordDuplicate() {
+	u.stats.RecordDuplicate()
+}
@@ -3,55 +3,172 @@ package connection
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/coder/websocket"
 )
 
 // Websocket satisfies the connection interface for a websocket connection
 type Websocket struct {
-	conn *websocket.Conn
+	addr      string
+	opts      *websocket.DialOptions
+	reconnect Reconnect
+	stats     *Counters
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	closed bool
+	// written are the messages written so far, replayed after a reconnect so the new session listens to the same devices
+	written [][]byte
 }
 
-// NewWebsocket dials a new websocket connection. Opts can be nil.
+// NewWebsocket dials a new websocket connection that does not reconnect. Opts can be nil.
 func NewWebsocket(ctx context.Context, addr string, opts *websocket.DialOptions) (Connection, error) {
+	return NewReconnectingWebsocket(ctx, addr, opts, Reconnect{})
+}
+
+// NewReconnectingWebsocket dials a new websocket connection. When a read fails the connection is redialed as configured
+// by reconnect, and the messages written so far, such as listen requests, are written again. Opts can be nil.
+func NewReconnectingWebsocket(ctx context.Context, addr string, opts *websocket.DialOptions, reconnect Reconnect) (Connection, error) {
 	c, _, err := websocket.Dial(ctx, addr, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Websocket{
-		conn: c,
+		addr:      addr,
+		opts:      opts,
+		reconnect: reconnect,
+		conn:      c,
+		stats:     NewCounters(StateOpen),
 	}, nil
 }
 
+func (w *Websocket) current() *websocket.Conn {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn
+}
+
 // Write writes a new message to the websocket connection
-func (w Websocket) Write(ctx context.Context, data any) error {
+func (w *Websocket) Write(ctx context.Context, data any) error {
 	b, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	return w.conn.Write(ctx, websocket.MessageText, b)
+	if err := w.current().Write(ctx, websocket.MessageText, b); err != nil {
+		return err
+	}
+
+	if w.reconnect.enabled() {
+		w.mu.Lock()
+		w.written = append(w.written, b)
+		w.mu.Unlock()
+	}
+
+	w.stats.RecordWrite(len(b))
+	return nil
 }
 
-// Read reads from the websocket connection
-func (w Websocket) Read(ctx context.Context) ([]byte, error) {
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
-		_, b, err := w.conn.Read(ctx)
-		if err != nil {
+// Read reads from the websocket connection, reconnecting when the read fails and reconnects are enabled
+func (w *Websocket) Read(ctx context.Context) ([]byte, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		conn := w.current()
+		_, b, err := conn.Read(ctx)
+		if err == nil {
+			w.stats.RecordRead(len(b))
+			return b, nil
+		}
+
+		if ctx.Err() != nil || !w.reconnect.enabled() || w.isClosed() {
 			return nil, err
 		}
-		return b, nil
+		if rerr := w.redial(ctx, conn); rerr != nil {
+			return nil, fmt.Errorf("reconnecting after %v: %w", err, rerr)
+		}
 	}
 }
 
+func (w *Websocket) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// redial replaces a failed connection, writing the messages written so far to the new one
+func (w *Websocket) redial(ctx context.Context, failed *websocket.Conn) error {
+	failed.CloseNow()
+	w.stats.SetState(StateConnecting)
+
+	err := w.reconnect.retry(ctx, func() error {
+		c, _, err := websocket.Dial(ctx, w.addr, w.opts)
+		if err != nil {
+			return err
+		}
+
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			c.CloseNow()
+			return errors.New("websocket closed while reconnecting")
+		}
+		w.conn = c
+		written := w.written
+		w.mu.Unlock()
+
+		for _, b := range written {
+			if err := c.Write(ctx, websocket.MessageText, b); err != nil {
+				c.CloseNow()
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		w.stats.SetState(StateClosed)
+		return err
+	}
+
+	w.stats.RecordReconnect()
+	w.stats.SetState(StateOpen)
+	return nil
+}
+
 // Close closes the websocket connection with status normal closure
-func (w Websocket) Close(ctx context.Context, status ...websocket.StatusCode) error {
+func (w *Websocket) Close(ctx context.Context, status ...websocket.StatusCode) error {
+	w.stats.SetState(StateClosed)
+
+	w.mu.Lock()
+	w.closed = true
+	conn := w.conn
+	w.mu.Unlock()
+
 	if len(status) != 0 {
-		return w.conn.Close(status[0], "")
+		return conn.Close(status[0], "")
 	}
 
-	return w.conn.Close(websocket.StatusNormalClosure, "")
+	return conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// Stats returns the activity of the websocket connection
+func (w *Websocket) Stats() Stats {
+	return w.stats.Stats()
+}
+
+// RecordDecodeFailure counts a message read from the websocket that could not be decoded
+func (w *Websocket) RecordDecodeFailure() {
+	w.stats.RecordDecodeFailure()
+}
+
+// RecordDuplicate counts a message read from the websocket that was discarded as a duplicate
+func (w *Websocket) RecordDuplicate() {
+	w.stats.RecordDuplicate()
 }
@@ -2,7 +2,12 @@ package connection
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/coder/websocket"
 )
@@ -42,3 +47,84 @@ func TestNewWebsocket(t *testing.T) {
 		})
 	}
 }
+
+func TestWebsocketReconnect(t *testing.T) {
+	var sessions atomic.Int32
+	requests := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+
+		session := sessions.Add(1)
+		_, b, err := c.Read(r.Context())
+		if err != nil {
+			return
+		}
+		requests <- string(b)
+
+		c.Write(r.Context(), websocket.MessageText, []byte(`{"type":"ack"}`))
+		if session == 1 {
+			// drops the first session without a close handshake
+			return
+		}
+		c.Read(r.Context())
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addr := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, err := NewReconnectingWebsocket(ctx, addr, nil, Reconnect{Attempts: 3, Backoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewReconnectingWebsocket() error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := conn.Write(ctx, map[string]string{"type": "listen_start"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	for i := range 2 {
+		if _, err := conn.Read(ctx); err != nil {
+			t.Fatalf("Read() %d error = %v", i, err)
+		}
+		if got := <-requests; got != `{"type":"listen_start"}` {
+			t.Errorf("session %d request = %s, want the listen request", i+1, got)
+		}
+	}
+
+	stats := conn.(StatsReporter).Stats()
+	if stats.Reconnects != 1 || stats.State != StateOpen {
+		t.Errorf("stats = %+v, want 1 reconnect and open", stats)
+	}
+}
+
+func TestWebsocketReconnectGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.CloseNow()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := NewReconnectingWebsocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil, Reconnect{Attempts: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewReconnectingWebsocket() error = %v", err)
+	}
+	srv.Close()
+
+	if _, err := conn.Read(ctx); err == nil || ctx.Err() != nil {
+		t.Errorf("Read() error = %v, want an error once the attempts are used up", err)
+	}
+	if stats := conn.(StatsReporter).Stats(); stats.Reconnects != 0 || stats.State != StateClosed {
+		t.Errorf("stats = %+v, want no reconnects and closed", stats)
+	}
+}
@@ -0,0 +1,137 @@
+package degreedays
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Method is how growing degree days are computed from a day's high and low
+type Method string
+
+const (
+	// Average counts how far the mean of the high and low is above the base
+	Average Method = "average"
+	// Capped raises the high and low to the base and caps them at the upper threshold before averaging them, the method of
+	// corn growing degree days
+	Capped Method = "capped"
+	// Sine fits a sine curve through the low and the high and counts its area between the base and the upper threshold,
+	// the single sine method with a horizontal cutoff
+	Sine Method = "sine"
+)
+
+// ParseMethod parses a method name
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(s); m {
+	case Average, Capped, Sine:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown method %q: expected average, capped or sine", s)
+}
+
+// Unit is the temperature scale of base temperatures and degree days
+type Unit string
+
+const (
+	Fahrenheit Unit = "f"
+	Celsius    Unit = "c"
+)
+
+// Config is how degree days are computed. Base temperatures, highs and lows and degree days are in Unit.
+type Config struct {
+	Unit        Unit    `json:"unit"`
+	HeatingBase float64 `json:"heating_base"`
+	CoolingBase float64 `json:"cooling_base"`
+	GrowingBase float64 `json:"growing_base"`
+	// Upper is the temperature growing stops increasing at, used by the capped and sine methods
+	Upper  float64 `json:"upper"`
+	Method Method  `json:"method"`
+	// SeasonMonth and SeasonDay are the date seasons start on each year
+	SeasonMonth time.Month `json:"season_month"`
+	SeasonDay   int        `json:"season_day"`
+}
+
+// DefaultConfig counts heating and cooling degree days below and above 65°F, and growing degree days between 50°F and 86°F
+// by the simple average, over seasons starting on January 1st. In celsius the bases are 18°C, 10°C and 30°C.
+func DefaultConfig(u Unit) Config {
+	c := Config{Unit: Fahrenheit, HeatingBase: 65, CoolingBase: 65, GrowingBase: 50, Upper: 86, Method: Average, SeasonMonth: time.January, SeasonDay: 1}
+	if u == Celsius {
+		c.Unit, c.HeatingBase, c.CoolingBase, c.GrowingBase, c.Upper = Celsius, 18, 18, 10, 30
+	}
+	return c
+}
+
+// ParseConfig parses a comma separated list of name=value pairs, such as "growing=10,upper=30,method=sine,season=04-01,unit=c",
+// over the default config of its unit. The names are unit (f or c), heating, cooling and growing for the base temperatures,
+// upper, method (average, capped or sine) and season, the month and day seasons start on.
+func ParseConfig(s string) (Config, error) {
+	settings := make(map[string]string)
+	for _, setting := range strings.Split(s, ",") {
+		if strings.TrimSpace(setting) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid degree day setting %q: expected name=value", setting)
+		}
+		settings[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	unit := Unit(strings.ToLower(settings["unit"]))
+	switch unit {
+	case "":
+		unit = Fahrenheit
+	case Fahrenheit, Celsius:
+	default:
+		return Config{}, fmt.Errorf("unknown unit %q: expected f or c", settings["unit"])
+	}
+	delete(settings, "unit")
+
+	c := DefaultConfig(unit)
+	bases := map[string]*float64{"heating": &c.HeatingBase, "cooling": &c.CoolingBase, "growing": &c.GrowingBase, "upper": &c.Upper}
+	for name, value := range settings {
+		var err error
+		switch name {
+		case "method":
+			c.Method, err = ParseMethod(value)
+		case "season":
+			var t time.Time
+			if t, err = time.Parse("01-02", value); err != nil {
+				err = fmt.Errorf("invalid season start %q: expected MM-DD", value)
+			}
+			c.SeasonMonth, c.SeasonDay = t.Month(), t.Day()
+		default:
+			base, ok := bases[name]
+			if !ok {
+				return Config{}, fmt.Errorf("unknown degree day setting %q: expected unit, heating, cooling, growing, upper, method or season", name)
+			}
+			if *base, err = strconv.ParseFloat(value, 64); err != nil {
+				err = fmt.Errorf("invalid %s temperature %q", name, value)
+			}
+		}
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	if c.Upper <= c.GrowingBase {
+		return Config{}, fmt.Errorf("upper threshold %g is not above the growing base %g", c.Upper, c.GrowingBase)
+	}
+	return c, nil
+}
+
+// String formats the config the way ParseConfig reads it
+func (c Config) String() string {
+	return fmt.Sprintf("unit=%s,heating=%g,cooling=%g,growing=%g,upper=%g,method=%s,season=%02d-%02d",
+		c.Unit, c.HeatingBase, c.CoolingBase, c.GrowingBase, c.Upper, c.Method, c.SeasonMonth, c.SeasonDay)
+}
+
+// SeasonStart returns the start of the season containing t, in the time zone of t
+func (c Config) SeasonStart(t time.Time) time.Time {
+	start := time.Date(t.Year(), c.SeasonMonth, c.SeasonDay, 0, 0, 0, 0, t.Location())
+	if start.After(t) {
+		start = time.Date(t.Year()-1, c.SeasonMonth, c.SeasonDay, 0, 0, 0, 0, t.Location())
+	}
+	return start
+}
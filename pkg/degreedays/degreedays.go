@@ -0,0 +1,190 @@
+// Package degreedays computes heating, cooling and growing degree days per station-local day from the high and low
+// temperatures of the day rollups, and totals them over seasons.
+package degreedays
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+// Heating returns the heating degree days of a day, how far the mean of its high and low is below the base
+func Heating(high, low, base float64) float64 {
+	return max(0, base-(high+low)/2)
+}
+
+// Cooling returns the cooling degree days of a day, how far the mean of its high and low is above the base
+func Cooling(high, low, base float64) float64 {
+	return max(0, (high+low)/2-base)
+}
+
+// Growing returns the growing degree days of a day by a method, with growth starting at the base and, for the capped and
+// sine methods, no longer increasing above the upper threshold
+func Growing(high, low, base, upper float64, m Method) float64 {
+	switch m {
+	case Capped:
+		high, low = min(max(high, base), upper), min(max(low, base), upper)
+		return (high+low)/2 - base
+	case Sine:
+		return sine(high, low, base, upper)
+	default:
+		return Cooling(high, low, base)
+	}
+}
+
+// sine integrates a sine curve running from the low to the high and back over a day, above the base and cut off
+// horizontally at the upper threshold
+func sine(high, low, base, upper float64) float64 {
+	switch {
+	case high <= base:
+		return 0
+	case low >= upper:
+		return upper - base
+	}
+
+	mean, amplitude := (high+low)/2, (high-low)/2
+	// the phases the curve crosses the base and the upper threshold at, at its ends when it stays between them
+	from, to := -math.Pi/2, math.Pi/2
+	if low < base {
+		from = math.Asin((base - mean) / amplitude)
+	}
+	if high > upper {
+		to = math.Asin((upper - mean) / amplitude)
+	}
+
+	return ((mean-base)*(to-from) + amplitude*(math.Cos(from)-math.Cos(to)) + (upper-base)*(math.Pi/2-to)) / math.Pi
+}
+
+// Day is the degree days of a device on a station-local day, from its high and low temperature
+type Day struct {
+	Device       int       `json:"device_id"`
+	Date         time.Time `json:"date"`
+	Observations int       `json:"observations"`
+	High         float64   `json:"high"`
+	Low          float64   `json:"low"`
+	Heating      float64   `json:"heating"`
+	Cooling      float64   `json:"cooling"`
+	Growing      float64   `json:"growing"`
+}
+
+// Total is the degree days of a device summed over the days with observations from Start up to End
+type Total struct {
+	Device  int       `json:"device_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Days    int       `json:"days"`
+	Heating float64   `json:"heating"`
+	Cooling float64   `json:"cooling"`
+	Growing float64   `json:"growing"`
+}
+
+// Summary is the degree days of a device on a day and over its season to date
+type Summary struct {
+	Config Config `json:"config"`
+	Day    Day    `json:"day"`
+	Season Total  `json:"season"`
+}
+
+// Calculator computes the degree days of the day rollups of an aggregator, in its time zone
+type Calculator struct {
+	rollups *rollup.Aggregator
+	config  Config
+}
+
+// New creates a calculator of the degree days of the day rollups of an aggregator
+func New(rollups *rollup.Aggregator, c Config) *Calculator {
+	return &Calculator{rollups: rollups, config: c}
+}
+
+// Config returns how the calculator computes degree days
+func (c *Calculator) Config() Config {
+	return c.config
+}
+
+// SeasonStart returns the start of the season containing t in the station's time zone
+func (c *Calculator) SeasonStart(t time.Time) time.Time {
+	return c.config.SeasonStart(t.In(c.rollups.Location()))
+}
+
+// Days returns the degree days of each day of a device with observations starting between start and end
+func (c *Calculator) Days(ctx context.Context, device int, start, end time.Time) ([]Day, error) {
+	buckets, err := c.rollups.Query(ctx, rollup.Query{Device: device, Resolution: rollup.Day, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]Day, 0, len(buckets))
+	for _, b := range buckets {
+		if b.AirTemperature.Count == 0 {
+			continue
+		}
+		days = append(days, c.day(b))
+	}
+	return days, nil
+}
+
+// Total returns the degree days of a device summed over the days starting between start and end
+func (c *Calculator) Total(ctx context.Context, device int, start, end time.Time) (Total, error) {
+	total := Total{Device: device, Start: start, End: end}
+
+	days, err := c.Days(ctx, device, start, end)
+	if err != nil {
+		return total, err
+	}
+	for _, d := range days {
+		total.Days++
+		total.Heating += d.Heating
+		total.Cooling += d.Cooling
+		total.Growing += d.Growing
+	}
+	return total, nil
+}
+
+// Summary returns the degree days of a device on the day containing t and over its season through that day
+func (c *Calculator) Summary(ctx context.Context, device int, t time.Time) (Summary, error) {
+	start := rollup.Day.Start(t, c.rollups.Location())
+	end := rollup.Day.End(start)
+	s := Summary{Config: c.config, Day: Day{Device: device, Date: start}}
+
+	season, err := c.Total(ctx, device, c.SeasonStart(t), end)
+	if err != nil {
+		return s, err
+	}
+	s.Season = season
+
+	days, err := c.Days(ctx, device, start, end)
+	if err != nil {
+		return s, err
+	}
+	if len(days) > 0 {
+		s.Day = days[0]
+	}
+	return s, nil
+}
+
+// Devices returns the devices with rollups in memory
+func (c *Calculator) Devices() []int {
+	return c.rollups.Devices()
+}
+
+// day computes the degree days of a day bucket
+func (c *Calculator) day(b rollup.Bucket) Day {
+	high, low := b.AirTemperature.Max, b.AirTemperature.Min
+	if c.config.Unit != Celsius {
+		high, low = api.CelsiusToFahrenheit(high), api.CelsiusToFahrenheit(low)
+	}
+
+	return Day{
+		Device:       b.Device,
+		Date:         b.Start,
+		Observations: b.Observations,
+		High:         high,
+		Low:          low,
+		Heating:      Heating(high, low, c.config.HeatingBase),
+		Cooling:      Cooling(high, low, c.config.CoolingBase),
+		Growing:      Growing(high, low, c.config.GrowingBase, c.config.Upper, c.config.Method),
+	}
+}
@@ -0,0 +1,118 @@
+package degreedays
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+func observation(t time.Time, data api.ObservationTempestData) api.ObservationTempest {
+	data.TimeEpoch = int(t.Unix())
+	return api.ObservationTempest{Type: "obs_st", Device: 1, Data: data}
+}
+
+func TestGrowing(t *testing.T) {
+	tests := []struct {
+		name      string
+		high, low float64
+		method    Method
+		want      float64
+	}{
+		{name: "average", high: 80, low: 40, method: Average, want: 10},
+		{name: "average below the base", high: 55, low: 35, method: Average, want: 0},
+		{name: "capped raises the low to the base", high: 80, low: 40, method: Capped, want: 15},
+		{name: "capped caps the high", high: 95, low: 60, method: Capped, want: 23},
+		{name: "capped below the base", high: 45, low: 30, method: Capped, want: 0},
+		{name: "sine crossing the base", high: 80, low: 40, method: Sine, want: 12.18},
+		{name: "sine crossing the upper threshold", high: 95, low: 60, method: Sine, want: 25.5086},
+		{name: "sine crossing both", high: 100, low: 40, method: Sine, want: 18.8204},
+		{name: "sine between them", high: 70, low: 60, method: Sine, want: 15},
+		{name: "sine below the base", high: 45, low: 30, method: Sine, want: 0},
+		{name: "sine above the upper threshold", high: 100, low: 90, method: Sine, want: 36},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Growing(tt.high, tt.low, 50, 86, tt.method); math.Abs(got-tt.want) > 1e-4 {
+				t.Errorf("Growing() = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", s: "", want: "unit=f,heating=65,cooling=65,growing=50,upper=86,method=average,season=01-01"},
+		{name: "overrides", s: "method=sine, season=04-15,heating=60", want: "unit=f,heating=60,cooling=65,growing=50,upper=86,method=sine,season=04-15"},
+		{name: "celsius defaults", s: "unit=c,growing=8", want: "unit=c,heating=18,cooling=18,growing=8,upper=30,method=average,season=01-01"},
+		{name: "unknown setting", s: "base=50", wantErr: true},
+		{name: "unknown method", s: "method=double", wantErr: true},
+		{name: "invalid season", s: "season=13-01", wantErr: true},
+		{name: "upper below the base", s: "growing=90", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseConfig() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	rollups := rollup.New(rollup.WithLocation(time.UTC))
+	config, _ := ParseConfig("unit=c,season=03-01")
+	c := New(rollups, config)
+
+	// a day before the season, two in it with a high of 20°C and a low of 10°C, and today at a steady 25°C
+	for _, d := range []struct {
+		date  time.Time
+		temps []float64
+	}{
+		{date: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), temps: []float64{0, 10}},
+		{date: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), temps: []float64{10, 20}},
+		{date: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), temps: []float64{10, 20}},
+		{date: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), temps: []float64{25}},
+	} {
+		for i, temp := range d.temps {
+			obs := observation(d.date.Add(time.Duration(i+6)*time.Hour), api.ObservationTempestData{AirTemperature: temp})
+			if err := rollups.Add(ctx, obs); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+		}
+	}
+
+	s, err := c.Summary(ctx, 1, time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if d := s.Day; d.High != 25 || d.Heating != 0 || d.Cooling != 7 || d.Growing != 15 {
+		t.Errorf("today = %+v, want 7 cooling and 15 growing degree days", d)
+	}
+	if got := s.Season; got.Days != 3 || got.Heating != 6 || got.Cooling != 7 || got.Growing != 25 {
+		t.Errorf("season = %+v, want 3 days with 6 heating, 7 cooling and 25 growing degree days", got)
+	}
+	if want := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC); !s.Season.Start.Equal(want) {
+		t.Errorf("season start = %s, want %s", s.Season.Start, want)
+	}
+
+	// the season before starts on march 1st of the previous year
+	if got := c.SeasonStart(time.Date(2025, 2, 28, 12, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("SeasonStart() = %s", got)
+	}
+}
@@ -0,0 +1,293 @@
+package presence
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// State describes whether a device or station is reporting
+type State string
+
+const (
+	StateOnline  State = "online"
+	StateStale   State = "stale"
+	StateOffline State = "offline"
+)
+
+const (
+	defaultReportInterval = time.Minute
+	defaultCheckInterval  = 10 * time.Second
+	defaultStaleAfter     = 2
+	defaultOfflineAfter   = 5
+)
+
+// Status is the presence of a single device or station
+type Status struct {
+	Device         int           `json:"device_id,omitempty"`
+	Station        int           `json:"station_id,omitempty"`
+	State          State         `json:"state"`
+	Since          time.Time     `json:"since"`
+	LastSeen       time.Time     `json:"last_seen"`
+	ReportInterval time.Duration `json:"report_interval"`
+}
+
+// Tracker follows the online and offline events of devices and stations, and watches that each device reports within its report interval.
+// When a device's data is overdue it injects a synthetic evt_device_stale event, and later a synthetic evt_device_offline event, into the listener.
+type Tracker struct {
+	listener       tempest.Listener
+	mu             sync.RWMutex
+	devices        map[int]*Status
+	stations       map[int]*Status
+	reportInterval time.Duration
+	checkInterval  time.Duration
+	staleAfter     int
+	offlineAfter   int
+	now            func() time.Time
+}
+
+// Option configures optional behavior of a Tracker
+type Option func(*Tracker)
+
+// WithReportInterval sets the report interval assumed for a device until it reports its own. Defaults to a minute.
+func WithReportInterval(d time.Duration) Option {
+	return func(t *Tracker) {
+		if d > 0 {
+			t.reportInterval = d
+		}
+	}
+}
+
+// WithCheckInterval sets how often Run checks for overdue devices. Defaults to 10 seconds.
+func WithCheckInterval(d time.Duration) Option {
+	return func(t *Tracker) {
+		if d > 0 {
+			t.checkInterval = d
+		}
+	}
+}
+
+// WithMissedReports sets how many report intervals a device can miss before it is stale, and before it is offline. Defaults to 2 and 5.
+func WithMissedReports(stale, offline int) Option {
+	return func(t *Tracker) {
+		if stale > 0 && offline >= stale {
+			t.staleAfter = stale
+			t.offlineAfter = offline
+		}
+	}
+}
+
+// New creates a tracker and registers its handlers on the listener
+func New(l tempest.Listener, opts ...Option) *Tracker {
+	t := &Tracker{
+		listener:       l,
+		devices:        make(map[int]*Status),
+		stations:       make(map[int]*Status),
+		reportInterval: defaultReportInterval,
+		checkInterval:  defaultCheckInterval,
+		staleAfter:     defaultStaleAfter,
+		offlineAfter:   defaultOfflineAfter,
+		now:            time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	l.AddHandler(tempest.EventAll, t.handleMessage)
+	tempest.On(l, t.handleObservation)
+	tempest.On(l, t.handleDeviceOnline)
+	tempest.On(l, t.handleDeviceOffline)
+	tempest.On(l, t.handleStationOnline)
+	tempest.On(l, t.handleStationOffline)
+
+	return t
+}
+
+// Run checks for overdue devices until the context is cancelled
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			t.check(ctx)
+		}
+	}
+}
+
+// Devices returns the status of every device seen, ordered by device id
+func (t *Tracker) Devices() []Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return sorted(t.devices)
+}
+
+// Stations returns the status of every station seen, ordered by station id
+func (t *Tracker) Stations() []Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return sorted(t.stations)
+}
+
+// Device returns the status of a device
+func (t *Tracker) Device(device int) (Status, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s, ok := t.devices[device]
+	if !ok {
+		return Status{}, false
+	}
+	return *s, true
+}
+
+// handleMessage marks the device of any message as seen, other than the synthetic events raised by the tracker and backfilled observations
+func (t *Tracker) handleMessage(ctx context.Context, b []byte) {
+	e, _ := tempest.EventFromContext(ctx)
+	switch e {
+	case tempest.EventDeviceStale, tempest.EventDeviceOffline, tempest.EventDeviceOnline:
+		return
+	}
+
+	o, err := tempest.Decode[api.Observation](ctx, b)
+	if err != nil || o.Device == 0 || o.Source == api.SourceBackfill {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.device(o.Device)
+	s.LastSeen = t.now()
+	t.transition(s, StateOnline)
+}
+
+func (t *Tracker) handleObservation(ctx context.Context, obs api.ObservationTempest) {
+	if obs.Data.ReportInterval <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.device(obs.Device).ReportInterval = time.Duration(obs.Data.ReportInterval) * time.Minute
+}
+
+func (t *Tracker) handleDeviceOnline(ctx context.Context, evt api.DeviceOnline) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.device(evt.Device)
+	s.LastSeen = t.now()
+	t.transition(s, StateOnline)
+}
+
+func (t *Tracker) handleDeviceOffline(ctx context.Context, evt api.DeviceOffline) {
+	if evt.Synthetic {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.transition(t.device(evt.Device), StateOffline)
+}
+
+func (t *Tracker) handleStationOnline(ctx context.Context, evt api.StationOnline) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.station(evt.Station)
+	s.LastSeen = t.now()
+	t.transition(s, StateOnline)
+}
+
+func (t *Tracker) handleStationOffline(ctx context.Context, evt api.StationOffline) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.transition(t.station(evt.Station), StateOffline)
+}
+
+// check moves overdue devices to stale or offline and injects an event for each change
+func (t *Tracker) check(ctx context.Context) {
+	now := t.now()
+
+	var events []api.Event
+	t.mu.Lock()
+	for _, s := range t.devices {
+		if s.LastSeen.IsZero() {
+			continue
+		}
+
+		overdue := now.Sub(s.LastSeen)
+		switch {
+		case s.State != StateOffline && overdue > time.Duration(t.offlineAfter)*s.ReportInterval:
+			t.transition(s, StateOffline)
+			events = append(events, api.DeviceOffline{Type: string(tempest.EventDeviceOffline), Device: s.Device, Synthetic: true})
+		case s.State == StateOnline && overdue > time.Duration(t.staleAfter)*s.ReportInterval:
+			t.transition(s, StateStale)
+			events = append(events, api.DeviceStale{Type: string(tempest.EventDeviceStale), Device: s.Device, LastSeenEpoch: s.LastSeen.Unix()})
+		}
+	}
+	t.mu.Unlock()
+
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("presence: encoding %s: %v", e.EventType(), err)
+			continue
+		}
+		if err := t.listener.Inject(ctx, b); err != nil {
+			log.Printf("presence: injecting %s: %v", e.EventType(), err)
+		}
+	}
+}
+
+func (t *Tracker) device(device int) *Status {
+	s, ok := t.devices[device]
+	if !ok {
+		s = &Status{Device: device, State: StateOnline, Since: t.now(), ReportInterval: t.reportInterval}
+		t.devices[device] = s
+	}
+	return s
+}
+
+func (t *Tracker) station(station int) *Status {
+	s, ok := t.stations[station]
+	if !ok {
+		s = &Status{Station: station, State: StateOnline, Since: t.now(), ReportInterval: t.reportInterval}
+		t.stations[station] = s
+	}
+	return s
+}
+
+func (t *Tracker) transition(s *Status, state State) {
+	if s.State == state {
+		return
+	}
+	s.State = state
+	s.Since = t.now()
+}
+
+func sorted(m map[int]*Status) []Status {
+	statuses := make([]Status, 0, len(m))
+	for _, s := range m {
+		statuses = append(statuses, *s)
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Compare(a.Device+a.Station, b.Device+b.Station)
+	})
+	return statuses
+}
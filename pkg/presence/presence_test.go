@@ -0,0 +1,136 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+	"github.com/kdwils/weatherstation/pkg/tempest/tempesttest"
+)
+
+const observation = `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,5,0,0,0,0]]}`
+
+func TestTrackerStaleness(t *testing.T) {
+	conn := tempesttest.NewConn(0)
+	l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1)
+
+	var mu sync.Mutex
+	now := time.Unix(1588948614, 0)
+	tracker := New(l)
+	tracker.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	stale := make(chan api.DeviceStale, 1)
+	offline := make(chan api.DeviceOffline, 1)
+	tempest.On(l, func(ctx context.Context, evt api.DeviceStale) { stale <- evt })
+	tempest.On(l, func(ctx context.Context, evt api.DeviceOffline) { offline <- evt })
+
+	done := make(chan error)
+	go func() { done <- l.Listen(context.Background()) }()
+
+	send(t, conn, observation)
+	waitFor(t, func() bool {
+		s, ok := tracker.Device(1)
+		return ok && s.ReportInterval == 5*time.Minute
+	})
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    State
+	}{
+		{name: "within two intervals", advance: 9 * time.Minute, want: StateOnline},
+		{name: "two intervals missed", advance: 2 * time.Minute, want: StateStale},
+		{name: "five intervals missed", advance: 15 * time.Minute, want: StateOffline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.advance)
+			tracker.check(context.Background())
+
+			s, _ := tracker.Device(1)
+			if s.State != tt.want {
+				t.Errorf("state = %s, want %s", s.State, tt.want)
+			}
+		})
+	}
+
+	select {
+	case evt := <-stale:
+		if evt.Device != 1 || evt.LastSeenEpoch != 1588948614 {
+			t.Errorf("stale event = %+v", evt)
+		}
+	case <-time.After(time.Second):
+		t.Error("no stale event injected")
+	}
+
+	select {
+	case evt := <-offline:
+		if evt.Device != 1 || !evt.Synthetic {
+			t.Errorf("offline event = %+v, want synthetic offline for device 1", evt)
+		}
+	case <-time.After(time.Second):
+		t.Error("no offline event injected")
+	}
+
+	send(t, conn, observation)
+	waitFor(t, func() bool {
+		s, _ := tracker.Device(1)
+		return s.State == StateOnline
+	})
+
+	conn.End()
+	<-done
+}
+
+func TestTrackerServerEvents(t *testing.T) {
+	conn := tempesttest.NewConn(4)
+	l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1)
+	tracker := New(l)
+
+	send(t, conn, `{"type":"evt_device_online","device_id":1}`)
+	send(t, conn, `{"type":"evt_device_offline","device_id":2}`)
+	send(t, conn, `{"type":"evt_station_offline","station_id":3}`)
+	conn.End()
+	l.Listen(context.Background())
+
+	devices := tracker.Devices()
+	if len(devices) != 2 || devices[0].State != StateOnline || devices[1].State != StateOffline {
+		t.Errorf("devices = %+v, want 1 online and 2 offline", devices)
+	}
+
+	stations := tracker.Stations()
+	if len(stations) != 1 || stations[0].Station != 3 || stations[0].State != StateOffline {
+		t.Errorf("stations = %+v, want 3 offline", stations)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func send(t *testing.T, conn *tempesttest.Conn, message string) {
+	t.Helper()
+	if err := conn.Send(context.Background(), message); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+}
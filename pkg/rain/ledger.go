@@ -0,0 +1,393 @@
+// Package rain keeps a ledger of a station's rain: station-local daily, monthly, yearly and water-year totals reconciled with
+// Rain Check, and the rain events detected from rain start events and minute accumulations.
+package rain
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+const (
+	// RecordType is the store record type of rain events
+	RecordType = "rain_event"
+
+	defaultDryGap = 30 * time.Minute
+	// recent is how long events are kept in memory after they end, long enough for backfilled observations to extend them
+	recent = 48 * time.Hour
+)
+
+// Event is a period of rain. It starts with a rain start event or the first minute with rain, and ends once no rain has
+// accumulated for the dry gap. Total is in mm and PeakIntensity, the highest rate of a single observation, in mm per hour.
+type Event struct {
+	Device          int       `json:"device_id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	Total           float64   `json:"total"`
+	PeakIntensity   float64   `json:"peak_intensity"`
+	PeakTime        time.Time `json:"peak_time"`
+	Ongoing         bool      `json:"ongoing"`
+}
+
+// Duration returns how long the event lasted
+func (e Event) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Ledger detects the rain events of each device as observations arrive and totals rain from the day rollups of an aggregator,
+// whose time zone it shares. With a store, events are kept in it and survive restarts.
+type Ledger struct {
+	rollups   *rollup.Aggregator
+	store     *store.Store
+	dryGap    time.Duration
+	waterYear time.Month
+
+	mu      sync.Mutex
+	devices map[int]*events
+}
+
+// events are the recent events of a device in time order
+type events struct {
+	list []*Event
+	// seen are the epochs of the rain observations added to events, so retransmitted ones are not counted twice.
+	// Rain observations up to loaded, inside events loaded from the store, were counted before a restart.
+	seen   map[int64]struct{}
+	loaded int64
+	latest int64
+}
+
+// Option configures a Ledger
+type Option func(*Ledger)
+
+// WithStore keeps rain events in a store
+func WithStore(st *store.Store) Option {
+	return func(l *Ledger) {
+		l.store = st
+	}
+}
+
+// WithDryGap sets how long it has to stay dry for a rain event to end. Defaults to 30 minutes.
+func WithDryGap(d time.Duration) Option {
+	return func(l *Ledger) {
+		if d > 0 {
+			l.dryGap = d
+		}
+	}
+}
+
+// WithWaterYearStart sets the month water years start in. Defaults to October.
+func WithWaterYearStart(m time.Month) Option {
+	return func(l *Ledger) {
+		if m >= time.January && m <= time.December {
+			l.waterYear = m
+		}
+	}
+}
+
+// New creates a ledger totaling the day rollups of an aggregator
+func New(rollups *rollup.Aggregator, opts ...Option) *Ledger {
+	l := &Ledger{
+		rollups:   rollups,
+		dryGap:    defaultDryGap,
+		waterYear: time.October,
+		devices:   make(map[int]*events),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Attach detects rain events from the observations and rain start events a listener handles, and returns a func that stops
+// detecting them. Errors writing to the store are logged.
+func (l *Ledger) Attach(listener tempest.Listener) (remove func()) {
+	removeObservations := tempest.On(listener, func(ctx context.Context, obs api.ObservationTempest) {
+		if err := l.Add(ctx, obs); err != nil {
+			log.Printf("rain: %v", err)
+		}
+	})
+	removePrecipitation := tempest.On(listener, func(ctx context.Context, evt api.PrecipitationEvent) {
+		if err := l.AddPrecipitation(ctx, evt); err != nil {
+			log.Printf("rain: %v", err)
+		}
+	})
+
+	return func() {
+		removeObservations()
+		removePrecipitation()
+	}
+}
+
+// AddPrecipitation starts a rain event at a rain start event, unless it falls in an event already
+func (l *Ledger) AddPrecipitation(ctx context.Context, evt api.PrecipitationEvent) error {
+	epoch := int64(evt.Data.TimeEpoch)
+	if epoch <= 0 {
+		return nil
+	}
+	t := time.Unix(epoch, 0).In(l.rollups.Location())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, err := l.events(ctx, evt.Device, t)
+	if err != nil {
+		return err
+	}
+
+	if l.find(d, t, t) != nil {
+		return nil
+	}
+	e := &Event{Device: evt.Device, Start: t, End: t, Ongoing: true}
+	d.insert(e)
+	return l.persist(ctx, e, time.Time{})
+}
+
+// Add extends the rain event containing an observation with its rain, starting one if there is none, and ends the events
+// that have been dry for the dry gap. Observations already added are skipped.
+func (l *Ledger) Add(ctx context.Context, obs api.ObservationTempest) error {
+	epoch := int64(obs.Data.TimeEpoch)
+	if epoch <= 0 {
+		return nil
+	}
+	t := time.Unix(epoch, 0).In(l.rollups.Location())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, err := l.events(ctx, obs.Device, t)
+	if err != nil {
+		return err
+	}
+	d.latest = max(d.latest, epoch)
+
+	if err := l.end(ctx, d); err != nil {
+		return err
+	}
+
+	if obs.Data.RainAccumulated <= 0 || d.counted(epoch) {
+		return nil
+	}
+	d.seen[epoch] = struct{}{}
+
+	// the rain fell over the report interval before the observation
+	interval := time.Duration(max(obs.Data.ReportInterval, 1)) * time.Minute
+	from := t.Add(-interval)
+	rate := obs.Data.RainAccumulated * float64(time.Hour) / float64(interval)
+
+	e := l.find(d, from, t)
+	if e == nil {
+		e = &Event{Device: obs.Device, Start: from, End: t, Ongoing: true}
+		d.insert(e)
+	}
+	previous := e.Start
+
+	// rain ending after the start fell after it, a rain start event can be part way through the report interval
+	if !t.After(e.Start) {
+		e.Start = from
+	}
+	e.End = maxTime(e.End, t)
+	e.Total += obs.Data.RainAccumulated
+	if rate > e.PeakIntensity {
+		e.PeakIntensity, e.PeakTime = rate, t
+	}
+
+	if err := l.persist(ctx, e, previous); err != nil {
+		return err
+	}
+	if err := l.merge(ctx, d, e); err != nil {
+		return err
+	}
+	return l.end(ctx, d)
+}
+
+// Events returns the rain events of a device starting between start, inclusive, and end, exclusive. A zero start or end is unbounded.
+func (l *Ledger) Events(ctx context.Context, device int, start, end time.Time) ([]Event, error) {
+	found, err := l.stored(ctx, device, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	if d, ok := l.devices[device]; ok {
+		for _, e := range d.list {
+			if (start.IsZero() || !e.Start.Before(start)) && (end.IsZero() || e.Start.Before(end)) {
+				found[e.Start.Unix()] = *e
+			}
+		}
+	}
+	l.mu.Unlock()
+
+	list := slices.Collect(maps.Values(found))
+	slices.SortFunc(list, func(a, b Event) int { return a.Start.Compare(b.Start) })
+	return list, nil
+}
+
+// stored returns the events of a device in the store starting between start and end, by their start
+func (l *Ledger) stored(ctx context.Context, device int, start, end time.Time) (map[int64]Event, error) {
+	found := make(map[int64]Event)
+	if l.store == nil {
+		return found, nil
+	}
+
+	records, err := l.store.Query(ctx, store.Query{Device: device, Types: []string{RecordType}, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	loc := l.rollups.Location()
+	for _, rec := range records {
+		var e Event
+		if err := json.Unmarshal(rec.Data, &e); err != nil {
+			return nil, err
+		}
+		e.Start, e.End, e.PeakTime = e.Start.In(loc), e.End.In(loc), e.PeakTime.In(loc)
+		found[rec.Epoch] = e
+	}
+	return found, nil
+}
+
+// events returns the recent events of a device, loading them from the store the first time the device is seen
+func (l *Ledger) events(ctx context.Context, device int, t time.Time) (*events, error) {
+	if d, ok := l.devices[device]; ok {
+		return d, nil
+	}
+
+	d := &events{seen: make(map[int64]struct{})}
+	loaded, err := l.stored(ctx, device, t.Add(-2*recent), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range loaded {
+		d.insert(&e)
+		d.loaded = max(d.loaded, e.End.Unix())
+	}
+
+	l.devices[device] = d
+	return d, nil
+}
+
+// find returns the event that rain between from and to falls in or within the dry gap of
+func (l *Ledger) find(d *events, from, to time.Time) *Event {
+	for _, e := range d.list {
+		if !to.Before(e.Start.Add(-l.dryGap)) && !from.After(e.End.Add(l.dryGap)) {
+			return e
+		}
+	}
+	return nil
+}
+
+// merge joins the event with the events a backfilled observation has bridged it to
+func (l *Ledger) merge(ctx context.Context, d *events, e *Event) error {
+	for _, other := range slices.Clone(d.list) {
+		if other == e || other.Start.After(e.End.Add(l.dryGap)) || other.End.Before(e.Start.Add(-l.dryGap)) {
+			continue
+		}
+
+		previous := e.Start
+		e.Start, e.End = minTime(e.Start, other.Start), maxTime(e.End, other.End)
+		e.Total += other.Total
+		if other.PeakIntensity > e.PeakIntensity {
+			e.PeakIntensity, e.PeakTime = other.PeakIntensity, other.PeakTime
+		}
+
+		d.list = slices.DeleteFunc(d.list, func(x *Event) bool { return x == other })
+		if err := l.remove(ctx, other); err != nil {
+			return err
+		}
+		if err := l.persist(ctx, e, previous); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// end marks the events that have been dry for the dry gap as over, and forgets those that ended long ago
+func (l *Ledger) end(ctx context.Context, d *events) error {
+	latest := time.Unix(d.latest, 0)
+	for _, e := range d.list {
+		if e.Ongoing && latest.Sub(e.End) > l.dryGap {
+			e.Ongoing = false
+			if err := l.persist(ctx, e, e.Start); err != nil {
+				return err
+			}
+		}
+	}
+
+	cutoff := latest.Add(-recent)
+	d.list = slices.DeleteFunc(d.list, func(e *Event) bool { return e.End.Before(cutoff) })
+	for epoch := range d.seen {
+		if epoch < cutoff.Unix() {
+			delete(d.seen, epoch)
+		}
+	}
+	return nil
+}
+
+// persist writes an event to the store, removing its record from before its start moved
+func (l *Ledger) persist(ctx context.Context, e *Event, previous time.Time) error {
+	e.DurationSeconds = int64(e.Duration() / time.Second)
+	if l.store == nil {
+		return nil
+	}
+
+	if !previous.IsZero() && !previous.Equal(e.Start) {
+		if err := l.remove(ctx, &Event{Device: e.Device, Start: previous}); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return l.store.Append(ctx, store.Record{Type: RecordType, Device: e.Device, Epoch: e.Start.Unix(), Data: data})
+}
+
+func (l *Ledger) remove(ctx context.Context, e *Event) error {
+	if l.store == nil {
+		return nil
+	}
+	_, err := l.store.Delete(ctx, store.Query{Device: e.Device, Types: []string{RecordType}, Start: e.Start, End: e.Start.Add(time.Second)})
+	return err
+}
+
+// insert adds an event in time order
+func (d *events) insert(e *Event) {
+	i, _ := slices.BinarySearchFunc(d.list, e, func(a, b *Event) int { return a.Start.Compare(b.Start) })
+	d.list = slices.Insert(d.list, i, e)
+}
+
+// counted reports whether the rain of an observation is already in an event
+func (d *events) counted(epoch int64) bool {
+	if _, ok := d.seen[epoch]; ok {
+		return true
+	}
+	if epoch > d.loaded {
+		return false
+	}
+	return slices.ContainsFunc(d.list, func(e *Event) bool { return epoch > e.Start.Unix() && epoch <= e.End.Unix() })
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,175 @@
+package rain
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest/tempesttest"
+)
+
+func observation(t time.Time, data api.ObservationTempestData) api.ObservationTempest {
+	data.TimeEpoch = int(t.Unix())
+	return api.ObservationTempest{Type: "obs_st", Device: 1, Data: data}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(t.TempDir(), store.WithSync(false))
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+	defer st.Close()
+
+	rollups := rollup.New(rollup.WithLocation(time.UTC))
+	l := New(rollups, WithStore(st))
+
+	at := func(hour, minute int) time.Time { return time.Date(2025, 7, 1, hour, minute, 0, 0, time.UTC) }
+
+	l.AddPrecipitation(ctx, tempesttest.RainStart(1, at(10, 0).Add(30*time.Second)))
+	for _, obs := range []api.ObservationTempest{
+		observation(at(10, 1), api.ObservationTempestData{RainAccumulated: 0.2, ReportInterval: 1}),
+		observation(at(10, 2), api.ObservationTempestData{RainAccumulated: 0.5, ReportInterval: 1}),
+		// retransmitted
+		observation(at(10, 2), api.ObservationTempestData{RainAccumulated: 0.5, ReportInterval: 1}),
+		observation(at(10, 3), api.ObservationTempestData{ReportInterval: 1}),
+		observation(at(10, 20), api.ObservationTempestData{RainAccumulated: 0.1, ReportInterval: 1}),
+		observation(at(10, 51), api.ObservationTempestData{ReportInterval: 1}),
+		observation(at(12, 0), api.ObservationTempestData{RainAccumulated: 0.3, ReportInterval: 1}),
+	} {
+		if err := l.Add(ctx, obs); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	events, _ := l.Events(ctx, 1, time.Time{}, time.Time{})
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want 2", events)
+	}
+
+	first := events[0]
+	if !first.Start.Equal(at(10, 0).Add(30*time.Second)) || !first.End.Equal(at(10, 20)) || first.Ongoing {
+		t.Errorf("first event runs %s to %s, ongoing %t", first.Start, first.End, first.Ongoing)
+	}
+	if math.Abs(first.Total-0.8) > 1e-9 || first.PeakIntensity != 30 || !first.PeakTime.Equal(at(10, 2)) {
+		t.Errorf("first event total = %g, peak = %g at %s", first.Total, first.PeakIntensity, first.PeakTime)
+	}
+	if first.DurationSeconds != 1170 {
+		t.Errorf("first event duration = %ds, want 1170s", first.DurationSeconds)
+	}
+	if second := events[1]; !second.Start.Equal(at(11, 59)) || !second.Ongoing {
+		t.Errorf("second event = %+v, want an ongoing event from 11:59", second)
+	}
+
+	// backfilled observations that close the gap join the events
+	l.Add(ctx, observation(at(10, 45), api.ObservationTempestData{RainAccumulated: 0.4, ReportInterval: 1}))
+	l.Add(ctx, observation(at(11, 15), api.ObservationTempestData{RainAccumulated: 0.1, ReportInterval: 1}))
+	l.Add(ctx, observation(at(11, 35), api.ObservationTempestData{RainAccumulated: 0.1, ReportInterval: 1}))
+
+	events, _ = l.Events(ctx, 1, time.Time{}, time.Time{})
+	if len(events) != 1 || math.Abs(events[0].Total-1.7) > 1e-9 || !events[0].End.Equal(at(12, 0)) {
+		t.Errorf("events after backfilling = %+v, want one event of 1.7mm", events)
+	}
+
+	if stored, _ := New(rollups, WithStore(st)).Events(ctx, 1, time.Time{}, time.Time{}); len(stored) != 1 || stored[0].Total != events[0].Total {
+		t.Errorf("stored events = %+v, want the merged event", stored)
+	}
+}
+
+func TestPeakIntensityUnevenInterval(t *testing.T) {
+	ctx := context.Background()
+	l := New(rollup.New(rollup.WithLocation(time.UTC)))
+
+	// 0.7mm over a 7 minute report interval is 6mm an hour, an hour does not divide into 7 minutes evenly
+	at := time.Date(2025, 7, 1, 10, 7, 0, 0, time.UTC)
+	if err := l.Add(ctx, observation(at, api.ObservationTempestData{RainAccumulated: 0.7, ReportInterval: 7})); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	events, _ := l.Events(ctx, 1, time.Time{}, time.Time{})
+	if len(events) != 1 || math.Abs(events[0].PeakIntensity-6) > 1e-9 {
+		t.Errorf("events = %+v, want one event peaking at 6mm an hour", events)
+	}
+}
+
+func TestEventsSurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(t.TempDir(), store.WithSync(false))
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+	defer st.Close()
+
+	start := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	rollups := rollup.New(rollup.WithLocation(time.UTC))
+
+	l := New(rollups, WithStore(st))
+	l.Add(ctx, observation(start, api.ObservationTempestData{RainAccumulated: 1, ReportInterval: 1}))
+	l.Add(ctx, observation(start.Add(time.Minute), api.ObservationTempestData{RainAccumulated: 1, ReportInterval: 1}))
+
+	l = New(rollups, WithStore(st))
+	l.Add(ctx, observation(start.Add(time.Minute), api.ObservationTempestData{RainAccumulated: 1, ReportInterval: 1}))
+	l.Add(ctx, observation(start.Add(2*time.Minute), api.ObservationTempestData{RainAccumulated: 1, ReportInterval: 1}))
+
+	events, _ := l.Events(ctx, 1, time.Time{}, time.Time{})
+	if len(events) != 1 || events[0].Total != 3 {
+		t.Errorf("events after a restart = %+v, want one event of 3mm", events)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	ctx := context.Background()
+	rollups := rollup.New(rollup.WithLocation(time.UTC))
+	l := New(rollups)
+
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 12, 0, 0, 0, time.UTC) }
+	for _, obs := range []api.ObservationTempest{
+		observation(day(9, 30), api.ObservationTempestData{RainAccumulated: 2, ReportInterval: 1}),
+		observation(day(10, 1), api.ObservationTempestData{RainAccumulated: 3, ReportInterval: 1}),
+		observation(day(10, 1).Add(time.Minute), api.ObservationTempestData{RainAccumulated: 1, ReportInterval: 1}),
+		observation(day(10, 2), api.ObservationTempestData{RainAccumulated: 5, ReportInterval: 1}),
+	} {
+		rollups.Add(ctx, obs)
+	}
+
+	// the backfilled copy of an observation carries the day's Rain Check correction
+	rollups.Add(ctx, observation(day(10, 1).Add(time.Minute), api.ObservationTempestData{
+		RainAccumulated:                 1,
+		ReportInterval:                  1,
+		PrecipitationAnalysisType:       1,
+		LocalRainAccumulationFinalCheck: 4.5,
+	}))
+
+	tests := []struct {
+		name   string
+		period Period
+		t      time.Time
+		want   Totals
+	}{
+		{name: "corrected day", period: Day, t: day(10, 1), want: Totals{Rain: 4.5, Measured: 4, Days: 1, Checked: 1}},
+		{name: "month", period: Month, t: day(10, 20), want: Totals{Rain: 9.5, Measured: 9, Days: 2, Checked: 1}},
+		{name: "year", period: Year, t: day(10, 20), want: Totals{Rain: 11.5, Measured: 11, Days: 3, Checked: 1}},
+		{name: "water year starts in october", period: WaterYear, t: day(10, 20), want: Totals{Rain: 9.5, Measured: 9, Days: 2, Checked: 1}},
+		{name: "previous water year", period: WaterYear, t: day(9, 30), want: Totals{Rain: 2, Measured: 2, Days: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Totals(ctx, 1, tt.period, tt.t)
+			if err != nil {
+				t.Fatalf("Totals() error = %v", err)
+			}
+			if got.Rain != tt.want.Rain || got.Measured != tt.want.Measured || got.Days != tt.want.Days || got.Checked != tt.want.Checked {
+				t.Errorf("Totals() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if start, _ := l.span(WaterYear, day(9, 30)); !start.Equal(time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("water year of september 30th starts %s", start)
+	}
+}
@@ -0,0 +1,125 @@
+package rain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/almanac"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+// Period is the span rain is totaled over
+type Period string
+
+const (
+	Day       Period = "day"
+	Month     Period = "month"
+	Year      Period = "year"
+	WaterYear Period = "water_year"
+)
+
+// Periods are every period, shortest first
+var Periods = []Period{Day, Month, Year, WaterYear}
+
+// ParsePeriod parses a period name
+func ParsePeriod(s string) (Period, error) {
+	switch p := Period(s); p {
+	case Day, Month, Year, WaterYear:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown period %q: expected day, month, year or water_year", s)
+}
+
+// Totals is the rain of a device over a period in mm. Rain is corrected by Rain Check for the days it has been, and
+// Measured is what the station measured before correction.
+type Totals struct {
+	Device   int       `json:"device_id"`
+	Period   Period    `json:"period"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Rain     float64   `json:"rain"`
+	Measured float64   `json:"measured"`
+	// Days is the number of days with observations, and Checked how many of them were corrected by Rain Check
+	Days    int `json:"days"`
+	Checked int `json:"checked_days"`
+}
+
+// Totals returns the rain of a device over the period containing t, from its day rollups
+func (l *Ledger) Totals(ctx context.Context, device int, p Period, t time.Time) (Totals, error) {
+	start, end := l.span(p, t)
+	totals := Totals{Device: device, Period: p, Start: start, End: end}
+
+	days, err := l.rollups.Query(ctx, rollup.Query{Device: device, Resolution: rollup.Day, Start: start, End: end})
+	if err != nil {
+		return totals, err
+	}
+	for _, b := range days {
+		totals.Days++
+		totals.Measured += b.Rain
+		totals.Rain += b.RainTotal()
+		if b.RainChecked {
+			totals.Checked++
+		}
+	}
+	return totals, nil
+}
+
+// Summary returns the rain of a device for each period containing t, shortest first
+func (l *Ledger) Summary(ctx context.Context, device int, t time.Time) ([]Totals, error) {
+	summary := make([]Totals, 0, len(Periods))
+	for _, p := range Periods {
+		totals, err := l.Totals(ctx, device, p, t)
+		if err != nil {
+			return nil, err
+		}
+		summary = append(summary, totals)
+	}
+	return summary, nil
+}
+
+// Daily returns the rain of each day of a device with observations between start and end
+func (l *Ledger) Daily(ctx context.Context, device int, start, end time.Time) ([]Totals, error) {
+	days, err := l.rollups.Query(ctx, rollup.Query{Device: device, Resolution: rollup.Day, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	daily := make([]Totals, 0, len(days))
+	for _, b := range days {
+		totals := Totals{Device: device, Period: Day, Start: b.Start, End: b.End, Rain: b.RainTotal(), Measured: b.Rain, Days: 1}
+		if b.RainChecked {
+			totals.Checked = 1
+		}
+		daily = append(daily, totals)
+	}
+	return daily, nil
+}
+
+// span returns the start and end of the period containing t in station-local time, with the calendar bounds of the
+// almanac. A water year starts on the first of the configured month and, by the usual convention, is named after the
+// calendar year it ends in.
+func (l *Ledger) span(p Period, t time.Time) (time.Time, time.Time) {
+	loc := l.rollups.Location()
+
+	var calendar almanac.Period
+	switch p {
+	case Day:
+		calendar = almanac.Day
+	case Month:
+		calendar = almanac.Month
+	case Year:
+		calendar = almanac.Year
+	default:
+		t = t.In(loc)
+		year := t.Year()
+		if t.Month() < l.waterYear {
+			year--
+		}
+		start := time.Date(year, l.waterYear, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(1, 0, 0)
+	}
+
+	start := calendar.Start(t, loc)
+	return start, calendar.End(start)
+}
@@ -0,0 +1,106 @@
+package retention
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+const day = 24 * time.Hour
+
+// Class is a kind of stored record with its own retention, raw messages or the rollups of a resolution
+type Class string
+
+// Raw is every stored message, as opposed to the rollups and rain events derived from them
+const Raw Class = "raw"
+
+// Classes are every class, finest first
+var Classes = []Class{Raw, Class(rollup.Minute), Class(rollup.Hour), Class(rollup.Day)}
+
+// Policy is how long records of each class are kept. A class without an age, or with an age of zero, is kept forever.
+type Policy map[Class]time.Duration
+
+// DefaultPolicy keeps raw messages and minute rollups for 30 days, hourly rollups for 2 years and daily rollups forever
+func DefaultPolicy() Policy {
+	return Policy{
+		Raw:                  30 * day,
+		Class(rollup.Minute): 30 * day,
+		Class(rollup.Hour):   2 * 365 * day,
+	}
+}
+
+// ParsePolicy parses a comma separated list of class=age pairs, such as "raw=30d,hour=2y,day=forever", over the default policy.
+// An age is a go duration, a number of days, weeks or 365 day years such as 30d, 8w or 2y, or forever.
+func ParsePolicy(s string) (Policy, error) {
+	p := DefaultPolicy()
+	if strings.TrimSpace(s) == "" {
+		return p, nil
+	}
+
+	for _, rule := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention rule %q: expected class=age", rule)
+		}
+
+		class := Class(strings.TrimSpace(name))
+		if !slices.Contains(Classes, class) {
+			return nil, fmt.Errorf("unknown retention class %q: expected raw, minute, hour or day", name)
+		}
+
+		age, err := parseAge(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention for %s: %w", class, err)
+		}
+		p[class] = age
+	}
+
+	return p, nil
+}
+
+// String formats the policy the way ParsePolicy reads it
+func (p Policy) String() string {
+	rules := make([]string, 0, len(Classes))
+	for _, class := range Classes {
+		rules = append(rules, fmt.Sprintf("%s=%s", class, formatAge(p[class])))
+	}
+	return strings.Join(rules, ",")
+}
+
+func parseAge(s string) (time.Duration, error) {
+	if s == "forever" {
+		return 0, nil
+	}
+
+	units := map[byte]time.Duration{'d': day, 'w': 7 * day, 'y': 365 * day}
+	if unit, ok := units[s[len(s)-1]]; ok && len(s) > 1 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	age, err := time.ParseDuration(s)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return age, nil
+}
+
+func formatAge(age time.Duration) string {
+	switch {
+	case age == 0:
+		return "forever"
+	case age%(365*day) == 0:
+		return fmt.Sprintf("%dy", age/(365*day))
+	case age%day == 0:
+		return fmt.Sprintf("%dd", age/day)
+	default:
+		return age.String()
+	}
+}
@@ -0,0 +1,159 @@
+// Package retention deletes stored history as it ages, rolling raw observations up before they are deleted.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/rollup"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// derived are the prefixes of the types of records derived from messages rather than received, which are not raw
+var derived = []string{"rollup_", "rain_"}
+
+// Retainer applies a policy to a store and the rollups kept in it
+type Retainer struct {
+	store   *store.Store
+	rollups *rollup.Aggregator
+	policy  Policy
+}
+
+// Expired is what a class of a device lost, or would lose in a dry run, to its retention
+type Expired struct {
+	Device int       `json:"device_id"`
+	Class  Class     `json:"class"`
+	Before time.Time `json:"before"`
+	// Records and Bytes are the deleted records and the space they take up until the store is compacted
+	Records int   `json:"records"`
+	Bytes   int64 `json:"bytes"`
+	// Downsampled is the number of rollup buckets rebuilt from raw observations before they were deleted
+	Downsampled int `json:"downsampled,omitempty"`
+}
+
+// Report is the outcome of applying a policy
+type Report struct {
+	Policy  Policy    `json:"-"`
+	DryRun  bool      `json:"dry_run"`
+	Expired []Expired `json:"expired"`
+}
+
+// New creates a retainer. The aggregator must keep its rollups in st, raw observations are rolled up with it before they are deleted.
+func New(st *store.Store, rollups *rollup.Aggregator, p Policy) *Retainer {
+	return &Retainer{store: st, rollups: rollups, policy: p}
+}
+
+// Plan reports what Apply would delete at now, without changing anything
+func (r *Retainer) Plan(ctx context.Context, now time.Time) (Report, error) {
+	return r.run(ctx, now, true)
+}
+
+// Apply deletes what the policy expires at now. Raw obs_st observations are rolled up first, filling in any rollups they are
+// missing, so their minute, hour and day rollups outlive them.
+func (r *Retainer) Apply(ctx context.Context, now time.Time) (Report, error) {
+	return r.run(ctx, now, false)
+}
+
+func (r *Retainer) run(ctx context.Context, now time.Time, dryRun bool) (Report, error) {
+	report := Report{Policy: r.policy, DryRun: dryRun}
+
+	for _, device := range r.store.Devices() {
+		for _, class := range Classes {
+			age := r.policy[class]
+			if age <= 0 {
+				continue
+			}
+
+			expired := Expired{Device: device, Class: class, Before: now.Add(-age)}
+			q := store.Query{Device: device, Types: r.types(device, class), End: expired.Before}
+			if len(q.Types) == 0 {
+				continue
+			}
+
+			count := r.store.Count(q)
+			if count.Records == 0 {
+				continue
+			}
+			expired.Records, expired.Bytes = count.Records, count.Bytes
+
+			if !dryRun {
+				var err error
+				if expired, err = r.expire(ctx, expired, q); err != nil {
+					return report, fmt.Errorf("expiring %s of device %d: %w", class, device, err)
+				}
+			}
+			report.Expired = append(report.Expired, expired)
+		}
+	}
+
+	return report, nil
+}
+
+// expire deletes the records of a class, rolling raw observations up first
+func (r *Retainer) expire(ctx context.Context, expired Expired, q store.Query) (Expired, error) {
+	if expired.Class != Raw {
+		removed, err := r.rollups.Expire(ctx, expired.Device, rollup.Resolution(expired.Class), expired.Before)
+		expired.Records, expired.Bytes = removed.Records, removed.Bytes
+		return expired, err
+	}
+
+	first, err := r.store.Query(ctx, store.Query{Device: expired.Device, Types: []string{string(tempest.EventObservationTempest)}, End: expired.Before, Limit: 1})
+	if err != nil {
+		return expired, err
+	}
+	if len(first) > 0 {
+		if expired.Downsampled, err = r.rollups.Backfill(ctx, expired.Device, first[0].Time(), expired.Before); err != nil {
+			return expired, err
+		}
+	}
+
+	removed, err := r.store.Delete(ctx, q)
+	expired.Records, expired.Bytes = removed.Records, removed.Bytes
+	return expired, err
+}
+
+// types returns the stored record types of a device in a class
+func (r *Retainer) types(device int, class Class) []string {
+	if class != Raw {
+		return []string{rollup.RecordType(rollup.Resolution(class))}
+	}
+
+	return slices.DeleteFunc(r.store.Types(device), func(typ string) bool {
+		return slices.ContainsFunc(derived, func(prefix string) bool { return strings.HasPrefix(typ, prefix) })
+	})
+}
+
+// WriteTo writes the report as a table
+func (rep Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	verb := "deleted"
+	if rep.DryRun {
+		verb = "would delete"
+	}
+	fmt.Fprintf(&b, "retention %s\n", rep.Policy)
+
+	if len(rep.Expired) == 0 {
+		fmt.Fprintf(&b, "nothing has expired\n")
+	} else {
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "DEVICE\tCLASS\tBEFORE\tRECORDS\tBYTES\tDOWNSAMPLED\n")
+		records, bytes := 0, int64(0)
+		for _, e := range rep.Expired {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\n", e.Device, e.Class, e.Before.Format(time.RFC3339), e.Records, e.Bytes, e.Downsampled)
+			records += e.Records
+			bytes += e.Bytes
+		}
+		tw.Flush()
+		fmt.Fprintf(&b, "%s %d records, %d bytes\n", verb, records, bytes)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,114 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/rollup"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest/tempesttest"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", policy: "", want: "raw=30d,minute=30d,hour=2y,day=forever"},
+		{name: "overrides the default", policy: "raw=8w, hour=forever,day=10y", want: "raw=56d,minute=30d,hour=forever,day=10y"},
+		{name: "go duration", policy: "minute=36h", want: "raw=30d,minute=36h0m0s,hour=2y,day=forever"},
+		{name: "unknown class", policy: "week=1y", wantErr: true},
+		{name: "invalid age", policy: "raw=soon", wantErr: true},
+		{name: "missing age", policy: "raw", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParsePolicy() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(t.TempDir(), store.WithSync(false))
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+	defer st.Close()
+
+	// an hourly observation for 40 days, stored without being rolled up
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 40 * 24 {
+		b, _ := json.Marshal(tempesttest.Observation(1, start.Add(time.Duration(i)*time.Hour)))
+		rec, _ := store.FromMessage(b)
+		st.Append(ctx, rec)
+	}
+	b, _ := json.Marshal(tempesttest.Strike(1, start.Add(time.Hour), 10, 100))
+	rec, _ := store.FromMessage(b)
+	st.Append(ctx, rec)
+
+	now := start.Add(40 * day)
+	policy, _ := ParsePolicy("raw=30d,minute=7d")
+	rollups := rollup.New(rollup.WithLocation(time.UTC), rollup.WithStore(st))
+	r := New(st, rollups, policy)
+
+	plan, err := r.Plan(ctx, now)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Expired) != 1 || plan.Expired[0].Records != 10*24+1 {
+		t.Fatalf("Plan() = %+v, want the first 10 days of raw messages", plan.Expired)
+	}
+	if got := st.Count(store.Query{Device: 1}).Records; got != 40*24+1 {
+		t.Fatalf("records after a dry run = %d, want all of them", got)
+	}
+
+	report, err := r.Apply(ctx, now)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := []Expired{
+		{Class: Raw, Records: 10*24 + 1, Downsampled: 10*24*2 + 10},
+		{Class: Class(rollup.Minute), Records: 10 * 24},
+	}
+	if len(report.Expired) != len(want) {
+		t.Fatalf("Apply() = %+v, want %+v", report.Expired, want)
+	}
+	for i, e := range report.Expired {
+		if e.Class != want[i].Class || e.Records != want[i].Records || e.Downsampled != want[i].Downsampled {
+			t.Errorf("expired %s = %+v, want %+v", e.Class, e, want[i])
+		}
+	}
+
+	if raw, _ := st.Query(ctx, store.Query{Device: 1, Types: []string{"obs_st"}, Limit: 1}); len(raw) != 1 || !raw[0].Time().Equal(start.Add(10*day)) {
+		t.Errorf("oldest raw observation = %+v, want the one 30 days old", raw)
+	}
+
+	hours, _ := rollups.Query(ctx, rollup.Query{Device: 1, Resolution: rollup.Hour, End: start.Add(10 * day)})
+	if len(hours) != 10*24 {
+		t.Errorf("hour rollups of the deleted observations = %d, want %d", len(hours), 10*24)
+	}
+
+	var out strings.Builder
+	report.WriteTo(&out)
+	if !strings.Contains(out.String(), "deleted 481 records") {
+		t.Errorf("report = %s", out.String())
+	}
+
+	if again, _ := r.Apply(ctx, now); len(again.Expired) != 0 {
+		t.Errorf("Apply() again = %+v, want nothing", again.Expired)
+	}
+}
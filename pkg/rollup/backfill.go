@@ -0,0 +1,119 @@
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// Backfill rebuilds the buckets of a device from the obs_st observations in the store, a day at a time for the days between
+// start and end, so observations stored before the aggregator ran are rolled up too. A bucket is only rebuilt when the store
+// holds more of its observations than it has aggregated, so a bucket whose observations were partly deleted already is kept.
+// It returns the number of buckets rebuilt.
+func (a *Aggregator) Backfill(ctx context.Context, device int, start, end time.Time) (int, error) {
+	if a.store == nil {
+		return 0, errors.New("backfilling rollups needs a store")
+	}
+
+	rebuilt := 0
+	for day := Day.Start(start, a.loc); day.Before(end); day = Day.End(day) {
+		records, err := a.store.Query(ctx, store.Query{Device: device, Types: []string{string(tempest.EventObservationTempest)}, Start: day, End: Day.End(day)})
+		if err != nil {
+			return rebuilt, err
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		observations := make([]api.ObservationTempestData, 0, len(records))
+		for _, rec := range records {
+			var obs api.ObservationTempest
+			if err := json.Unmarshal(rec.Data, &obs); err != nil {
+				return rebuilt, fmt.Errorf("observation at %d: %w", rec.Epoch, err)
+			}
+			observations = append(observations, obs.Data)
+		}
+
+		for _, r := range Resolutions {
+			n, err := a.rebuild(ctx, device, r, day, observations)
+			rebuilt += n
+			if err != nil {
+				return rebuilt, err
+			}
+		}
+	}
+
+	return rebuilt, nil
+}
+
+// rebuild aggregates the observations of a day into buckets of a resolution, replacing the buckets that have fewer observations
+func (a *Aggregator) rebuild(ctx context.Context, device int, r Resolution, day time.Time, observations []api.ObservationTempestData) (int, error) {
+	existing, err := a.Query(ctx, Query{Device: device, Resolution: r, Start: day, End: Day.End(day)})
+	if err != nil {
+		return 0, err
+	}
+	aggregated := make(map[int64]int, len(existing))
+	for _, b := range existing {
+		aggregated[b.Start.Unix()] = b.Observations
+	}
+
+	built := make(map[int64]*Bucket)
+	var order []int64
+	for _, o := range observations {
+		t := time.Unix(int64(o.TimeEpoch), 0)
+		start := r.Start(t, a.loc).Unix()
+		if built[start] == nil {
+			built[start] = newBucket(device, r, t, a.loc)
+			order = append(order, start)
+		}
+		built[start].add(o)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rebuilt := 0
+	for _, start := range order {
+		b := built[start]
+		if b.Observations <= aggregated[start] {
+			continue
+		}
+
+		// an observation aggregated since the query may already be in the bucket in memory
+		live, ok := a.buckets[r][device][start]
+		if ok && live.Observations >= b.Observations {
+			continue
+		}
+
+		if err := a.persist(ctx, b); err != nil {
+			return rebuilt, err
+		}
+		if ok {
+			*live = *b
+		}
+		rebuilt++
+	}
+	return rebuilt, nil
+}
+
+// Expire deletes the buckets of a device at a resolution that start before a time, from memory and the store
+func (a *Aggregator) Expire(ctx context.Context, device int, r Resolution, before time.Time) (store.Stats, error) {
+	a.mu.Lock()
+	for start, b := range a.buckets[r][device] {
+		if b.Start.Before(before) {
+			delete(a.buckets[r][device], start)
+		}
+	}
+	a.mu.Unlock()
+
+	if a.store == nil {
+		return store.Stats{}, nil
+	}
+	return a.store.Delete(ctx, store.Query{Device: device, Types: []string{RecordType(r)}, End: before})
+}
@@ -0,0 +1,208 @@
+package rollup
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// Resolution is the length of a rollup bucket
+type Resolution string
+
+const (
+	Minute Resolution = "minute"
+	Hour   Resolution = "hour"
+	Day    Resolution = "day"
+)
+
+// Resolutions are every resolution, finest first
+var Resolutions = []Resolution{Minute, Hour, Day}
+
+// ParseResolution parses a resolution name
+func ParseResolution(s string) (Resolution, error) {
+	switch r := Resolution(s); r {
+	case Minute, Hour, Day:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown resolution %q: expected minute, hour or day", s)
+}
+
+// Start returns the start of the bucket containing t. Buckets follow the wall clock of loc, so a day runs from local midnight
+// to local midnight and can be 23 or 25 hours long across daylight saving changes.
+func (r Resolution) Start(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch r {
+	case Minute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	case Hour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// End returns the start of the bucket after the one starting at start
+func (r Resolution) End(start time.Time) time.Time {
+	switch r {
+	case Minute:
+		return start.Add(time.Minute)
+	case Hour:
+		return start.Add(time.Hour)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Stat is the minimum, maximum and mean of the values of a field. MinEpoch and MaxEpoch are when the minimum and maximum
+// were first observed.
+type Stat struct {
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Mean     float64 `json:"mean"`
+	Count    int     `json:"count"`
+	MinEpoch int64   `json:"min_epoch,omitempty"`
+	MaxEpoch int64   `json:"max_epoch,omitempty"`
+}
+
+// Add adds a value observed at epoch
+func (s *Stat) Add(v float64, epoch int64) {
+	s.Merge(Stat{Min: v, Max: v, Mean: v, Count: 1, MinEpoch: epoch, MaxEpoch: epoch})
+}
+
+// Merge combines the values of another stat, weighting the means by their counts
+func (s *Stat) Merge(o Stat) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Count == 0 {
+		*s = o
+		return
+	}
+
+	if o.Min < s.Min || (o.Min == s.Min && o.MinEpoch < s.MinEpoch) {
+		s.Min, s.MinEpoch = o.Min, o.MinEpoch
+	}
+	if o.Max > s.Max || (o.Max == s.Max && o.MaxEpoch < s.MaxEpoch) {
+		s.Max, s.MaxEpoch = o.Max, o.MaxEpoch
+	}
+	s.Mean = (s.Mean*float64(s.Count) + o.Mean*float64(o.Count)) / float64(s.Count+o.Count)
+	s.Count += o.Count
+}
+
+// Bucket aggregates the observations of a device over one bucket of a resolution. Rain and lightning strikes are summed.
+// WindU and WindV are the summed east and north components of the average wind, so WindDirection is the vector mean of
+// the wind direction, weighted by speed, rather than an arithmetic mean of degrees. GustDirection is the wind direction
+// of the observation with the peak gust, and RainRate is the rain of each observation in mm per hour. A day bucket's
+// RainCheck is the day's rain corrected by Rain Check, once an observation carrying it has been seen.
+type Bucket struct {
+	Device     int        `json:"device_id"`
+	Resolution Resolution `json:"resolution"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	// First and Last are the epochs of the earliest and latest observation in the bucket
+	First        int64 `json:"first_epoch"`
+	Last         int64 `json:"last_epoch"`
+	Observations int   `json:"observations"`
+
+	AirTemperature    Stat    `json:"air_temperature"`
+	RelativeHumidity  Stat    `json:"relative_humidity"`
+	StationPressure   Stat    `json:"station_pressure"`
+	WindAverage       Stat    `json:"wind_average"`
+	WindGust          Stat    `json:"wind_gust"`
+	GustDirection     float64 `json:"gust_direction"`
+	WindU             float64 `json:"wind_u"`
+	WindV             float64 `json:"wind_v"`
+	WindDirection     float64 `json:"wind_direction"`
+	Illuminance       Stat    `json:"illuminance"`
+	UVIndex           Stat    `json:"uv_index"`
+	SolarRadiation    Stat    `json:"solar_radiation"`
+	Rain              float64 `json:"rain"`
+	RainRate          Stat    `json:"rain_rate"`
+	RainCheck         float64 `json:"rain_check,omitempty"`
+	RainChecked       bool    `json:"rain_checked,omitempty"`
+	LightningStrikes  int     `json:"lightning_strikes"`
+	LightningDistance Stat    `json:"lightning_distance"`
+}
+
+// newBucket creates the bucket of a resolution containing t
+func newBucket(device int, r Resolution, t time.Time, loc *time.Location) *Bucket {
+	start := r.Start(t, loc)
+	return &Bucket{Device: device, Resolution: r, Start: start, End: r.End(start)}
+}
+
+// add aggregates an observation into the bucket
+func (b *Bucket) add(o api.ObservationTempestData) {
+	epoch := int64(o.TimeEpoch)
+	if b.Observations == 0 || epoch < b.First {
+		b.First = epoch
+	}
+	if epoch > b.Last {
+		b.Last = epoch
+	}
+	b.Observations++
+
+	if b.WindGust.Count == 0 || o.WindGust > b.WindGust.Max {
+		b.GustDirection = o.WindDirectionDegrees
+	}
+
+	b.AirTemperature.Add(o.AirTemperature, epoch)
+	b.RelativeHumidity.Add(float64(o.RelativeHumidity), epoch)
+	b.StationPressure.Add(o.StationPressure, epoch)
+	b.WindAverage.Add(o.WindAverage, epoch)
+	b.WindGust.Add(o.WindGust, epoch)
+	b.Illuminance.Add(float64(o.Illuminance), epoch)
+	b.UVIndex.Add(o.UltraviolentIndex, epoch)
+	b.SolarRadiation.Add(float64(o.SolarRadiation), epoch)
+
+	rad := o.WindDirectionDegrees * math.Pi / 180
+	b.WindU += o.WindAverage * math.Sin(rad)
+	b.WindV += o.WindAverage * math.Cos(rad)
+	b.WindDirection = direction(b.WindU, b.WindV)
+
+	b.check(o)
+
+	interval := max(o.ReportInterval, 1)
+	b.Rain += o.RainAccumulated
+	b.RainRate.Add(o.RainAccumulated*60/float64(interval), epoch)
+	b.LightningStrikes += o.LightningStrikeCount
+	if o.LightningStrikeCount > 0 {
+		b.LightningDistance.Add(o.LightningStrikeAverageDistance, epoch)
+	}
+}
+
+// RainTotal returns the rain of the bucket, corrected by Rain Check when it has been
+func (b Bucket) RainTotal() float64 {
+	if b.RainChecked {
+		return b.RainCheck
+	}
+	return b.Rain
+}
+
+// check reconciles a day bucket with the Rain Check corrected rain of the local day an observation carries, which is
+// accumulated since local midnight, so the largest value seen is the day's total. Live observations leave it zero until
+// Rain Check has run, so only a positive value is taken as the correction. It reports whether the bucket changed.
+func (b *Bucket) check(o api.ObservationTempestData) bool {
+	checked := o.PrecipitationAnalysisType == 1 || o.PrecipitationAnalysisType == 2
+	if b.Resolution != Day || !checked || o.LocalRainAccumulationFinalCheck <= b.RainCheck {
+		return false
+	}
+
+	b.RainCheck, b.RainChecked = o.LocalRainAccumulationFinalCheck, true
+	return true
+}
+
+// contains reports whether an observation at epoch is already in the bucket. Tempest devices report at most once a minute,
+// so an observation within the span of the minute bucket holding it has been aggregated already.
+func (b *Bucket) contains(epoch int64) bool {
+	return b.Observations > 0 && epoch >= b.First && epoch <= b.Last
+}
+
+// direction returns the compass direction in degrees the wind is blowing from, for summed east and north components
+func direction(u, v float64) float64 {
+	if u == 0 && v == 0 {
+		return 0
+	}
+	return math.Mod(math.Atan2(u, v)*180/math.Pi+360, 360)
+}
@@ -0,0 +1,243 @@
+// Package rollup aggregates observations into minute, hour and day buckets in station-local time, for charts over long ranges.
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// defaultKeep is how long buckets are kept in memory after their end, older buckets are only available from the store
+var defaultKeep = map[Resolution]time.Duration{
+	Minute: 48 * time.Hour,
+	Hour:   90 * 24 * time.Hour,
+	Day:    10 * 365 * 24 * time.Hour,
+}
+
+// Aggregator updates the rollups of each device incrementally as obs_st observations arrive. With a store every update is
+// written to it, so rollups survive restarts and buckets older than the in-memory window can still be queried.
+type Aggregator struct {
+	loc   *time.Location
+	store *store.Store
+	keep  map[Resolution]time.Duration
+
+	mu      sync.Mutex
+	buckets map[Resolution]map[int]map[int64]*Bucket
+}
+
+// Option configures an Aggregator
+type Option func(*Aggregator)
+
+// WithLocation sets the station's time zone, which bucket boundaries follow. Defaults to the local time zone.
+func WithLocation(loc *time.Location) Option {
+	return func(a *Aggregator) {
+		if loc != nil {
+			a.loc = loc
+		}
+	}
+}
+
+// WithStore persists rollups to a store and reads buckets that are no longer in memory from it
+func WithStore(st *store.Store) Option {
+	return func(a *Aggregator) {
+		a.store = st
+	}
+}
+
+// Query selects the buckets of a device at a resolution starting between Start, inclusive, and End, exclusive.
+// A zero Start or End is unbounded.
+type Query struct {
+	Device     int
+	Resolution Resolution
+	Start      time.Time
+	End        time.Time
+}
+
+// RecordType returns the store record type of the rollups of a resolution
+func RecordType(r Resolution) string {
+	return "rollup_" + string(r)
+}
+
+// New creates an aggregator
+func New(opts ...Option) *Aggregator {
+	a := &Aggregator{
+		loc:     time.Local,
+		keep:    defaultKeep,
+		buckets: make(map[Resolution]map[int]map[int64]*Bucket),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	for _, r := range Resolutions {
+		a.buckets[r] = make(map[int]map[int64]*Bucket)
+	}
+	return a
+}
+
+// Location returns the time zone buckets follow
+func (a *Aggregator) Location() *time.Location {
+	return a.loc
+}
+
+// Attach aggregates the observations a listener handles and returns a func that stops aggregating them. Errors writing to the store are logged.
+func (a *Aggregator) Attach(l tempest.Listener) (remove func()) {
+	return tempest.On(l, func(ctx context.Context, obs api.ObservationTempest) {
+		if err := a.Add(ctx, obs); err != nil {
+			log.Printf("rollup: %v", err)
+		}
+	})
+}
+
+// Add aggregates an observation into the buckets containing it. An observation already aggregated is skipped, other than
+// reconciling its day with the Rain Check corrected rain it carries.
+func (a *Aggregator) Add(ctx context.Context, obs api.ObservationTempest) error {
+	epoch := int64(obs.Data.TimeEpoch)
+	if epoch <= 0 {
+		return nil
+	}
+	t := time.Unix(epoch, 0)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	minute, err := a.bucket(ctx, obs.Device, Minute, t)
+	if err != nil {
+		return err
+	}
+	if minute.contains(epoch) {
+		// a backfilled observation can carry the Rain Check correction its live copy did not
+		day, err := a.bucket(ctx, obs.Device, Day, t)
+		if err != nil || !day.check(obs.Data) {
+			return err
+		}
+		return a.persist(ctx, day)
+	}
+
+	for _, r := range Resolutions {
+		b, err := a.bucket(ctx, obs.Device, r, t)
+		if err != nil {
+			return err
+		}
+		b.add(obs.Data)
+
+		if err := a.persist(ctx, b); err != nil {
+			return err
+		}
+	}
+
+	a.prune(t)
+	return nil
+}
+
+// Query returns the buckets matching q in time order
+func (a *Aggregator) Query(ctx context.Context, q Query) ([]Bucket, error) {
+	found := make(map[int64]Bucket)
+
+	if a.store != nil {
+		records, err := a.store.Query(ctx, store.Query{Device: q.Device, Types: []string{RecordType(q.Resolution)}, Start: q.Start, End: q.End})
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			b, err := a.decode(rec)
+			if err != nil {
+				return nil, err
+			}
+			found[rec.Epoch] = b
+		}
+	}
+
+	a.mu.Lock()
+	for start, b := range a.buckets[q.Resolution][q.Device] {
+		if (q.Start.IsZero() || !b.Start.Before(q.Start)) && (q.End.IsZero() || b.Start.Before(q.End)) {
+			found[start] = *b
+		}
+	}
+	a.mu.Unlock()
+
+	buckets := slices.Collect(maps.Values(found))
+	slices.SortFunc(buckets, func(x, y Bucket) int { return x.Start.Compare(y.Start) })
+	return buckets, nil
+}
+
+// Devices returns the devices with buckets in memory
+func (a *Aggregator) Devices() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Sorted(maps.Keys(a.buckets[Day]))
+}
+
+// bucket returns the bucket of a resolution containing t, resuming it from the store when it is not in memory
+func (a *Aggregator) bucket(ctx context.Context, device int, r Resolution, t time.Time) (*Bucket, error) {
+	start := r.Start(t, a.loc)
+
+	devices := a.buckets[r]
+	if devices[device] == nil {
+		devices[device] = make(map[int64]*Bucket)
+	}
+	if b, ok := devices[device][start.Unix()]; ok {
+		return b, nil
+	}
+
+	b := newBucket(device, r, t, a.loc)
+	if a.store != nil {
+		records, err := a.store.Query(ctx, store.Query{Device: device, Types: []string{RecordType(r)}, Start: start, End: start.Add(time.Second)})
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			stored, err := a.decode(records[0])
+			if err != nil {
+				return nil, err
+			}
+			b = &stored
+		}
+	}
+
+	devices[device][start.Unix()] = b
+	return b, nil
+}
+
+func (a *Aggregator) persist(ctx context.Context, b *Bucket) error {
+	if a.store == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return a.store.Append(ctx, store.Record{Type: RecordType(b.Resolution), Device: b.Device, Epoch: b.Start.Unix(), Data: data})
+}
+
+func (a *Aggregator) decode(rec store.Record) (Bucket, error) {
+	var b Bucket
+	if err := json.Unmarshal(rec.Data, &b); err != nil {
+		return b, err
+	}
+	b.Start, b.End = b.Start.In(a.loc), b.End.In(a.loc)
+	return b, nil
+}
+
+// prune drops the buckets that ended longer ago than their resolution is kept in memory, relative to the latest observation
+func (a *Aggregator) prune(latest time.Time) {
+	for r, devices := range a.buckets {
+		cutoff := latest.Add(-a.keep[r])
+		for _, buckets := range devices {
+			for start, b := range buckets {
+				if b.End.Before(cutoff) {
+					delete(buckets, start)
+				}
+			}
+		}
+	}
+}
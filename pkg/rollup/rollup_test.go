@@ -0,0 +1,178 @@
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/store"
+)
+
+func observation(t time.Time, data api.ObservationTempestData) api.ObservationTempest {
+	data.TimeEpoch = int(t.Unix())
+	return api.ObservationTempest{Type: "obs_st", Device: 1, Data: data}
+}
+
+func TestResolutionStart(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data is not available")
+	}
+
+	tests := []struct {
+		name       string
+		resolution Resolution
+		t          time.Time
+		wantStart  string
+		wantLength time.Duration
+	}{
+		{name: "minute", resolution: Minute, t: time.Date(2025, 3, 9, 12, 30, 45, 0, ny), wantStart: "2025-03-09T12:30:00-04:00", wantLength: time.Minute},
+		{name: "hour", resolution: Hour, t: time.Date(2025, 7, 1, 12, 30, 0, 0, ny), wantStart: "2025-07-01T12:00:00-04:00", wantLength: time.Hour},
+		{name: "local day, not utc", resolution: Day, t: time.Date(2025, 7, 2, 2, 0, 0, 0, time.UTC), wantStart: "2025-07-01T00:00:00-04:00", wantLength: 24 * time.Hour},
+		{name: "day losing an hour to daylight saving", resolution: Day, t: time.Date(2025, 3, 9, 12, 0, 0, 0, ny), wantStart: "2025-03-09T00:00:00-05:00", wantLength: 23 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := tt.resolution.Start(tt.t, ny)
+			if got := start.Format(time.RFC3339); got != tt.wantStart {
+				t.Errorf("Start() = %s, want %s", got, tt.wantStart)
+			}
+			if got := tt.resolution.End(start).Sub(start); got != tt.wantLength {
+				t.Errorf("bucket length = %s, want %s", got, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	ctx := context.Background()
+	loc := time.FixedZone("station", -5*3600)
+	a := New(WithLocation(loc))
+
+	start := time.Date(2025, 7, 1, 13, 58, 0, 0, loc)
+	obs := []api.ObservationTempest{
+		observation(start, api.ObservationTempestData{AirTemperature: 20, WindAverage: 2, WindDirectionDegrees: 350, RainAccumulated: 0.2}),
+		observation(start.Add(time.Minute), api.ObservationTempestData{AirTemperature: 22, WindAverage: 2, WindDirectionDegrees: 10, RainAccumulated: 0.3, LightningStrikeCount: 2}),
+		observation(start.Add(2*time.Minute), api.ObservationTempestData{AirTemperature: 25, WindAverage: 4, WindDirectionDegrees: 90, RainAccumulated: 1, LightningStrikeCount: 1}),
+		// retransmitted
+		observation(start.Add(2*time.Minute), api.ObservationTempestData{AirTemperature: 25, WindAverage: 4, WindDirectionDegrees: 90, RainAccumulated: 1, LightningStrikeCount: 1}),
+	}
+	for _, o := range obs {
+		if err := a.Add(ctx, o); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	hours, err := a.Query(ctx, Query{Device: 1, Resolution: Hour})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(hours) != 2 {
+		t.Fatalf("hour buckets = %d, want 2", len(hours))
+	}
+
+	first := hours[0]
+	if first.Observations != 2 || first.Rain != 0.5 || first.LightningStrikes != 2 {
+		t.Errorf("first hour = %d observations, %g rain, %d strikes, want 2, 0.5 and 2", first.Observations, first.Rain, first.LightningStrikes)
+	}
+	if first.AirTemperature != (Stat{Min: 20, Max: 22, Mean: 21, Count: 2, MinEpoch: start.Unix(), MaxEpoch: start.Unix() + 60}) {
+		t.Errorf("first hour temperature = %+v", first.AirTemperature)
+	}
+	if d := first.WindDirection; d > 0.001 && d < 359.999 {
+		t.Errorf("mean of 350 and 10 degrees = %g, want north", d)
+	}
+	if first.RainRate.Max != 18 || first.RainRate.MaxEpoch != start.Unix()+60 {
+		t.Errorf("first hour rain rate = %+v, want a peak of 18mm/h in the second minute", first.RainRate)
+	}
+
+	days, _ := a.Query(ctx, Query{Device: 1, Resolution: Day})
+	if len(days) != 1 || days[0].Observations != 3 || math.Abs(days[0].Rain-1.5) > 1e-9 {
+		t.Errorf("day buckets = %+v, want one with 3 observations and 1.5 rain", days)
+	}
+
+	minutes, _ := a.Query(ctx, Query{Device: 1, Resolution: Minute, Start: start.Add(time.Minute)})
+	if len(minutes) != 2 {
+		t.Errorf("minute buckets from the second minute = %d, want 2", len(minutes))
+	}
+}
+
+func TestResumeFromStore(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(t.TempDir(), store.WithSync(false))
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+	defer st.Close()
+
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	a := New(WithLocation(time.UTC), WithStore(st))
+	a.Add(ctx, observation(start, api.ObservationTempestData{AirTemperature: 20, WindAverage: 1, RainAccumulated: 1}))
+
+	// a restart mid-hour resumes the stored bucket
+	a = New(WithLocation(time.UTC), WithStore(st))
+	a.Add(ctx, observation(start.Add(time.Minute), api.ObservationTempestData{AirTemperature: 30, WindAverage: 1, RainAccumulated: 2}))
+
+	hours, err := a.Query(ctx, Query{Device: 1, Resolution: Hour})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(hours) != 1 || hours[0].Observations != 2 || hours[0].Rain != 3 || hours[0].AirTemperature.Mean != 25 {
+		t.Errorf("hour buckets after a restart = %+v", hours)
+	}
+	if !hours[0].Start.Equal(start) {
+		t.Errorf("bucket start = %s, want %s", hours[0].Start, start)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(t.TempDir(), store.WithSync(false))
+	if err != nil {
+		t.Fatalf("store.Open() error = %v", err)
+	}
+	defer st.Close()
+
+	start := time.Date(2025, 7, 1, 23, 0, 0, 0, time.UTC)
+	a := New(WithLocation(time.UTC), WithStore(st))
+	for i := range 120 {
+		obs := observation(start.Add(time.Duration(i)*time.Minute), api.ObservationTempestData{AirTemperature: 20, WindAverage: 1, RainAccumulated: 0.1})
+		b, _ := json.Marshal(obs)
+		rec, err := store.FromMessage(b)
+		if err != nil {
+			t.Fatalf("FromMessage() error = %v", err)
+		}
+		st.Append(ctx, rec)
+
+		// the aggregator only ran for the last half hour
+		if i >= 90 {
+			a.Add(ctx, obs)
+		}
+	}
+
+	rebuilt, err := a.Backfill(ctx, 1, start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	// 90 minutes, the two hours and the two days
+	if rebuilt != 94 {
+		t.Errorf("Backfill() rebuilt %d buckets, want 94", rebuilt)
+	}
+
+	days, _ := a.Query(ctx, Query{Device: 1, Resolution: Day})
+	if len(days) != 2 || days[0].Observations != 60 || days[1].Observations != 60 {
+		t.Fatalf("day buckets after backfilling = %+v", days)
+	}
+
+	// with the raw observations of the first hour deleted, the rollups are kept as they are
+	st.Delete(ctx, store.Query{Device: 1, Types: []string{"obs_st"}, End: start.Add(time.Hour)})
+	if rebuilt, _ := a.Backfill(ctx, 1, start, start.Add(2*time.Hour)); rebuilt != 0 {
+		t.Errorf("Backfill() again rebuilt %d buckets, want 0", rebuilt)
+	}
+	if hours, _ := a.Query(ctx, Query{Device: 1, Resolution: Hour}); len(hours) != 2 || hours[0].Observations != 60 || math.Abs(hours[0].Rain-6) > 1e-9 {
+		t.Errorf("hour buckets after deleting raw observations = %+v", hours)
+	}
+}
@@ -0,0 +1,296 @@
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// DefaultPath is the path the websocket api is served on, the same as the WeatherFlow api
+const DefaultPath = "/swd/data"
+
+// Server is a websocket handler speaking the WeatherFlow protocol, for the simulate command and for testing websocket
+// listeners. Each connection is sent connection_opened and every listen request is answered with an ack. As with the
+// WeatherFlow api, messages for a device or station are only sent to connections listening to it, and rapid_wind messages
+// only to connections that sent listen_rapid_start for the device.
+type Server struct {
+	// Path is where the server expects to be mounted
+	Path string
+	// Token is the token query parameter connections must pass, any connection is accepted when it is empty
+	Token string
+
+	script   [][]byte
+	interval time.Duration
+	acks     bool
+
+	mu       sync.Mutex
+	conns    map[*serverConn]struct{}
+	requests []tempest.RequestMessage
+}
+
+// ServerOption configures a Server
+type ServerOption func(*Server)
+
+// WithToken rejects connections that do not pass the token as their token query parameter
+func WithToken(token string) ServerOption {
+	return func(s *Server) {
+		s.Token = token
+	}
+}
+
+// WithScript sends messages to each connection once its first listen request is acknowledged. Messages are encoded by Encode.
+// Messages the connection is not listening for when they are due are skipped.
+func WithScript(messages ...any) ServerOption {
+	return func(s *Server) {
+		for _, m := range messages {
+			b, err := Encode(m)
+			if err != nil {
+				panic("simulate: invalid script message: " + err.Error())
+			}
+			s.script = append(s.script, b)
+		}
+	}
+}
+
+// WithInterval waits between scripted messages, the first message is sent without waiting
+func WithInterval(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.interval = d
+	}
+}
+
+// WithoutAcks never acknowledges listen requests, for testing ack timeouts
+func WithoutAcks() ServerOption {
+	return func(s *Server) {
+		s.acks = false
+	}
+}
+
+// NewServer creates a server to mount on Path of an http server
+func NewServer(opts ...ServerOption) *Server {
+	s := &Server{
+		Path:  DefaultPath,
+		acks:  true,
+		conns: make(map[*serverConn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Send sends a message to every connection listening for it, encoded by Encode
+func (s *Server) Send(ctx context.Context, v any) error {
+	b, err := Encode(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		if err := c.send(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Requests returns the listen requests received so far, from every connection
+func (s *Server) Requests() []tempest.RequestMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tempest.RequestMessage(nil), s.requests...)
+}
+
+// Connections returns the number of open connections
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Disconnect closes every open connection with status going away, the server keeps accepting new connections
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = make(map[*serverConn]struct{})
+	s.mu.Unlock()
+
+	for c := range conns {
+		c.ws.Close(websocket.StatusGoingAway, "")
+	}
+}
+
+// ServeHTTP accepts a websocket connection and speaks the WeatherFlow protocol on it until the client disconnects
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && r.URL.Query().Get("token") != s.Token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.CloseNow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &serverConn{ws: ws, subs: make(map[tempest.Subscription]bool)}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+
+	if err := c.write(ctx, api.ConnectionOpened{Type: string(tempest.EventConnectionOpened)}); err != nil {
+		return
+	}
+
+	var play sync.Once
+	for {
+		_, b, err := ws.Read(ctx)
+		if err != nil {
+			return
+		}
+
+		var req tempest.RequestMessage
+		if err := json.Unmarshal(b, &req); err != nil || req.Type == "" {
+			continue
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+		c.apply(req)
+
+		if !s.acks {
+			continue
+		}
+		if err := c.write(ctx, api.Ack{Type: string(tempest.EventAck), ID: req.ID}); err != nil {
+			return
+		}
+		play.Do(func() { go s.play(ctx, c) })
+	}
+}
+
+// play sends the script to a connection
+func (s *Server) play(ctx context.Context, c *serverConn) {
+	for i, b := range s.script {
+		if i > 0 && s.interval > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.interval):
+			}
+		}
+
+		if err := c.send(ctx, b); err != nil {
+			return
+		}
+	}
+}
+
+// serverConn is a connection to the server and the listen groups it has started
+type serverConn struct {
+	ws   *websocket.Conn
+	mu   sync.Mutex
+	subs map[tempest.Subscription]bool
+}
+
+// apply starts or stops the listen group of a request
+func (c *serverConn) apply(req tempest.RequestMessage) {
+	id := req.Device
+	if req.Station != 0 {
+		id = req.Station
+	}
+	group := tempest.ListenGroup(req.Type)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := tempest.StopGroup(group); ok {
+		c.subs[tempest.Subscription{Group: group, ID: id}] = true
+		return
+	}
+
+	for _, start := range []tempest.ListenGroup{tempest.ListenGroupStart, tempest.ListenGroupStartEvents, tempest.ListenGroupRapidStart} {
+		if stop, _ := tempest.StopGroup(start); stop == group {
+			delete(c.subs, tempest.Subscription{Group: start, ID: id})
+		}
+	}
+}
+
+// listening reports whether the connection has started the listen group a message belongs to
+func (c *serverConn) listening(b []byte) bool {
+	var m struct {
+		Type    string `json:"type"`
+		Device  int    `json:"device_id"`
+		Station int    `json:"station_id"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case tempest.Event(m.Type) == tempest.EventRapidWind:
+		return c.subs[tempest.Subscription{Group: tempest.ListenGroupRapidStart, ID: m.Device}]
+	case m.Device != 0:
+		return c.subs[tempest.Subscription{Group: tempest.ListenGroupStart, ID: m.Device}]
+	case m.Station != 0:
+		return c.subs[tempest.Subscription{Group: tempest.ListenGroupStartEvents, ID: m.Station}]
+	default:
+		return true
+	}
+}
+
+// send writes a message if the connection is listening for it
+func (c *serverConn) send(ctx context.Context, b []byte) error {
+	if !c.listening(b) {
+		return nil
+	}
+	return c.ws.Write(ctx, websocket.MessageText, b)
+}
+
+func (c *serverConn) write(ctx context.Context, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.ws.Write(ctx, websocket.MessageText, b)
+}
+
+// Encode returns the json of a message, passing []byte, json.RawMessage and string values through as they are
+func Encode(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case []byte:
+		return m, nil
+	case json.RawMessage:
+		return m, nil
+	case string:
+		return []byte(strings.TrimSpace(m)), nil
+	default:
+		return json.Marshal(v)
+	}
+}
@@ -0,0 +1,404 @@
+// Package simulate generates the traffic of a synthetic tempest station, for demos and load tests without a device, and
+// sends it the way a hub broadcasts it over udp or the WeatherFlow websocket api serves it.
+package simulate
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+const (
+	defaultRapidInterval  = 3 * time.Second
+	defaultReportInterval = time.Minute
+	defaultMeanTemp       = 15.0
+	defaultPressure       = 1013.25
+
+	// a front starts on average this often, and a storm this often during afternoons
+	frontEvery = 72 * time.Hour
+	stormEvery = 32 * time.Hour
+	// rain that starts after this long dry raises a new evt_precip
+	dryAfter = time.Hour
+)
+
+// Message is a generated message and the simulated time it was sent at
+type Message struct {
+	Time  time.Time
+	Event api.Event
+}
+
+// Station simulates the weather at a tempest station. Temperature and solar radiation follow the time of day, fronts pass
+// with a pressure drop, rain, a wind shift and cooling behind them, and afternoon thunderstorms approach with lightning that
+// closes in, then heavy rain and gusts as the storm passes overhead. Given the same seed and start a Station generates the same messages.
+type Station struct {
+	rng            *rand.Rand
+	device         int
+	now            time.Time
+	rapidInterval  time.Duration
+	reportInterval time.Duration
+	nextReport     time.Time
+	meanTemp       float64
+
+	windSpeed float64
+	windDir   float64
+	pressure  float64
+	front     *front
+	storm     *storm
+	// chill is the cooling left behind by a front, fading over a day or so
+	chill     float64
+	lastRain  time.Time
+	dailyRain float64
+	day       int
+
+	report report
+}
+
+// front is a passing weather front, phase runs from 0 to 1 over its duration with the front overhead at 0.5
+type front struct {
+	start    time.Time
+	duration time.Duration
+	drop     float64
+	cooling  float64
+	rainRate float64
+}
+
+// storm is a thunderstorm cell moving past the station
+type storm struct {
+	distance    float64
+	closest     float64
+	speed       float64
+	intensity   float64
+	approaching bool
+}
+
+// report accumulates the samples of one observation
+type report struct {
+	windSum, windMin, windMax float64
+	dirX, dirY                float64
+	samples                   int
+	rain                      float64
+	strikes                   int
+	strikeDistance            float64
+}
+
+// Option configures a Station
+type Option func(*Station)
+
+// WithSeed seeds the random weather, stations with the same seed and start generate the same messages
+func WithSeed(seed uint64) Option {
+	return func(s *Station) {
+		s.rng = rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	}
+}
+
+// WithDevice sets the device id of generated messages. Defaults to 1.
+func WithDevice(id int) Option {
+	return func(s *Station) {
+		s.device = id
+	}
+}
+
+// WithReportInterval sets how often observations are generated. Defaults to a minute.
+func WithReportInterval(d time.Duration) Option {
+	return func(s *Station) {
+		if d > 0 {
+			s.reportInterval = d
+		}
+	}
+}
+
+// WithMeanTemperature sets the daily mean temperature in celsius. Defaults to 15.
+func WithMeanTemperature(c float64) Option {
+	return func(s *Station) {
+		s.meanTemp = c
+	}
+}
+
+// New creates a station whose simulated clock starts at start
+func New(start time.Time, opts ...Option) *Station {
+	s := &Station{
+		rng:            rand.New(rand.NewPCG(1, 2)),
+		device:         1,
+		now:            start,
+		rapidInterval:  defaultRapidInterval,
+		reportInterval: defaultReportInterval,
+		meanTemp:       defaultMeanTemp,
+		pressure:       defaultPressure,
+		windSpeed:      2.5,
+		windDir:        200,
+		day:            start.YearDay(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.nextReport = start.Add(s.reportInterval)
+	s.lastRain = start.Add(-dryAfter)
+	s.report = newReport()
+	return s
+}
+
+// Now returns the simulated time of the next step
+func (s *Station) Now() time.Time {
+	return s.now
+}
+
+// Next advances the simulation by one rapid wind interval and returns the messages sent during it, in time order
+func (s *Station) Next() []Message {
+	t := s.now
+	dt := s.rapidInterval.Minutes()
+	s.now = s.now.Add(s.rapidInterval)
+
+	s.startWeather(t)
+
+	var msgs []Message
+
+	// wind drifts around a calm mean, and picks up ahead of fronts and in storm outflow
+	s.windSpeed += (2.5-s.windSpeed)*0.02 + s.rng.NormFloat64()*0.15
+	s.windSpeed = math.Max(0, s.windSpeed)
+	s.windDir = math.Mod(s.windDir+s.rng.NormFloat64()*2+360, 360)
+	s.pressure += (defaultPressure-s.pressure)*0.0005 + s.rng.NormFloat64()*0.01
+	s.chill *= math.Exp(-dt / (18 * 60))
+
+	speed, dir := s.windSpeed, s.windDir
+	rain := 0.0
+
+	if f := s.front; f != nil {
+		phase := float64(t.Sub(f.start)) / float64(f.duration)
+		speed += 3 * math.Sin(math.Pi*phase)
+		dir = math.Mod(dir+100*smoothstep(0.4, 0.6, phase), 360)
+		if phase > 0.4 && phase < 0.7 {
+			rain += f.rainRate * dt
+		}
+		if phase >= 1 {
+			s.chill += f.cooling
+			s.windDir = dir
+			s.front = nil
+		}
+	}
+
+	if st := s.storm; st != nil {
+		if st.approaching {
+			st.distance -= st.speed * dt
+			if st.distance <= st.closest {
+				st.approaching = false
+			}
+		} else {
+			st.distance += st.speed * dt
+		}
+
+		if st.distance < 10 {
+			speed += 6 * (1 - st.distance/10)
+			rain += st.intensity * (1 - st.distance/10) * dt
+		}
+
+		// strikes are more frequent as the cell gets closer
+		rate := st.intensity * 4 * (0.3 + math.Exp(-st.distance/12)) * dt
+		for range poisson(s.rng, rate) {
+			at := t.Add(time.Duration(s.rng.Float64() * float64(s.rapidInterval)))
+			distance := max(1, int(math.Round(st.distance+s.rng.NormFloat64()*2)))
+			msgs = append(msgs, Message{Time: at, Event: api.LightningStrikeEvent{
+				Type:   string(tempest.EventLightingStrike),
+				Device: s.device,
+				Strike: api.LightningStrike{TimeEpoch: int(at.Unix()), DistanceInKM: distance, Energy: 500 + s.rng.IntN(8000)},
+			}})
+			s.report.strikes++
+			s.report.strikeDistance += float64(distance)
+		}
+
+		if !st.approaching && st.distance > 40 {
+			s.storm = nil
+		}
+	}
+
+	if rain > 0 {
+		if t.Sub(s.lastRain) >= dryAfter {
+			msgs = append(msgs, Message{Time: t, Event: api.PrecipitationEvent{
+				Type:   string(tempest.EventPrecipitation),
+				Device: s.device,
+				Data:   api.PrecipitationData{TimeEpoch: int(t.Unix())},
+			}})
+		}
+		s.lastRain = t
+	}
+
+	sample := math.Max(0, speed+s.rng.NormFloat64()*speed*0.2)
+	msgs = append(msgs, Message{Time: t, Event: api.RapidWind{
+		Type:   string(tempest.EventRapidWind),
+		Device: s.device,
+		Data:   api.RapidWindData{TimeEpoch: int(t.Unix()), WindSpeed: round(sample, 2), WindDirectionDegrees: math.Round(dir)},
+	}})
+
+	s.report.add(sample, dir, rain)
+
+	if !s.now.Before(s.nextReport) {
+		msgs = append(msgs, Message{Time: s.nextReport, Event: s.observe(s.nextReport)})
+		s.nextReport = s.nextReport.Add(s.reportInterval)
+	}
+
+	slices.SortStableFunc(msgs, func(a, b Message) int { return a.Time.Compare(b.Time) })
+	return msgs
+}
+
+// startWeather starts fronts and storms at random, storms only on warm afternoons
+func (s *Station) startWeather(t time.Time) {
+	if s.front == nil && s.rng.Float64() < float64(s.rapidInterval)/float64(frontEvery) {
+		s.front = &front{
+			start:    t,
+			duration: time.Duration(6+s.rng.IntN(7)) * time.Hour,
+			drop:     6 + s.rng.Float64()*10,
+			cooling:  2 + s.rng.Float64()*5,
+			rainRate: 0.01 + s.rng.Float64()*0.05,
+		}
+	}
+
+	hour := float64(t.Hour()) + float64(t.Minute())/60
+	if s.storm == nil && hour >= 12 && hour <= 20 && s.rng.Float64() < float64(s.rapidInterval)/float64(stormEvery) {
+		s.storm = &storm{
+			distance:    35 + s.rng.Float64()*10,
+			closest:     s.rng.Float64() * 6,
+			speed:       0.3 + s.rng.Float64()*0.5,
+			intensity:   0.3 + s.rng.Float64()*0.9,
+			approaching: true,
+		}
+	}
+}
+
+// observe summarises the samples since the last observation
+func (s *Station) observe(t time.Time) api.ObservationTempest {
+	r := s.report
+	s.report = newReport()
+
+	if t.YearDay() != s.day {
+		s.day = t.YearDay()
+		s.dailyRain = 0
+	}
+	s.dailyRain += r.rain
+
+	hour := float64(t.Hour()) + float64(t.Minute())/60
+	daylight := math.Max(0, math.Sin(math.Pi*(hour-6)/12))
+
+	clouds := 0.1
+	temp := s.meanTemp + 6*math.Cos(2*math.Pi*(hour-15)/24) - s.chill
+	pressure := s.pressure
+
+	if f := s.front; f != nil {
+		phase := float64(t.Sub(f.start)) / float64(f.duration)
+		pressure -= f.drop * math.Sin(math.Pi*phase)
+		temp -= f.cooling * smoothstep(0.45, 0.8, phase)
+		clouds = math.Max(clouds, 0.8*math.Sin(math.Pi*phase))
+	}
+	if st := s.storm; st != nil && st.distance < 20 {
+		clouds = math.Max(clouds, 0.9*(1-st.distance/20))
+		temp -= 5 * (1 - st.distance/20)
+		pressure += 2 * (1 - st.distance/20)
+	}
+
+	solar := 950 * daylight * (1 - clouds)
+	humidity := 70 - 2*(temp-s.meanTemp)
+	if r.rain > 0 {
+		humidity = 90 + 8*s.rng.Float64()
+	}
+
+	o := api.ObservationTempestData{
+		TimeEpoch:                  int(t.Unix()),
+		WindSampleInterval:         int(s.rapidInterval.Seconds()),
+		StationPressure:            round(pressure, 2),
+		AirTemperature:             round(temp+s.rng.NormFloat64()*0.1, 2),
+		RelativeHumidity:           int(math.Round(math.Min(100, math.Max(15, humidity)))),
+		Illuminance:                int(solar * 120),
+		UltraviolentIndex:          round(solar/90, 2),
+		SolarRadiation:             int(solar),
+		RainAccumulated:            round(r.rain, 2),
+		LightningStrikeCount:       r.strikes,
+		BatteryVolts:               2.6,
+		ReportInterval:             int(s.reportInterval.Minutes()),
+		LocalDailyRainAccumulation: round(s.dailyRain, 2),
+		PrecipitationAnalysisType:  1,
+	}
+
+	if r.samples > 0 {
+		o.WindLull = round(r.windMin, 2)
+		o.WindAverage = round(r.windSum/float64(r.samples), 2)
+		o.WindGust = round(r.windMax, 2)
+		o.WindDirectionDegrees = math.Round(math.Mod(math.Atan2(r.dirY, r.dirX)*180/math.Pi+360, 360))
+	}
+	if r.rain > 0 {
+		o.PrecipitationType = 1
+	}
+	if r.strikes > 0 {
+		o.LightningStrikeAverageDistance = round(r.strikeDistance/float64(r.strikes), 1)
+	}
+
+	return api.ObservationTempest{Type: string(tempest.EventObservationTempest), Device: s.device, Data: o}
+}
+
+// Run steps the simulation until ctx is cancelled or duration of simulated time has passed, passing every message to send.
+// Simulated time runs speed times faster than real time. A speed of zero or less sends messages as fast as send returns.
+func (s *Station) Run(ctx context.Context, speed float64, duration time.Duration, send func(ctx context.Context, m Message) error) error {
+	start := s.now
+	wall := time.Now()
+
+	for duration <= 0 || s.now.Sub(start) < duration {
+		for _, m := range s.Next() {
+			if speed > 0 {
+				due := wall.Add(time.Duration(float64(m.Time.Sub(start)) / speed))
+				if wait := time.Until(due); wait > 0 {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(wait):
+					}
+				}
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := send(ctx, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newReport() report {
+	return report{windMin: math.Inf(1)}
+}
+
+func (r *report) add(speed, dir, rain float64) {
+	r.windSum += speed
+	r.windMin = math.Min(r.windMin, speed)
+	r.windMax = math.Max(r.windMax, speed)
+	r.dirX += math.Cos(dir * math.Pi / 180)
+	r.dirY += math.Sin(dir * math.Pi / 180)
+	r.samples++
+	r.rain += rain
+}
+
+// poisson draws the number of events in an interval with the given expected count
+func poisson(rng *rand.Rand, mean float64) int {
+	limit := math.Exp(-mean)
+	n := 0
+	for p := rng.Float64(); p > limit; p *= rng.Float64() {
+		n++
+	}
+	return n
+}
+
+// smoothstep rises smoothly from 0 at edge0 to 1 at edge1
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := math.Min(1, math.Max(0, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,92 @@
+package simulate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+var start = time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+func TestSeedIsReproducible(t *testing.T) {
+	generate := func(seed uint64) []Message {
+		var msgs []Message
+		New(start, WithSeed(seed)).Run(context.Background(), 0, 6*time.Hour, func(ctx context.Context, m Message) error {
+			msgs = append(msgs, m)
+			return nil
+		})
+		return msgs
+	}
+
+	if !reflect.DeepEqual(generate(7), generate(7)) {
+		t.Error("the same seed generated different messages")
+	}
+	if reflect.DeepEqual(generate(7), generate(8)) {
+		t.Error("different seeds generated the same messages")
+	}
+}
+
+func TestDiurnalCycle(t *testing.T) {
+	s := New(start, WithSeed(1))
+
+	obs := make(map[int]api.ObservationTempestData)
+	var last time.Time
+	s.Run(context.Background(), 0, 24*time.Hour, func(ctx context.Context, m Message) error {
+		if m.Time.Before(last) {
+			t.Fatalf("message at %s sent after %s", m.Time, last)
+		}
+		last = m.Time
+
+		if o, ok := m.Event.(api.ObservationTempest); ok {
+			obs[m.Time.Hour()*60+m.Time.Minute()] = o.Data
+		}
+		return nil
+	})
+
+	if len(obs) != 24*60 {
+		t.Fatalf("generated %d observations, want one a minute", len(obs))
+	}
+
+	night, afternoon := obs[4*60], obs[15*60]
+	if night.SolarRadiation != 0 {
+		t.Errorf("solar radiation at 4am = %d, want 0", night.SolarRadiation)
+	}
+	if afternoon.SolarRadiation == 0 {
+		t.Error("solar radiation at 3pm = 0")
+	}
+	if afternoon.AirTemperature <= night.AirTemperature {
+		t.Errorf("afternoon temperature %.1f is not warmer than night %.1f", afternoon.AirTemperature, night.AirTemperature)
+	}
+}
+
+func TestStormApproaches(t *testing.T) {
+	s := New(start.Add(14*time.Hour), WithSeed(3))
+	s.storm = &storm{distance: 30, closest: 2, speed: 0.5, intensity: 1, approaching: true}
+
+	var strikes []api.LightningStrike
+	var rain []api.PrecipitationEvent
+	for s.storm != nil && s.storm.approaching {
+		for _, m := range s.Next() {
+			switch e := m.Event.(type) {
+			case api.LightningStrikeEvent:
+				strikes = append(strikes, e.Strike)
+			case api.PrecipitationEvent:
+				rain = append(rain, e)
+			}
+		}
+	}
+
+	if len(strikes) < 10 {
+		t.Fatalf("generated %d strikes, want a storm's worth", len(strikes))
+	}
+	first, lastStrike := strikes[0], strikes[len(strikes)-1]
+	if lastStrike.DistanceInKM >= first.DistanceInKM {
+		t.Errorf("strikes moved from %dkm to %dkm, want them to close in", first.DistanceInKM, lastStrike.DistanceInKM)
+	}
+	if len(rain) != 1 {
+		t.Errorf("generated %d evt_precip events, want 1 as the storm arrives", len(rain))
+	}
+}
@@ -0,0 +1,212 @@
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultUDPPort is the port a tempest hub broadcasts its messages on
+const DefaultUDPPort = 50222
+
+// hubObservationFields are the values of an observation row a hub broadcasts, ending at the report interval
+const hubObservationFields = 18
+
+// DefaultHubSerial is the serial number a UDPHub sends as its hub_sn
+const DefaultHubSerial = "HB-00000001"
+
+// UDPHub imitates a tempest hub broadcasting messages on the local network, for the simulate command and for testing udp
+// listeners. Every message is sent to each receiver: the addresses added with WithBroadcast and any address that has sent
+// the hub a datagram, such as the listen request a listener writes on a connection.NewUDP connection to Addr. Like a hub it
+// ignores what receivers send and never acknowledges. Messages are sent in the hub's format, see Send.
+type UDPHub struct {
+	conn    *net.UDPConn
+	serials map[int]string
+	mu      sync.Mutex
+	targets []*net.UDPAddr
+	peers   map[string]*net.UDPAddr
+	done    chan struct{}
+}
+
+// UDPOption configures a UDPHub
+type UDPOption func(*UDPHub) error
+
+// WithBroadcast also sends every message to an address, such as "255.255.255.255:50222" to reach listeners on the local network
+func WithBroadcast(addr string) UDPOption {
+	return func(h *UDPHub) error {
+		a, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return err
+		}
+		h.targets = append(h.targets, a)
+		return nil
+	}
+}
+
+// WithSerial sets the serial number a device is named by, "ST-" and the device id padded to 8 digits by default
+func WithSerial(device int, serial string) UDPOption {
+	return func(h *UDPHub) error {
+		h.serials[device] = serial
+		return nil
+	}
+}
+
+// NewUDPHub binds a hub to a local address, "127.0.0.1:0" when addr is empty. Close it when done.
+func NewUDPHub(addr string, opts ...UDPOption) (*UDPHub, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &UDPHub{
+		serials: make(map[int]string),
+		peers:   make(map[string]*net.UDPAddr),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return nil, err
+		}
+	}
+
+	h.conn, err = net.ListenUDP("udp", a)
+	if err != nil {
+		return nil, err
+	}
+
+	go h.accept()
+	return h, nil
+}
+
+// Addr returns the address the hub is bound to, for connection.NewUDP
+func (h *UDPHub) Addr() string {
+	return h.conn.LocalAddr().String()
+}
+
+// Receivers returns the number of addresses the hub sends to
+func (h *UDPHub) Receivers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.targets) + len(h.peers)
+}
+
+// Send sends a message to every receiver, encoded by Encode and then rewritten in the format a hub broadcasts, see hubFormat. Messages already in the hub's format are sent as they are.
+func (h *UDPHub) Send(v any) error {
+	b, err := Encode(v)
+	if err != nil {
+		return err
+	}
+	if b, err = h.hubFormat(b); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	addrs := append([]*net.UDPAddr(nil), h.targets...)
+	for _, a := range h.peers {
+		addrs = append(addrs, a)
+	}
+	h.mu.Unlock()
+
+	var errs []error
+	for _, a := range addrs {
+		if _, err := h.conn.WriteToUDP(b, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Play sends messages in order, waiting the interval between them, until they are all sent or ctx is cancelled
+func (h *UDPHub) Play(ctx context.Context, interval time.Duration, messages ...any) error {
+	for i, m := range messages {
+		if i > 0 && interval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		if err := h.Send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the hub
+func (h *UDPHub) Close() error {
+	close(h.done)
+	return h.conn.Close()
+}
+
+// accept registers every address that sends the hub a datagram as a receiver
+func (h *UDPHub) accept() {
+	buf := make([]byte, 1024)
+	for {
+		_, a, err := h.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-h.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		h.mu.Lock()
+		h.peers[a.String()] = a
+		h.mu.Unlock()
+	}
+}
+
+// hubFormat rewrites a message of the websocket api as a hub broadcasts it: the device is named by its serial number instead
+// of its device id, the hub adds its own serial number, and observation rows end at the report interval, without the rain
+// fields the cloud computes. Messages without a device id are returned as they are.
+func (h *UDPHub) hubFormat(b []byte) ([]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("simulate: message is not a json object: %w", err)
+	}
+
+	var device int
+	if raw, ok := m["device_id"]; !ok || json.Unmarshal(raw, &device) != nil || device == 0 {
+		return b, nil
+	}
+
+	serial, ok := h.serials[device]
+	if !ok {
+		serial = fmt.Sprintf("ST-%08d", device)
+	}
+
+	hub := map[string]any{
+		"serial_number": serial,
+		"type":          m["type"],
+		"hub_sn":        DefaultHubSerial,
+	}
+	for _, field := range []string{"ob", "evt"} {
+		if v, ok := m[field]; ok {
+			hub[field] = v
+		}
+	}
+	if v, ok := m["obs"]; ok {
+		var rows [][]any
+		if err := json.Unmarshal(v, &rows); err != nil {
+			return nil, fmt.Errorf("simulate: invalid observation rows: %w", err)
+		}
+		for i, row := range rows {
+			rows[i] = row[:min(len(row), hubObservationFields)]
+		}
+		hub["obs"] = rows
+	}
+
+	return json.Marshal(hub)
+}
@@ -0,0 +1,126 @@
+package simulate
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+func TestUDPHub(t *testing.T) {
+	hub, err := NewUDPHub("")
+	if err != nil {
+		t.Fatalf("NewUDPHub() error = %v", err)
+	}
+	defer hub.Close()
+
+	conn, err := connection.NewUDP(context.Background(), hub.Addr())
+	if err != nil {
+		t.Fatalf("NewUDP() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var strikes []api.LightningStrike
+	l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1)
+	l.AddHandler(tempest.EventLightingStrike, func(ctx context.Context, b []byte) {
+		e, _ := tempest.Decode[api.LightningStrikeEvent](ctx, b)
+		mu.Lock()
+		strikes = append(strikes, e.Strike)
+		mu.Unlock()
+	})
+	go l.Listen(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for hub.Receivers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("listener did not register with the hub")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := hub.Play(ctx, 0, `{"type":"evt_strike","device_id":1,"evt":[1493322445,27,3848]}`, `{"type":"evt_strike","device_id":1,"evt":[1493322446,12,1200]}`); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+
+	deadline = time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(strikes)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d strikes, want 2", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if strikes[1].DistanceInKM != 12 {
+		t.Errorf("second strike = %+v, want 12km", strikes[1])
+	}
+}
+
+func TestUDPHubFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []UDPOption
+		message any
+		want    string
+	}{
+		{
+			name:    "observations name the device by serial number and end at the report interval",
+			message: `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.8,1.9,3.1,210,3,1013.2,18.5,62,24000,2.1,200,0,0,0,0,2.6,1,0,0,0,1]]}`,
+			want:    `{"hub_sn":"HB-00000001","obs":[[1588948614,0.8,1.9,3.1,210,3,1013.2,18.5,62,24000,2.1,200,0,0,0,0,2.6,1]],"serial_number":"ST-00000001","type":"obs_st"}`,
+		},
+		{
+			name:    "events keep their data",
+			opts:    []UDPOption{WithSerial(1, "ST-00000512")},
+			message: `{"type":"evt_strike","device_id":1,"evt":[1588948614,27,3848]}`,
+			want:    `{"evt":[1588948614,27,3848],"hub_sn":"HB-00000001","serial_number":"ST-00000512","type":"evt_strike"}`,
+		},
+		{
+			name:    "messages in the hub's format are sent as they are",
+			message: `{"serial_number":"ST-00000512","type":"rapid_wind","hub_sn":"HB-00013030","ob":[1493322445,2.3,128]}`,
+			want:    `{"serial_number":"ST-00000512","type":"rapid_wind","hub_sn":"HB-00013030","ob":[1493322445,2.3,128]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer receiver.Close()
+
+			hub, err := NewUDPHub("", append(tt.opts, WithBroadcast(receiver.LocalAddr().String()))...)
+			if err != nil {
+				t.Fatalf("NewUDPHub() error = %v", err)
+			}
+			defer hub.Close()
+
+			if err := hub.Send(tt.message); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			receiver.SetReadDeadline(time.Now().Add(time.Second))
+			buf := make([]byte, 1024)
+			n, err := receiver.Read(buf)
+			if err != nil {
+				t.Fatalf("receiver did not get the message: %v", err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Errorf("sent %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Compact rewrites the store without replaced records, ordered by device and time. The new segments are written to
+// temporary files and renamed into place before the old segments are removed, so a crash during compaction leaves either
+// the old segments, or both the old and the new ones, which hold the same records. Appends wait until compaction is done.
+func (s *Store) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return ErrClosed
+	}
+
+	old := s.segments
+	out := &compaction{dir: s.dir, max: s.maxSegmentSize, seq: s.active.seq}
+	defer out.abort()
+
+	devices := slices.Sorted(maps.Keys(s.devices))
+	moved := make(map[int][]entry, len(devices))
+
+	for _, device := range devices {
+		entries := slices.Clone(s.devices[device].entries)
+		for i, e := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			payload, err := old[e.loc.seq].read(e.loc.off, e.loc.n)
+			if err != nil {
+				return err
+			}
+
+			loc, err := out.append(payload)
+			if err != nil {
+				return err
+			}
+			entries[i].loc = loc
+		}
+		moved[device] = entries
+	}
+
+	segments, err := out.commit()
+	if err != nil {
+		return err
+	}
+
+	s.segments = make(map[int]*segment, len(segments)+1)
+	s.bytes, s.garbage = 0, 0
+	for _, seg := range segments {
+		s.segments[seg.seq] = seg
+		s.bytes += seg.size
+	}
+	for device, entries := range moved {
+		s.devices[device].entries = entries
+	}
+
+	// the new segments are in place, the old ones only hold replaced records and copies of the new ones
+	var errs []error
+	for _, seg := range old {
+		seg.f.Close()
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, s.rotate(out.seq+1))
+	return errors.Join(errs...)
+}
+
+// compaction writes the segments of a compacted store as temporary files
+type compaction struct {
+	dir      string
+	max      int64
+	seq      int
+	current  *segment
+	segments []*segment
+}
+
+func (c *compaction) append(payload []byte) (location, error) {
+	if c.current == nil || c.current.size >= c.max {
+		c.seq++
+		f, err := os.OpenFile(segmentPath(c.dir, c.seq)+tmpExt, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return location{}, err
+		}
+		c.current = &segment{seq: c.seq, path: f.Name(), f: f}
+		c.segments = append(c.segments, c.current)
+	}
+
+	off, err := c.current.append(payload)
+	return location{seq: c.current.seq, off: off, n: len(payload)}, err
+}
+
+// commit syncs the temporary segments and renames them into place
+func (c *compaction) commit() ([]*segment, error) {
+	for _, seg := range c.segments {
+		if err := seg.f.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, seg := range c.segments {
+		path := strings.TrimSuffix(seg.path, tmpExt)
+		if err := os.Rename(seg.path, path); err != nil {
+			return nil, err
+		}
+		seg.path = path
+	}
+
+	if err := syncDir(c.dir); err != nil {
+		return nil, err
+	}
+
+	segments := c.segments
+	c.segments = nil
+	return segments, nil
+}
+
+// abort removes the temporary segments of a compaction that did not commit
+func (c *compaction) abort() {
+	for _, seg := range c.segments {
+		seg.f.Close()
+		os.Remove(seg.path)
+	}
+}
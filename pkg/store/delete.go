@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+)
+
+// deleteType is the type of the records that delete earlier records
+const deleteType = "store_delete"
+
+// tombstone is the data of a delete record, the range of records of a device it deletes
+type tombstone struct {
+	Types []string `json:"types,omitempty"`
+	Start int64    `json:"start,omitempty"`
+	End   int64    `json:"end,omitempty"`
+}
+
+func (t tombstone) query(device int) Query {
+	q := Query{Device: device, Types: t.Types}
+	if t.Start != 0 {
+		q.Start = time.Unix(t.Start, 0)
+	}
+	if t.End != 0 {
+		q.End = time.Unix(t.End, 0)
+	}
+	return q
+}
+
+// Delete removes the records matching q, ignoring its Limit, and returns the number of records and bytes removed. The store
+// is append-only, so the delete is recorded as a tombstone that is replayed when the store is opened, and the space is
+// reclaimed by Compact. Records appended afterwards are kept even when they match q.
+func (s *Store) Delete(ctx context.Context, q Query) (Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	t := tombstone{Types: q.Types}
+	if !q.Start.IsZero() {
+		t.Start = q.Start.Unix()
+	}
+	if !q.End.IsZero() {
+		t.End = q.End.Unix()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return Stats{}, ErrClosed
+	}
+
+	removed := s.count(t.query(q.Device))
+	if removed.Records == 0 {
+		return removed, nil
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return Stats{}, err
+	}
+	payload, err := json.Marshal(Record{Type: deleteType, Device: q.Device, Data: data})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if _, err := s.active.append(payload); err != nil {
+		return Stats{}, err
+	}
+	if s.sync {
+		if err := s.active.f.Sync(); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	s.bytes += headerSize + int64(len(payload))
+	s.remove(t.query(q.Device))
+	s.garbage += headerSize + int64(len(payload))
+
+	if s.active.size >= s.maxSegmentSize {
+		return removed, s.rotate(s.active.seq + 1)
+	}
+	return removed, nil
+}
+
+// Count returns the number of records matching q, ignoring its Limit, and the bytes they take up
+func (s *Store) Count(q Query) Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.count(q)
+}
+
+// Types returns the types of the records stored for a device
+func (s *Store) Types(device int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser, ok := s.devices[device]
+	if !ok {
+		return nil
+	}
+
+	types := make(map[string]struct{})
+	for _, e := range ser.entries {
+		types[e.typ] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(types))
+}
+
+func (s *Store) count(q Query) Stats {
+	ser, ok := s.devices[q.Device]
+	if !ok {
+		return Stats{}
+	}
+
+	var stats Stats
+	for _, e := range ser.between(q.Start, q.End) {
+		if q.matches(e) {
+			stats.Records++
+			stats.Bytes += headerSize + int64(e.loc.n)
+		}
+	}
+	return stats
+}
+
+// remove drops the records matching q from the index, counting them as garbage
+func (s *Store) remove(q Query) {
+	ser, ok := s.devices[q.Device]
+	if !ok {
+		return
+	}
+
+	ser.entries = slices.DeleteFunc(ser.entries, func(e entry) bool {
+		if !q.matches(e) || (!q.Start.IsZero() && e.epoch < q.Start.Unix()) || (!q.End.IsZero() && e.epoch >= q.End.Unix()) {
+			return false
+		}
+		s.garbage += headerSize + int64(e.loc.n)
+		return true
+	})
+	if len(ser.entries) == 0 {
+		delete(s.devices, q.Device)
+	}
+}
+
+// replayDelete applies a delete record read when opening the store
+func (s *Store) replayDelete(r Record, n int) error {
+	var t tombstone
+	if err := json.Unmarshal(r.Data, &t); err != nil {
+		return err
+	}
+	s.remove(t.query(r.Device))
+	s.garbage += headerSize + int64(n)
+	return nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoEpoch is returned for messages that carry no time, such as acks
+var ErrNoEpoch = errors.New("message has no epoch")
+
+// Record is a stored message. Data holds the message as it was received.
+type Record struct {
+	Type    string          `json:"type"`
+	Device  int             `json:"device_id,omitempty"`
+	Station int             `json:"station_id,omitempty"`
+	Epoch   int64           `json:"epoch"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Time returns the time of the record
+func (r Record) Time() time.Time {
+	return time.Unix(r.Epoch, 0)
+}
+
+// FromMessage creates a record from a tempest message, taking its time from the first value of its obs, ob or evt data
+func FromMessage(b []byte) (Record, error) {
+	var m struct {
+		Type    string  `json:"type"`
+		Device  int     `json:"device_id"`
+		Station int     `json:"station_id"`
+		Obs     [][]any `json:"obs"`
+		Ob      []any   `json:"ob"`
+		Evt     []any   `json:"evt"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return Record{}, fmt.Errorf("invalid message: %w", err)
+	}
+
+	var epoch any
+	switch {
+	case len(m.Obs) > 0 && len(m.Obs[0]) > 0:
+		epoch = m.Obs[0][0]
+	case len(m.Ob) > 0:
+		epoch = m.Ob[0]
+	case len(m.Evt) > 0:
+		epoch = m.Evt[0]
+	}
+
+	e, ok := epoch.(float64)
+	if !ok || e <= 0 {
+		return Record{}, fmt.Errorf("%s: %w", m.Type, ErrNoEpoch)
+	}
+
+	var data bytes.Buffer
+	if err := json.Compact(&data, b); err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		Type:    m.Type,
+		Device:  m.Device,
+		Station: m.Station,
+		Epoch:   int64(e),
+		Data:    data.Bytes(),
+	}, nil
+}
+
+// key identifies a record, a record with the same key replaces it. Records are keyed on their time, so a backfilled
+// observation replaces the live one and an updated rollup the previous one. Events are keyed on their data as well since
+// several can share a second.
+func (r Record) key() string {
+	k := fmt.Sprintf("%s/%d/%d/%d", r.Type, r.Device, r.Station, r.Epoch)
+	if !strings.HasPrefix(r.Type, "evt_") {
+		return k
+	}
+
+	sum := sha256.Sum256(r.Data)
+	return k + "/" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentExt = ".seg"
+	tmpExt     = ".tmp"
+	// headerSize is the length and checksum before each frame's payload
+	headerSize = 8
+	// maxFrameSize guards against reading a corrupt length as a huge allocation
+	maxFrameSize = 16 << 20
+)
+
+// errTornFrame is returned for a frame that was only partly written, or whose checksum does not match
+var errTornFrame = errors.New("torn frame")
+
+// segment is one append-only file of the store. Each frame is a little endian uint32 payload length, the crc32 of the
+// payload and the payload, so a frame cut short by a crash is detected when the segment is read back.
+type segment struct {
+	seq  int
+	path string
+	f    *os.File
+	size int64
+}
+
+func segmentPath(dir string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d%s", seq, segmentExt))
+}
+
+// listSegments returns the sequence numbers of the segments in dir in order, removing temporary files left by an interrupted compaction
+func listSegments(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var seqs []int
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, tmpExt) {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		seq, err := strconv.Atoi(strings.TrimSuffix(name, segmentExt))
+		if !strings.HasSuffix(name, segmentExt) || err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+
+	slices.Sort(seqs)
+	return seqs, nil
+}
+
+func openSegment(dir string, seq int) (*segment, error) {
+	path := segmentPath(dir, seq)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &segment{seq: seq, path: path, f: f, size: info.Size()}, nil
+}
+
+// scan calls fn with the offset and payload of every frame. It stops at the first torn frame, returning its offset and errTornFrame.
+func (s *segment) scan(fn func(off int64, payload []byte) error) (int64, error) {
+	var off int64
+	header := make([]byte, headerSize)
+
+	for off < s.size {
+		if _, err := s.f.ReadAt(header, off); err != nil {
+			return off, errTornFrame
+		}
+
+		n := binary.LittleEndian.Uint32(header[0:4])
+		if n > maxFrameSize || off+headerSize+int64(n) > s.size {
+			return off, errTornFrame
+		}
+
+		payload := make([]byte, n)
+		if _, err := s.f.ReadAt(payload, off+headerSize); err != nil {
+			return off, errTornFrame
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+			return off, errTornFrame
+		}
+
+		if err := fn(off, payload); err != nil {
+			return off, err
+		}
+		off += headerSize + int64(n)
+	}
+
+	return off, nil
+}
+
+// truncate drops everything from off, used to remove a torn frame at the end of the last segment
+func (s *segment) truncate(off int64) error {
+	if err := s.f.Truncate(off); err != nil {
+		return err
+	}
+	s.size = off
+	return s.f.Sync()
+}
+
+// append writes a frame with a single write and returns its offset
+func (s *segment) append(payload []byte) (int64, error) {
+	frame := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[headerSize:], payload)
+
+	off := s.size
+	if _, err := s.f.WriteAt(frame, off); err != nil {
+		// drop whatever part of the frame made it to disk, so the next frame is not written after it
+		s.f.Truncate(off)
+		return 0, err
+	}
+
+	s.size += int64(len(frame))
+	return off, nil
+}
+
+// read returns the payload of the frame at off
+func (s *segment) read(off int64, n int) ([]byte, error) {
+	payload := make([]byte, n)
+	if _, err := s.f.ReadAt(payload, off+headerSize); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// syncDir makes renames and removals in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,387 @@
+// Package store is an embedded, append-only store of tempest observations and events with time range queries per device.
+package store
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+const defaultMaxSegmentSize = 64 << 20
+
+// ErrClosed is returned when using a closed store
+var ErrClosed = errors.New("store is closed")
+
+// Store keeps records in append-only segment files under a directory, with an in-memory index of each device's records by time.
+// Every frame is checksummed and, by default, synced before Append returns, so a crash loses at most the record being written;
+// a partly written frame is dropped when the store is opened again. A record with the same key as an earlier one replaces it,
+// and Compact rewrites the segments without the replaced records.
+type Store struct {
+	dir            string
+	maxSegmentSize int64
+	sync           bool
+
+	mu       sync.RWMutex
+	segments map[int]*segment
+	active   *segment
+	devices  map[int]*series
+	bytes    int64
+	garbage  int64
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithMaxSegmentSize sets the size at which a new segment file is started. Defaults to 64MiB.
+func WithMaxSegmentSize(n int64) Option {
+	return func(s *Store) {
+		if n > 0 {
+			s.maxSegmentSize = n
+		}
+	}
+}
+
+// WithSync sets whether each append is synced to disk before it returns. Defaults to true.
+func WithSync(sync bool) Option {
+	return func(s *Store) {
+		s.sync = sync
+	}
+}
+
+// Query selects the records of a device between Start, inclusive, and End, exclusive. A zero Start or End is unbounded,
+// no Types selects every type and a zero Limit returns every matching record.
+type Query struct {
+	Device int
+	Types  []string
+	Start  time.Time
+	End    time.Time
+	Limit  int
+}
+
+// Stats describes the size of a store. Garbage is the bytes held by replaced records, which Compact reclaims.
+type Stats struct {
+	Records  int   `json:"records"`
+	Segments int   `json:"segments"`
+	Bytes    int64 `json:"bytes"`
+	Garbage  int64 `json:"garbage_bytes"`
+}
+
+// location is where a record's frame is stored
+type location struct {
+	seq int
+	off int64
+	n   int
+}
+
+// entry is a record in the index
+type entry struct {
+	epoch int64
+	typ   string
+	key   string
+	loc   location
+}
+
+// series is the index of one device, ordered by time
+type series struct {
+	entries []entry
+}
+
+// Open opens the store in dir, creating it if needed, and indexes the records already stored
+func Open(dir string, opts ...Option) (*Store, error) {
+	s := &Store{
+		dir:            dir,
+		maxSegmentSize: defaultMaxSegmentSize,
+		sync:           true,
+		segments:       make(map[int]*segment),
+		devices:        make(map[int]*series),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	seqs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, seq := range seqs {
+		seg, err := openSegment(dir, seq)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.segments[seq] = seg
+
+		end, err := seg.scan(func(off int64, payload []byte) error {
+			var r Record
+			if err := json.Unmarshal(payload, &r); err != nil {
+				return fmt.Errorf("segment %s at offset %d: %w", seg.path, off, err)
+			}
+			if r.Type == deleteType {
+				return s.replayDelete(r, len(payload))
+			}
+			s.put(r, location{seq: seq, off: off, n: len(payload)})
+			return nil
+		})
+
+		switch {
+		case errors.Is(err, errTornFrame) && i == len(seqs)-1:
+			// the write in progress when the process stopped, drop it
+			if err := seg.truncate(end); err != nil {
+				s.Close()
+				return nil, err
+			}
+		case errors.Is(err, errTornFrame):
+			s.Close()
+			return nil, fmt.Errorf("segment %s is corrupt at offset %d", seg.path, end)
+		case err != nil:
+			s.Close()
+			return nil, err
+		}
+		s.bytes += seg.size
+	}
+
+	if len(seqs) > 0 && s.segments[seqs[len(seqs)-1]].size < s.maxSegmentSize {
+		s.active = s.segments[seqs[len(seqs)-1]]
+		return s, nil
+	}
+
+	next := 1
+	if len(seqs) > 0 {
+		next = seqs[len(seqs)-1] + 1
+	}
+	if err := s.rotate(next); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append stores a record
+func (s *Store) Append(ctx context.Context, r Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return ErrClosed
+	}
+
+	off, err := s.active.append(payload)
+	if err != nil {
+		return err
+	}
+	if s.sync {
+		if err := s.active.f.Sync(); err != nil {
+			return err
+		}
+	}
+
+	s.bytes += headerSize + int64(len(payload))
+	s.put(r, location{seq: s.active.seq, off: off, n: len(payload)})
+
+	if s.active.size >= s.maxSegmentSize {
+		return s.rotate(s.active.seq + 1)
+	}
+	return nil
+}
+
+// Query returns the records matching q in time order
+func (s *Store) Query(ctx context.Context, q Query) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.active == nil {
+		return nil, ErrClosed
+	}
+
+	ser, ok := s.devices[q.Device]
+	if !ok {
+		return nil, nil
+	}
+
+	var records []Record
+	for _, e := range ser.between(q.Start, q.End) {
+		if !q.matches(e) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		r, err := s.read(e.loc)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+
+		if q.Limit > 0 && len(records) == q.Limit {
+			break
+		}
+	}
+
+	return records, nil
+}
+
+// Devices returns the devices with stored records
+func (s *Store) Devices() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.devices))
+}
+
+// Stats returns the size of the store
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := Stats{Segments: len(s.segments), Bytes: s.bytes, Garbage: s.garbage}
+	for _, ser := range s.devices {
+		stats.Records += len(ser.entries)
+	}
+	return stats
+}
+
+// Close closes the segment files, the store cannot be used afterwards
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, seg := range s.segments {
+		errs = append(errs, seg.f.Close())
+	}
+	s.segments = make(map[int]*segment)
+	s.active = nil
+	return errors.Join(errs...)
+}
+
+// Attach stores every message of the events a listener handles, observations and rain and lightning events by default,
+// and returns a func that stops storing them. Messages that fail to store are logged.
+func (s *Store) Attach(l tempest.Listener, events ...tempest.Event) (remove func()) {
+	if len(events) == 0 {
+		events = []tempest.Event{
+			tempest.EventObservationTempest,
+			tempest.EventObservationAir,
+			tempest.EventObservationSky,
+			tempest.EventPrecipitation,
+			tempest.EventLightingStrike,
+		}
+	}
+
+	removes := make([]func(), 0, len(events))
+	for _, e := range events {
+		removes = append(removes, l.AddHandler(e, func(ctx context.Context, b []byte) {
+			r, err := FromMessage(b)
+			if err != nil {
+				log.Printf("store: %v", err)
+				return
+			}
+			if err := s.Append(ctx, r); err != nil {
+				log.Printf("store: writing %s: %v", r.Type, err)
+			}
+		}))
+	}
+
+	return func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}
+}
+
+// put indexes a record, replacing the record with the same key
+func (s *Store) put(r Record, loc location) {
+	ser, ok := s.devices[r.Device]
+	if !ok {
+		ser = &series{}
+		s.devices[r.Device] = ser
+	}
+
+	e := entry{epoch: r.Epoch, typ: r.Type, key: r.key(), loc: loc}
+
+	// records almost always arrive in time order
+	i := len(ser.entries)
+	for i > 0 && ser.entries[i-1].epoch > r.Epoch {
+		i--
+	}
+	for j := i - 1; j >= 0 && ser.entries[j].epoch == r.Epoch; j-- {
+		if ser.entries[j].key == e.key {
+			s.garbage += headerSize + int64(ser.entries[j].loc.n)
+			ser.entries[j].loc = loc
+			return
+		}
+	}
+
+	ser.entries = slices.Insert(ser.entries, i, e)
+}
+
+// between returns the entries from start, inclusive, to end, exclusive. A zero start or end is unbounded.
+func (ser *series) between(start, end time.Time) []entry {
+	i, j := 0, len(ser.entries)
+	search := func(t time.Time) int {
+		n, _ := slices.BinarySearchFunc(ser.entries, t.Unix(), func(e entry, epoch int64) int { return cmp.Compare(e.epoch, epoch) })
+		return n
+	}
+	if !start.IsZero() {
+		i = search(start)
+	}
+	if !end.IsZero() {
+		j = max(search(end), i)
+	}
+	return ser.entries[i:j]
+}
+
+// matches reports whether an entry is of one of the query's types
+func (q Query) matches(e entry) bool {
+	return len(q.Types) == 0 || slices.Contains(q.Types, e.typ)
+}
+
+func (s *Store) read(loc location) (Record, error) {
+	seg, ok := s.segments[loc.seq]
+	if !ok {
+		return Record{}, fmt.Errorf("segment %d is missing", loc.seq)
+	}
+
+	payload, err := seg.read(loc.off, loc.n)
+	if err != nil {
+		return Record{}, err
+	}
+
+	var r Record
+	err = json.Unmarshal(payload, &r)
+	return r, err
+}
+
+// rotate starts a new active segment
+func (s *Store) rotate(seq int) error {
+	seg, err := openSegment(s.dir, seq)
+	if err != nil {
+		return err
+	}
+	s.segments[seq] = seg
+	s.active = seg
+	return syncDir(s.dir)
+}
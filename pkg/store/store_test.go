@@ -0,0 +1,266 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func obs(device int, epoch int64, temp float64) Record {
+	r, err := FromMessage(fmt.Appendf(nil, `{"type":"obs_st","device_id":%d,"obs":[[%d,0,0,0,0,3,1013,%g,50,0,0,0,0,0,0,0,2.6,1,0,0,0,1]]}`, device, epoch, temp))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func epochs(records []Record) []int64 {
+	var got []int64
+	for _, r := range records {
+		got = append(got, r.Epoch)
+	}
+	return got
+}
+
+func TestFromMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    Record
+		wantErr error
+	}{
+		{
+			name:    "observation",
+			message: `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18]]}`,
+			want:    Record{Type: "obs_st", Device: 1, Epoch: 1588948614},
+		},
+		{
+			name:    "rapid wind",
+			message: `{"type":"rapid_wind","device_id":1,"ob":[1588948614,0.27,144]}`,
+			want:    Record{Type: "rapid_wind", Device: 1, Epoch: 1588948614},
+		},
+		{
+			name:    "strike",
+			message: `{"type":"evt_strike","device_id":1,"evt":[1493322445,27,3848]}`,
+			want:    Record{Type: "evt_strike", Device: 1, Epoch: 1493322445},
+		},
+		{
+			name:    "ack",
+			message: `{"type":"ack","id":"1"}`,
+			wantErr: ErrNoEpoch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromMessage([]byte(tt.message))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FromMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Type != tt.want.Type || got.Device != tt.want.Device || got.Epoch != tt.want.Epoch {
+				t.Errorf("FromMessage() = %+v, want %+v", got, tt.want)
+			}
+			if string(got.Data) != tt.message {
+				t.Errorf("data = %s, want the message", got.Data)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	strike, _ := FromMessage([]byte(`{"type":"evt_strike","device_id":1,"evt":[150,27,3848]}`))
+	for _, r := range []Record{obs(1, 100, 10), obs(1, 300, 12), obs(2, 200, 20), obs(1, 200, 11), strike} {
+		if err := s.Append(ctx, r); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []int64
+	}{
+		{name: "every record of a device in time order", query: Query{Device: 1}, want: []int64{100, 150, 200, 300}},
+		{name: "end is exclusive", query: Query{Device: 1, Start: time.Unix(150, 0), End: time.Unix(300, 0)}, want: []int64{150, 200}},
+		{name: "by type", query: Query{Device: 1, Types: []string{"obs_st"}, Start: time.Unix(101, 0)}, want: []int64{200, 300}},
+		{name: "limit", query: Query{Device: 1, Start: time.Unix(101, 0), Limit: 2}, want: []int64{150, 200}},
+		{name: "another device", query: Query{Device: 2}, want: []int64{200}},
+		{name: "unknown device", query: Query{Device: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Query(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if fmt.Sprint(epochs(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("Query() epochs = %v, want %v", epochs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir, WithMaxSegmentSize(200))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := range 5 {
+		s.Append(ctx, obs(1, int64(100+i*60), 10))
+	}
+	// a backfilled observation replaces the live one
+	s.Append(ctx, obs(1, 160, 15))
+	s.Close()
+
+	// a frame cut short by a crash
+	seqs, _ := listSegments(dir)
+	f, _ := os.OpenFile(segmentPath(dir, seqs[len(seqs)-1]), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{200, 0, 0, 0, 1, 2})
+	f.Close()
+
+	s, err = Open(dir, WithMaxSegmentSize(200))
+	if err != nil {
+		t.Fatalf("reopening after a torn write: %v", err)
+	}
+	defer s.Close()
+
+	got, _ := s.Query(ctx, Query{Device: 1})
+	if fmt.Sprint(epochs(got)) != "[100 160 220 280 340]" {
+		t.Fatalf("epochs after reopening = %v", epochs(got))
+	}
+	if string(got[1].Data) != string(obs(1, 160, 15).Data) {
+		t.Errorf("observation at 160 = %s, want the replacement", got[1].Data)
+	}
+
+	if err := s.Append(ctx, obs(1, 400, 10)); err != nil {
+		t.Fatalf("Append() after a torn write: %v", err)
+	}
+	if got, _ := s.Query(ctx, Query{Device: 1, Start: time.Unix(400, 0)}); len(got) != 1 {
+		t.Errorf("records after the torn write = %d, want 1", len(got))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	strike, _ := FromMessage([]byte(`{"type":"evt_strike","device_id":1,"evt":[150,27,3848]}`))
+	for _, r := range []Record{obs(1, 100, 10), strike, obs(1, 200, 11), obs(1, 300, 12), obs(2, 100, 20)} {
+		s.Append(ctx, r)
+	}
+
+	q := Query{Device: 1, Types: []string{"obs_st"}, End: time.Unix(300, 0)}
+	if got := s.Count(q); got.Records != 2 {
+		t.Errorf("Count() = %+v, want 2 records", got)
+	}
+
+	removed, err := s.Delete(ctx, q)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if removed.Records != 2 || removed.Bytes == 0 {
+		t.Errorf("Delete() = %+v, want 2 records", removed)
+	}
+
+	// appended after the delete, so it is kept
+	s.Append(ctx, obs(1, 100, 13))
+	s.Close()
+
+	for _, step := range []string{"reopened", "compacted"} {
+		s, err = Open(dir)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		if step == "compacted" {
+			if err := s.Compact(ctx); err != nil {
+				t.Fatalf("Compact() error = %v", err)
+			}
+			if g := s.Stats().Garbage; g != 0 {
+				t.Errorf("garbage after compaction = %d", g)
+			}
+		}
+
+		got, _ := s.Query(ctx, Query{Device: 1})
+		if fmt.Sprint(epochs(got)) != "[100 150 300]" || string(got[0].Data) != string(obs(1, 100, 13).Data) {
+			t.Errorf("%s: device 1 epochs = %v", step, epochs(got))
+		}
+		if got, _ := s.Query(ctx, Query{Device: 2}); len(got) != 1 {
+			t.Errorf("%s: device 2 records = %d, want 1", step, len(got))
+		}
+		s.Close()
+	}
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir, WithMaxSegmentSize(300))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for round := range 3 {
+		for i := range 4 {
+			s.Append(ctx, obs(i%2+1, int64(100+i*60), float64(round)))
+		}
+	}
+
+	before := s.Stats()
+	if before.Garbage == 0 {
+		t.Fatal("replaced records are not counted as garbage")
+	}
+
+	if err := s.Compact(ctx); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	after := s.Stats()
+	if after.Records != 4 || after.Garbage != 0 || after.Bytes >= before.Bytes {
+		t.Errorf("stats after compaction = %+v, before %+v", after, before)
+	}
+
+	s.Append(ctx, obs(1, 500, 3))
+	s.Close()
+
+	entries, _ := filepath.Glob(filepath.Join(dir, "*"+tmpExt))
+	if len(entries) != 0 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopening after compaction: %v", err)
+	}
+	defer s.Close()
+
+	got, _ := s.Query(ctx, Query{Device: 1})
+	if fmt.Sprint(epochs(got)) != "[100 220 500]" {
+		t.Errorf("device 1 epochs after compaction = %v", epochs(got))
+	}
+	if r := got[0]; string(r.Data) != string(obs(1, 100, 2).Data) {
+		t.Errorf("compaction kept %s, want the latest record", r.Data)
+	}
+}
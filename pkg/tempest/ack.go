@@ -0,0 +1,129 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/connection"
+)
+
+// ErrAckTimeout is returned when a listen request is not acknowledged within the ack timeout
+var ErrAckTimeout = errors.New("timed out waiting for ack")
+
+// SubscriptionError describes a listen request that was not accepted
+type SubscriptionError struct {
+	Request RequestMessage
+	Err     error
+}
+
+func (e *SubscriptionError) Error() string {
+	id := e.Request.Device
+	kind := "device"
+	if e.Request.Station != 0 {
+		id, kind = e.Request.Station, "station"
+	}
+	return fmt.Sprintf("%s for %s %d failed: %v", e.Request.Type, kind, id, e.Err)
+}
+
+func (e *SubscriptionError) Unwrap() error {
+	return e.Err
+}
+
+// WithAckTimeout makes the listener wait for the ack of each listen request. A request that is rejected or
+// not acknowledged within the timeout fails Listen or Subscribe. Acks are not awaited by default, since UDP hubs never send them.
+func WithAckTimeout(d time.Duration) Option {
+	return func(l *EventListener) {
+		l.ackTimeout = d
+	}
+}
+
+// ackMessage is the part of a message needed to correlate it with a request
+type ackMessage struct {
+	Type   string     `json:"type"`
+	ID     string     `json:"id"`
+	Status api.Status `json:"status"`
+}
+
+// request writes a listen request. When acks are awaited, the returned func blocks until the request is acknowledged or fails.
+// A read only connection, such as an mqtt subscription without a publish topic, receives what it is sent without requests,
+// so its ErrReadOnly is not an error and nothing is awaited.
+func (l *EventListener) request(ctx context.Context, r RequestMessage) (wait func(ctx context.Context) error, err error) {
+	sent := func(context.Context) error { return nil }
+	if l.ackTimeout <= 0 {
+		if err := l.c.Write(ctx, r); err != nil && !errors.Is(err, connection.ErrReadOnly) {
+			return nil, err
+		}
+		return sent, nil
+	}
+
+	ch := make(chan error, 1)
+	l.ackMu.Lock()
+	l.acks[r.ID] = ch
+	l.ackMu.Unlock()
+
+	if err := l.c.Write(ctx, r); err != nil {
+		l.forgetAck(r.ID)
+		if errors.Is(err, connection.ErrReadOnly) {
+			return sent, nil
+		}
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		timer := time.NewTimer(l.ackTimeout)
+		defer timer.Stop()
+
+		select {
+		case err := <-ch:
+			if err != nil {
+				return &SubscriptionError{Request: r, Err: err}
+			}
+			return nil
+		case <-timer.C:
+			l.forgetAck(r.ID)
+			return &SubscriptionError{Request: r, Err: ErrAckTimeout}
+		case <-ctx.Done():
+			l.forgetAck(r.ID)
+			return ctx.Err()
+		}
+	}, nil
+}
+
+// resolveAck resolves the outstanding request a message responds to, if any
+func (l *EventListener) resolveAck(ctx context.Context, b []byte) {
+	if l.ackTimeout <= 0 {
+		return
+	}
+
+	m, err := Decode[ackMessage](ctx, b)
+	if err != nil || m.ID == "" {
+		return
+	}
+
+	l.ackMu.Lock()
+	ch, ok := l.acks[m.ID]
+	delete(l.acks, m.ID)
+	l.ackMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	switch {
+	case m.Status.StatusCode != 0:
+		ch <- fmt.Errorf("status %d: %s", m.Status.StatusCode, m.Status.StatusMessage)
+	case m.Type == string(EventAck):
+		ch <- nil
+	default:
+		ch <- fmt.Errorf("unexpected %s response", m.Type)
+	}
+}
+
+func (l *EventListener) forgetAck(id string) {
+	l.ackMu.Lock()
+	defer l.ackMu.Unlock()
+	delete(l.acks, id)
+}
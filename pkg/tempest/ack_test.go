@@ -0,0 +1,170 @@
+package tempest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/kdwils/weatherstation/pkg/connection"
+)
+
+// fakeConn is a connection driven by channels, reads block until a message is sent or the context is done
+type fakeConn struct {
+	reads  chan []byte
+	writes chan RequestMessage
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		reads:  make(chan []byte, 16),
+		writes: make(chan RequestMessage, 16),
+	}
+}
+
+func (f *fakeConn) Write(ctx context.Context, data any) error {
+	f.writes <- data.(RequestMessage)
+	return nil
+}
+
+func (f *fakeConn) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case b, ok := <-f.reads:
+		if !ok {
+			return nil, io.EOF
+		}
+		return b, nil
+	}
+}
+
+func (f *fakeConn) Close(context.Context, ...websocket.StatusCode) error {
+	return nil
+}
+
+// ack sends an ack for the next written request
+func (f *fakeConn) ack(t *testing.T) {
+	t.Helper()
+	select {
+	case r := <-f.writes:
+		f.reads <- []byte(fmt.Sprintf(`{"type":"ack","id":%q}`, r.ID))
+	case <-time.After(time.Second):
+		t.Fatal("expected a request to be written")
+	}
+}
+
+func TestListenAck(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(t *testing.T, f *fakeConn)
+		wantErr error
+	}{
+		{
+			name: "acknowledged",
+			respond: func(t *testing.T, f *fakeConn) {
+				f.ack(t)
+				close(f.reads)
+			},
+			wantErr: io.EOF,
+		},
+		{
+			name: "not acknowledged",
+			respond: func(t *testing.T, f *fakeConn) {
+				<-f.writes
+			},
+			wantErr: ErrAckTimeout,
+		},
+		{
+			name: "rejected",
+			respond: func(t *testing.T, f *fakeConn) {
+				r := <-f.writes
+				b, _ := json.Marshal(map[string]any{
+					"type":   "ack",
+					"id":     r.ID,
+					"status": map[string]any{"status_code": 2, "status_message": "unknown device"},
+				})
+				f.reads <- b
+			},
+			wantErr: &SubscriptionError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeConn()
+			l := NewEventListener(f, ListenGroupStart, 1, WithAckTimeout(50*time.Millisecond))
+
+			go tt.respond(t, f)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := l.Listen(ctx)
+
+			var se *SubscriptionError
+			switch want := tt.wantErr.(type) {
+			case *SubscriptionError:
+				if !errors.As(err, &se) {
+					t.Errorf("Listen() error = %v, want subscription error", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("Listen() error = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+// readOnlyConn is a connection that cannot send requests
+type readOnlyConn struct {
+	*fakeConn
+}
+
+func (readOnlyConn) Write(context.Context, any) error {
+	return connection.ErrReadOnly
+}
+
+func TestListenReadOnlyConnection(t *testing.T) {
+	f := newFakeConn()
+	l := NewEventListener(readOnlyConn{f}, ListenGroupStart, 1, WithAckTimeout(50*time.Millisecond))
+
+	var calls atomic.Int32
+	l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) { calls.Add(1) })
+
+	f.reads <- []byte(`{"type":"obs_st","device_id":1}`)
+	close(f.reads)
+
+	if err := l.Listen(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("Listen() error = %v, want io.EOF without awaiting acks", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler calls = %d, want 1", got)
+	}
+}
+
+func TestListenAckStillDispatched(t *testing.T) {
+	f := newFakeConn()
+	l := NewEventListener(f, ListenGroupStart, 1, WithAckTimeout(time.Second))
+
+	acks := make(chan struct{}, 1)
+	l.RegisterHandler(EventAck, func(ctx context.Context, b []byte) {
+		acks <- struct{}{}
+	})
+
+	go func() {
+		f.ack(t)
+		<-acks
+		close(f.reads)
+	}()
+
+	if err := l.Listen(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("Listen() error = %v, want EOF", err)
+	}
+}
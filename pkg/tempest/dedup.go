@@ -0,0 +1,153 @@
+package tempest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// Deduplicator discards messages that were already seen within a window. Observations are keyed on their type, device and epoch,
+// and events on their type, device and data, which includes their epoch. Share a Deduplicator between listeners to de-duplicate
+// across sources, such as overlapping cloud and local feeds. A hub's udp broadcasts name their device by serial number rather
+// than device id, map the serial numbers with MapSerial or MapStations so they share keys with the websocket api's messages.
+type Deduplicator struct {
+	window     time.Duration
+	mu         sync.Mutex
+	seen       map[string]time.Time
+	serials    map[string]int
+	lastPrune  time.Time
+	duplicates atomic.Uint64
+	now        func() time.Time
+}
+
+// NewDeduplicator creates a deduplicator that remembers messages for the window
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		window:  window,
+		seen:    make(map[string]time.Time),
+		serials: make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+// MapSerial keys messages naming a device by its serial number, such as a hub's udp broadcasts, on the device id
+func (d *Deduplicator) MapSerial(serial string, device int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.serials[serial] = device
+}
+
+// MapStations maps the serial number of every device of the stations, as returned by the station metadata api
+func (d *Deduplicator) MapStations(stations ...api.Station) {
+	for _, s := range stations {
+		for _, device := range s.Devices {
+			if device.SerialNumber != "" {
+				d.MapSerial(device.SerialNumber, device.DeviceID)
+			}
+		}
+	}
+}
+
+// WithDeduplicator discards messages the deduplicator has already seen before they reach any handler
+func WithDeduplicator(d *Deduplicator) Option {
+	return func(l *EventListener) {
+		l.dedup = d
+	}
+}
+
+// Duplicates returns how many messages were discarded as duplicates
+func (d *Deduplicator) Duplicates() uint64 {
+	return d.duplicates.Load()
+}
+
+// dedupMessage is the part of a message that identifies it
+type dedupMessage struct {
+	Type    string          `json:"type"`
+	Device  int             `json:"device_id"`
+	Station int             `json:"station_id"`
+	Serial  string          `json:"serial_number"`
+	Obs     [][]any         `json:"obs"`
+	Ob      json.RawMessage `json:"ob"`
+	Evt     json.RawMessage `json:"evt"`
+}
+
+// source identifies what a message is from: its device, resolving a serial number with the mapped serial numbers, otherwise
+// its serial number or station
+func (m dedupMessage) source(serials map[string]int) string {
+	device := m.Device
+	if device == 0 && m.Serial != "" {
+		device = serials[m.Serial]
+		if device == 0 {
+			return "serial/" + m.Serial
+		}
+	}
+	if device == 0 {
+		return fmt.Sprintf("station/%d", m.Station)
+	}
+	return fmt.Sprintf("device/%d", device)
+}
+
+// key returns the identity of a message from a source, or false for messages that are never de-duplicated, such as acks
+func (m dedupMessage) key(source string) (string, bool) {
+	id := m.Type + "/" + source
+
+	switch {
+	case strings.HasPrefix(m.Type, "obs_") && len(m.Obs) > 0 && len(m.Obs[0]) > 0:
+		epoch, _ := m.Obs[0][0].(float64)
+		return fmt.Sprintf("%s/%d", id, int64(epoch)), true
+	case len(m.Ob) > 0:
+		return id + "/" + digest(m.Ob), true
+	case len(m.Evt) > 0:
+		return id + "/" + digest(m.Evt), true
+	default:
+		return "", false
+	}
+}
+
+func digest(b json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		buf.Reset()
+		buf.Write(b)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// duplicate records a message and reports whether it was already seen within the window
+func (d *Deduplicator) duplicate(m dedupMessage) bool {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, ok := m.key(m.source(d.serials))
+	if !ok {
+		return false
+	}
+
+	if now.Sub(d.lastPrune) > d.window {
+		for k, seen := range d.seen {
+			if now.Sub(seen) > d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	if seen, ok := d.seen[key]; ok && now.Sub(seen) <= d.window {
+		d.duplicates.Add(1)
+		return true
+	}
+
+	d.seen[key] = now
+	return false
+}
@@ -0,0 +1,167 @@
+package tempest
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/connection"
+)
+
+func TestDeduplicatorKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     string
+		second    string
+		duplicate bool
+	}{
+		{
+			name:      "same observation epoch",
+			first:     `{"type":"obs_st","device_id":1,"source":"cache","obs":[[1588948614,0.18]]}`,
+			second:    `{"type":"obs_st","device_id":1,"source":"backfill","obs":[[1588948614,0.19]]}`,
+			duplicate: true,
+		},
+		{
+			name:   "next observation",
+			first:  `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18]]}`,
+			second: `{"type":"obs_st","device_id":1,"obs":[[1588948674,0.18]]}`,
+		},
+		{
+			name:   "another device",
+			first:  `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18]]}`,
+			second: `{"type":"obs_st","device_id":2,"obs":[[1588948614,0.18]]}`,
+		},
+		{
+			name:      "retransmitted rapid wind",
+			first:     `{"type":"rapid_wind","device_id":1,"ob":[1588948614,0.27,144]}`,
+			second:    `{"type":"rapid_wind","device_id":1,"ob": [1588948614, 0.27, 144]}`,
+			duplicate: true,
+		},
+		{
+			name:   "strike at the same epoch with other data",
+			first:  `{"type":"evt_strike","device_id":1,"evt":[1493322445,27,3848]}`,
+			second: `{"type":"evt_strike","device_id":1,"evt":[1493322445,12,1200]}`,
+		},
+		{
+			name:      "udp broadcast of a websocket observation",
+			first:     `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,5,0,0,0,0]]}`,
+			second:    `{"serial_number":"ST-00000512","type":"obs_st","hub_sn":"HB-00013030","obs":[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,5]],"firmware_revision":129}`,
+			duplicate: true,
+		},
+		{
+			name:      "udp broadcast of a websocket rapid wind",
+			first:     `{"type":"rapid_wind","device_id":1,"ob":[1588948614,0.27,144]}`,
+			second:    `{"serial_number":"ST-00000512","type":"rapid_wind","hub_sn":"HB-00013030","ob":[1588948614,0.27,144]}`,
+			duplicate: true,
+		},
+		{
+			name:   "udp broadcast of an unmapped serial number",
+			first:  `{"type":"obs_st","device_id":1,"obs":[[1588948614,0.18]]}`,
+			second: `{"serial_number":"ST-00000999","type":"obs_st","hub_sn":"HB-00013030","obs":[[1588948614,0.18]]}`,
+		},
+		{
+			name:      "repeated udp broadcast of an unmapped serial number",
+			first:     `{"serial_number":"ST-00000999","type":"obs_st","hub_sn":"HB-00013030","obs":[[1588948614,0.18]]}`,
+			second:    `{"serial_number":"ST-00000999","type":"obs_st","hub_sn":"HB-00013030","obs":[[1588948614,0.18]]}`,
+			duplicate: true,
+		},
+		{
+			name:   "acks are never duplicates",
+			first:  `{"type":"ack","id":"1"}`,
+			second: `{"type":"ack","id":"1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDeduplicator(time.Minute)
+			d.MapSerial("ST-00000512", 1)
+
+			var first, second dedupMessage
+			json.Unmarshal([]byte(tt.first), &first)
+			json.Unmarshal([]byte(tt.second), &second)
+
+			if d.duplicate(first) {
+				t.Fatal("first message reported as a duplicate")
+			}
+			if got := d.duplicate(second); got != tt.duplicate {
+				t.Errorf("duplicate = %v, want %v", got, tt.duplicate)
+			}
+		})
+	}
+}
+
+func TestDeduplicatorWindow(t *testing.T) {
+	now := time.Unix(1588948614, 0)
+	d := NewDeduplicator(time.Minute)
+	d.now = func() time.Time { return now }
+
+	var m dedupMessage
+	json.Unmarshal([]byte(`{"type":"obs_st","device_id":1,"obs":[[1588948614]]}`), &m)
+
+	d.duplicate(m)
+	now = now.Add(30 * time.Second)
+	if !d.duplicate(m) {
+		t.Error("message within the window was not a duplicate")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if d.duplicate(m) {
+		t.Error("message after the window was a duplicate")
+	}
+	if got := d.Duplicates(); got != 1 {
+		t.Errorf("duplicates = %d, want 1", got)
+	}
+}
+
+func TestListenDeduplicates(t *testing.T) {
+	conn := scriptedConnection(t,
+		`{"type":"obs_st","device_id":1,"obs":[[1588948614]]}`,
+		`{"type":"obs_st","device_id":1,"obs":[[1588948614]]}`,
+		`{"type":"obs_st","device_id":1,"obs":[[1588948674]]}`,
+	)
+
+	d := NewDeduplicator(time.Minute)
+	l := NewEventListener(conn, ListenGroupStart, 1, WithDeduplicator(d))
+
+	var calls atomic.Int32
+	l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) { calls.Add(1) })
+	l.Listen(context.Background())
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler calls = %d, want 2", got)
+	}
+	if got := d.Duplicates(); got != 1 {
+		t.Errorf("duplicates = %d, want 1", got)
+	}
+}
+
+func TestListenDeduplicatesAcrossSources(t *testing.T) {
+	cloud := scriptedConnection(t,
+		`{"type":"obs_st","device_id":1110,"obs":[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,5,0,0,0,0]]}`,
+	)
+	local := scriptedConnection(t,
+		`{"serial_number":"ST-00000512","type":"obs_st","hub_sn":"HB-00013030","obs":[[1588948614,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,5]],"firmware_revision":129}`,
+		`{"serial_number":"ST-00000512","type":"obs_st","hub_sn":"HB-00013030","obs":[[1588948674,0.18,0.22,0.27,144,6,1017.57,22.37,50.26,328,0.03,3,0,0,0,0,2.410,5]],"firmware_revision":129}`,
+	)
+
+	d := NewDeduplicator(time.Minute)
+	d.MapStations(api.Station{Devices: []api.Device{{SerialNumber: "ST-00000512", DeviceID: 1110}}})
+
+	var calls atomic.Int32
+	for _, conn := range []connection.Connection{cloud, local} {
+		l := NewEventListener(conn, ListenGroupStart, 1110, WithDeduplicator(d))
+		l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) { calls.Add(1) })
+		l.Listen(context.Background())
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler calls = %d, want 2", got)
+	}
+	if got := d.Duplicates(); got != 1 {
+		t.Errorf("duplicates = %d, want 1", got)
+	}
+}
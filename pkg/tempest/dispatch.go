@@ -0,0 +1,202 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// OverflowPolicy decides what happens to a message when the queue for its event type is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the queue, which stops reading from the connection until handlers catch up
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued message to make room
+	OverflowDropOldest
+	// OverflowDropNewest discards the incoming message
+	OverflowDropNewest
+)
+
+const defaultQueueSize = 64
+
+// ErrDropped is reported to the error handler when a message is discarded because its queue is full
+var ErrDropped = errors.New("message dropped, queue is full")
+
+// PanicError is reported to the error handler when a handler panics
+type PanicError struct {
+	Event Event
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler for %s panicked: %v", e.Event, e.Value)
+}
+
+// WithQueueSize sets how many messages can be queued per event type before the overflow policy applies
+func WithQueueSize(n int) Option {
+	return func(l *EventListener) {
+		if n > 0 {
+			l.queueSize = n
+		}
+	}
+}
+
+// WithOverflowPolicy sets what happens when the queue for an event type is full. Defaults to OverflowBlock.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(l *EventListener) {
+		l.overflow = p
+	}
+}
+
+// job is a message waiting to be passed to its handlers
+type job struct {
+	ctx      context.Context
+	event    Event
+	b        []byte
+	handlers []Handler
+}
+
+// dispatcher runs handlers on one worker per event type, so messages of a type are handled in the order they arrived
+type dispatcher struct {
+	size    int
+	policy  OverflowPolicy
+	onError func(context.Context, error)
+	// done is closed by close, ending blocked sends and telling workers to drain their queues and stop. Queues are never
+	// closed, so a send racing close cannot panic.
+	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
+	queues map[Event]chan job
+	wg     sync.WaitGroup
+}
+
+func newDispatcher(size int, policy OverflowPolicy, onError func(context.Context, error)) *dispatcher {
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	if onError == nil {
+		onError = defaultErrorHandler
+	}
+
+	return &dispatcher{
+		size:    size,
+		policy:  policy,
+		onError: onError,
+		done:    make(chan struct{}),
+		queues:  make(map[Event]chan job),
+	}
+}
+
+// dispatch queues a message for its handlers according to the overflow policy. No lock is held while it waits for room,
+// so a blocked dispatch never holds up close.
+func (d *dispatcher) dispatch(j job) {
+	q, ok := d.queue(j.event)
+	if !ok {
+		return
+	}
+
+	switch d.policy {
+	case OverflowDropNewest:
+		select {
+		case q <- j:
+		default:
+			d.onError(j.ctx, fmt.Errorf("%w: %s", ErrDropped, j.event))
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q <- j:
+				return
+			default:
+			}
+
+			select {
+			case old := <-q:
+				d.onError(old.ctx, fmt.Errorf("%w: %s", ErrDropped, old.event))
+			default:
+			}
+		}
+	default:
+		select {
+		case q <- j:
+		case <-j.ctx.Done():
+		case <-d.done:
+		}
+	}
+}
+
+// queue returns the queue for an event type, starting its worker on first use. It reports false once the dispatcher is closed.
+func (d *dispatcher) queue(e Event) (chan job, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, false
+	}
+
+	q, ok := d.queues[e]
+	if !ok {
+		q = make(chan job, d.size)
+		d.queues[e] = q
+
+		d.wg.Add(1)
+		go d.work(q)
+	}
+
+	return q, true
+}
+
+// work passes queued messages to their handlers one at a time, draining the queue once the dispatcher is closed
+func (d *dispatcher) work(q chan job) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case j := <-q:
+			d.handle(j)
+		case <-d.done:
+			for {
+				select {
+				case j := <-q:
+					d.handle(j)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle passes a message to each of its handlers
+func (d *dispatcher) handle(j job) {
+	for _, h := range j.handlers {
+		d.call(j, h)
+	}
+}
+
+// call runs a handler, recovering and reporting a panic
+func (d *dispatcher) call(j job, h Handler) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.onError(j.ctx, &PanicError{Event: j.event, Value: r, Stack: debug.Stack()})
+		}
+	}()
+
+	h(j.ctx, j.b)
+}
+
+// close stops accepting messages and waits for the queued ones to be handled
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
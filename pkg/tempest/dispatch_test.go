@@ -0,0 +1,181 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherKeepsOrder(t *testing.T) {
+	var got []string
+	h := func(ctx context.Context, b []byte) {
+		got = append(got, string(b))
+	}
+
+	d := newDispatcher(4, OverflowBlock, nil)
+	want := make([]string, 0)
+	for i := range 50 {
+		b := fmt.Sprintf("%d", i)
+		want = append(want, b)
+		d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, b: []byte(b), handlers: []Handler{h}})
+	}
+	d.close()
+
+	if !slices.Equal(got, want) {
+		t.Errorf("handled out of order: %v", got)
+	}
+}
+
+func TestDispatcherRecoversPanics(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	onError := func(ctx context.Context, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+
+	var called bool
+	handlers := []Handler{
+		func(ctx context.Context, b []byte) { panic("boom") },
+		func(ctx context.Context, b []byte) { called = true },
+	}
+
+	d := newDispatcher(1, OverflowBlock, onError)
+	d.dispatch(job{ctx: context.Background(), event: EventRapidWind, handlers: handlers})
+	d.close()
+
+	if !called {
+		t.Error("handler after a panicking handler should still be called")
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want 1 panic error", errs)
+	}
+
+	var pe *PanicError
+	if !errors.As(errs[0], &pe) || pe.Value != "boom" || pe.Event != EventRapidWind {
+		t.Errorf("error = %v, want panic error for %s", errs[0], EventRapidWind)
+	}
+}
+
+func TestDispatcherOverflow(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy OverflowPolicy
+		want   []string
+	}{
+		{
+			name:   "drop newest",
+			policy: OverflowDropNewest,
+			want:   []string{"0", "1", "2"},
+		},
+		{
+			name:   "drop oldest",
+			policy: OverflowDropOldest,
+			want:   []string{"0", "3", "4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				dropped int
+				got     []string
+			)
+			onError := func(ctx context.Context, err error) {
+				if errors.Is(err, ErrDropped) {
+					mu.Lock()
+					dropped++
+					mu.Unlock()
+				}
+			}
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			h := func(ctx context.Context, b []byte) {
+				if string(b) == "0" {
+					close(started)
+					<-release
+				}
+				got = append(got, string(b))
+			}
+
+			d := newDispatcher(2, tt.policy, onError)
+			d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, b: []byte("0"), handlers: []Handler{h}})
+			<-started
+
+			for i := 1; i < 5; i++ {
+				d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, b: []byte(fmt.Sprintf("%d", i)), handlers: []Handler{h}})
+			}
+
+			close(release)
+			d.close()
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("handled = %v, want %v", got, tt.want)
+			}
+			if dropped != 2 {
+				t.Errorf("dropped = %d, want 2", dropped)
+			}
+		})
+	}
+}
+
+func TestDispatcherBlockHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	h := func(ctx context.Context, b []byte) { <-release }
+
+	d := newDispatcher(1, OverflowBlock, nil)
+	d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, handlers: []Handler{h}})
+	d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, handlers: []Handler{h}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.dispatch(job{ctx: ctx, event: EventObservationTempest, handlers: []Handler{h}})
+
+	close(release)
+	d.close()
+}
+
+func TestDispatcherCloseUnblocksDispatch(t *testing.T) {
+	release := make(chan struct{})
+	h := func(ctx context.Context, b []byte) { <-release }
+
+	d := newDispatcher(1, OverflowBlock, nil)
+	d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, handlers: []Handler{h}})
+	d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, handlers: []Handler{h}})
+
+	// the queue is full, so this waits for room until the dispatcher is closed
+	blocked := make(chan struct{})
+	go func() {
+		d.dispatch(job{ctx: context.Background(), event: EventObservationTempest, handlers: []Handler{h}})
+		close(blocked)
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		d.close()
+		close(closed)
+	}()
+
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("a dispatch waiting on a full queue was not released by close")
+	}
+
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close did not return once handlers finished")
+	}
+}
@@ -22,10 +22,16 @@ const (
 	EventLightingStrike     Event = "evt_strike"
 	EventDeviceOnline       Event = "evt_device_online"
 	EventDeviceOffline      Event = "evt_device_offline"
+	EventDeviceStale        Event = "evt_device_stale"
 	EventStationOnline      Event = "evt_station_online"
 	EventStationOffline     Event = "evt_station_offline"
 	EventRapidWind          Event = "rapid_wind"
 	EventObservationAir     Event = "obs_air"
 	EventObservationSky     Event = "obs_sky"
 	EventObservationTempest Event = "obs_st"
+	EventDeviceStatus       Event = "device_status"
+	EventHubStatus          Event = "hub_status"
+
+	// EventAll registers a handler that receives every message, whatever its type
+	EventAll Event = "*"
 )
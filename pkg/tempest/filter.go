@@ -0,0 +1,51 @@
+package tempest
+
+import (
+	"context"
+	"slices"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// ForDevices wraps a handler so it is only called for messages from one of the given devices
+func ForDevices(h Handler, devices ...int) Handler {
+	return func(ctx context.Context, b []byte) {
+		o, err := Decode[api.Observation](ctx, b)
+		if err != nil {
+			return
+		}
+
+		if slices.Contains(devices, o.Device) {
+			h(ctx, b)
+		}
+	}
+}
+
+// ForStations wraps a handler so it is only called for messages from one of the given stations
+func ForStations(h Handler, stations ...int) Handler {
+	return func(ctx context.Context, b []byte) {
+		o, err := Decode[api.Observation](ctx, b)
+		if err != nil {
+			return
+		}
+
+		if slices.Contains(stations, o.Station) {
+			h(ctx, b)
+		}
+	}
+}
+
+// RouteByDevice returns a handler that passes each message to the handler registered for its device.
+// Messages from devices without a route are dropped.
+func RouteByDevice(routes map[int]Handler) Handler {
+	return func(ctx context.Context, b []byte) {
+		o, err := Decode[api.Observation](ctx, b)
+		if err != nil {
+			return
+		}
+
+		if h, ok := routes[o.Device]; ok {
+			h(ctx, b)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package tempest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// registration is a handler registered for an event type
+type registration struct {
+	id      uint64
+	h       Handler
+	removed atomic.Bool
+}
+
+// RegisterHandler registers new handlers for a given event type
+func (l *EventListener) RegisterHandler(Event Event, hs ...Handler) error {
+	for _, h := range hs {
+		l.AddHandler(Event, h)
+	}
+	return nil
+}
+
+// AddHandler registers a handler for an event type and returns a func that removes it.
+// It is safe to call while the listener is running. Once remove returns the handler is not called again,
+// other than a call that is already in progress. Calling remove more than once has no effect.
+func (l *EventListener) AddHandler(e Event, h Handler) (remove func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.handlers == nil {
+		l.handlers = make(map[Event][]*registration)
+	}
+
+	l.nextID++
+	r := &registration{id: l.nextID, h: h}
+	l.handlers[e] = append(l.handlers[e], r)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.removed.Store(true)
+			l.removeHandler(e, r.id)
+		})
+	}
+}
+
+func (l *EventListener) removeHandler(e Event, id uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.handlers[e] = slices.DeleteFunc(l.handlers[e], func(r *registration) bool {
+		return r.id == id
+	})
+
+	if len(l.handlers[e]) == 0 {
+		delete(l.handlers, e)
+	}
+}
+
+// WithUnhandledHandler sets a handler for messages of a type with no handlers registered, such as new firmware events
+// or UDP only events like hub_status. Handlers registered for EventAll do not count as handling a type.
+func WithUnhandledHandler(h Handler) Option {
+	return func(l *EventListener) {
+		l.unhandled = h
+	}
+}
+
+// handlersFor returns the handlers of an event type followed by the EventAll handlers, wrapped with the listener's middleware.
+// The unhandled handler takes the place of the event type's handlers when it has none.
+// Removed handlers are skipped even if they were removed after the message was queued.
+func (l *EventListener) handlersFor(e Event) []Handler {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var hs []Handler
+	for _, r := range l.handlers[e] {
+		hs = append(hs, r.guard(chain(r.h, l.middleware)))
+	}
+	if len(hs) == 0 && l.unhandled != nil {
+		hs = append(hs, chain(l.unhandled, l.middleware))
+	}
+
+	if e == EventAll {
+		return hs
+	}
+	for _, r := range l.handlers[EventAll] {
+		hs = append(hs, r.guard(chain(r.h, l.middleware)))
+	}
+	return hs
+}
+
+// guard returns a handler that does nothing once the registration is removed
+func (r *registration) guard(h Handler) Handler {
+	return func(ctx context.Context, b []byte) {
+		if r.removed.Load() {
+			return
+		}
+		h(ctx, b)
+	}
+}
@@ -0,0 +1,107 @@
+package tempest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestAddHandlerRemove(t *testing.T) {
+	conn := scriptedConnection(t,
+		`{"type":"obs_st","device_id":1}`,
+		`{"type":"obs_st","device_id":1}`,
+		`{"type":"obs_st","device_id":1}`,
+	)
+
+	l := NewEventListener(conn, ListenGroupStart, 1)
+
+	var kept, removed int
+	l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) { kept++ })
+
+	var remove func()
+	remove = l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) {
+		removed++
+		remove()
+	})
+
+	l.Listen(context.Background())
+
+	if kept != 3 {
+		t.Errorf("kept handler calls = %d, want 3", kept)
+	}
+	if removed != 1 {
+		t.Errorf("removed handler calls = %d, want 1", removed)
+	}
+
+	remove()
+	if n := len(l.(*EventListener).handlers[EventObservationTempest]); n != 1 {
+		t.Errorf("registered handlers = %d, want 1", n)
+	}
+}
+
+func TestAddHandlerConcurrent(t *testing.T) {
+	l := NewEventListener(nil, ListenGroupStart, 1).(*EventListener)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			remove := l.AddHandler(EventRapidWind, func(ctx context.Context, b []byte) {})
+			l.handlersFor(EventRapidWind)
+			remove()
+		}()
+	}
+	wg.Wait()
+
+	if n := len(l.handlersFor(EventRapidWind)); n != 0 {
+		t.Errorf("handlers = %d, want 0", n)
+	}
+}
+
+func TestOnRemove(t *testing.T) {
+	l := NewEventListener(nil, ListenGroupStart, 1).(*EventListener)
+
+	remove := On(l, func(ctx context.Context, v countingEvent) {})
+	if n := len(l.handlersFor(EventObservationTempest)); n != 1 {
+		t.Fatalf("handlers = %d, want 1", n)
+	}
+
+	remove()
+	if n := len(l.handlersFor(EventObservationTempest)); n != 0 {
+		t.Errorf("handlers = %d, want 0", n)
+	}
+}
+
+func TestCatchAllAndUnhandled(t *testing.T) {
+	conn := scriptedConnection(t,
+		`{"type":"obs_st","device_id":1}`,
+		`{"type":"hub_status","serial_number":"HB-1"}`,
+		`{"type":"evt_new_firmware_event"}`,
+	)
+
+	var mu sync.Mutex
+	var all, unhandled []Event
+	record := func(events *[]Event) Handler {
+		return func(ctx context.Context, b []byte) {
+			e, _ := EventFromContext(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			*events = append(*events, e)
+		}
+	}
+
+	l := NewEventListener(conn, ListenGroupStart, 1, WithUnhandledHandler(record(&unhandled)))
+	l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) {})
+	l.AddHandler(EventAll, record(&all))
+
+	l.Listen(context.Background())
+
+	if len(all) != 3 {
+		t.Errorf("catch-all received %v, want every message", all)
+	}
+	if len(unhandled) != 2 || !slices.Contains(unhandled, EventHubStatus) || !slices.Contains(unhandled, Event("evt_new_firmware_event")) {
+		t.Errorf("unhandled received %v, want hub_status and evt_new_firmware_event", unhandled)
+	}
+}
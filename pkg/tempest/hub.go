@@ -0,0 +1,249 @@
+package tempest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coder/websocket"
+	"github.com/kdwils/weatherstation/pkg/connection"
+)
+
+// Hub shares the feed of one listener, and so one connection, with many in-process consumers. Each consumer is a Listener
+// with its own handlers, middleware and buffering, fed every message the hub's listener dispatches. Consumers start with
+// the hub's subscriptions. Their Subscribe and Unsubscribe calls are forwarded to the hub's listener and acked once applied,
+// and a listen group is only stopped once no consumer needs it. The subscriptions the hub's listener started with are never
+// stopped by consumers.
+type Hub struct {
+	listener Listener
+	mu       sync.Mutex
+	conns    map[*hubConn]struct{}
+	done     bool
+	// subMu serializes forwarded requests, it is not held while broadcasting so acks can be dispatched
+	subMu sync.Mutex
+	base  []Subscription
+	refs  map[Subscription]int
+}
+
+// NewHub creates a hub for a listener
+func NewHub(l Listener) *Hub {
+	h := &Hub{
+		listener: l,
+		conns:    make(map[*hubConn]struct{}),
+		base:     l.Subscriptions(),
+		refs:     make(map[Subscription]int),
+	}
+
+	l.AddHandler(EventAll, h.broadcast)
+	return h
+}
+
+// Listener returns the listener the hub reads from, for managing its subscriptions
+func (h *Hub) Listener() Listener {
+	return h.listener
+}
+
+// Run listens on the hub's listener until it returns, then ends every consumer
+func (h *Hub) Run(ctx context.Context) error {
+	err := h.listener.Listen(ctx)
+
+	h.mu.Lock()
+	h.done = true
+	conns := h.conns
+	h.conns = make(map[*hubConn]struct{})
+	h.mu.Unlock()
+
+	for c := range conns {
+		c.end()
+	}
+
+	return err
+}
+
+// Subscribe creates a consumer of the hub's feed. Messages are buffered up to the consumer's queue size. When a consumer
+// falls behind its oldest messages are dropped, reporting ErrDropped to its error handler, rather than holding up the hub.
+// Call Listen on the consumer to start handling messages.
+func (h *Hub) Subscribe(opts ...Option) Listener {
+	l := NewEventListener(nil, ListenGroupStart, 0, opts...).(*EventListener)
+	l.subs = append(l.subs, h.listener.Subscriptions()...)
+
+	size := l.queueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+
+	c := &hubConn{
+		hub:     h,
+		ch:      make(chan []byte, size),
+		done:    make(chan struct{}),
+		onError: l.onError,
+		subs:    make(map[Subscription]bool),
+	}
+	l.c = c
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.done {
+		c.end()
+		return l
+	}
+	h.conns[c] = struct{}{}
+	return l
+}
+
+// broadcast passes a message to every consumer
+func (h *Hub) broadcast(ctx context.Context, b []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.conns {
+		c.push(ctx, b)
+	}
+}
+
+func (h *Hub) remove(c *hubConn) {
+	h.mu.Lock()
+	delete(h.conns, c)
+	h.mu.Unlock()
+
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	for sub := range c.subs {
+		h.release(context.Background(), c, sub)
+	}
+}
+
+// forward applies a consumer's listen request to the hub's listener
+func (h *Hub) forward(ctx context.Context, c *hubConn, r RequestMessage) error {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+
+	id := r.Device
+	if r.Station != 0 {
+		id = r.Station
+	}
+
+	group := ListenGroup(r.Type)
+	if _, ok := stopGroups[group]; ok {
+		sub := Subscription{Group: group, ID: id}
+		if c.subs[sub] {
+			return nil
+		}
+		if h.refs[sub] == 0 {
+			if err := h.listener.Subscribe(ctx, group, id); err != nil {
+				return err
+			}
+		}
+		h.refs[sub]++
+		c.subs[sub] = true
+		return nil
+	}
+
+	for start, stop := range stopGroups {
+		if stop == group && c.subs[Subscription{Group: start, ID: id}] {
+			return h.release(ctx, c, Subscription{Group: start, ID: id})
+		}
+	}
+	return nil
+}
+
+// release drops a consumer's reference to a subscription, stopping it when it was the last one
+func (h *Hub) release(ctx context.Context, c *hubConn, sub Subscription) error {
+	delete(c.subs, sub)
+	h.refs[sub]--
+	if h.refs[sub] > 0 {
+		return nil
+	}
+
+	delete(h.refs, sub)
+	if slices.Contains(h.base, sub) {
+		return nil
+	}
+	return h.listener.Unsubscribe(ctx, sub.Group, sub.ID)
+}
+
+// hubConn is the connection of a hub consumer
+type hubConn struct {
+	hub      *Hub
+	ch       chan []byte
+	done     chan struct{}
+	once     sync.Once
+	onError  func(ctx context.Context, err error)
+	failures atomic.Uint64
+	// subs are the subscriptions the consumer holds on the hub, guarded by the hub's subMu
+	subs map[Subscription]bool
+}
+
+// push queues a message, dropping the oldest queued message when the consumer is full
+func (c *hubConn) push(ctx context.Context, b []byte) {
+	for {
+		select {
+		case c.ch <- b:
+			return
+		default:
+		}
+
+		select {
+		case <-c.ch:
+			e, _ := EventFromContext(ctx)
+			c.onError(ctx, fmt.Errorf("%w: %s", ErrDropped, e))
+		default:
+		}
+	}
+}
+
+func (c *hubConn) end() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *hubConn) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-c.ch:
+		return b, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, io.EOF
+	}
+}
+
+// Write forwards listen requests to the hub. The hub's listener sends requests of its own, so once a request is applied,
+// after the api acknowledged it when the hub's listener awaits acks, the consumer is acked with its own request id.
+func (c *hubConn) Write(ctx context.Context, v any) error {
+	r, ok := v.(RequestMessage)
+	if !ok {
+		return nil
+	}
+	if err := c.hub.forward(ctx, c, r); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(ackMessage{Type: string(EventAck), ID: r.ID})
+	if err != nil {
+		return err
+	}
+	c.push(ctx, b)
+	return nil
+}
+
+func (c *hubConn) Close(ctx context.Context, codes ...websocket.StatusCode) error {
+	c.hub.remove(c)
+	c.end()
+	return nil
+}
+
+// Stats reports the hub's connection, with the messages this consumer dropped and failed to decode
+func (c *hubConn) Stats() connection.Stats {
+	stats, _ := c.hub.listener.Stats()
+	stats.DecodeFailures += c.failures.Load()
+	return stats
+}
+
+func (c *hubConn) RecordDecodeFailure() {
+	c.failures.Add(1)
+}
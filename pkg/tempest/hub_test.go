@@ -0,0 +1,177 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHubFanOut(t *testing.T) {
+	conn := newFakeConn()
+	hub := NewHub(NewEventListener(conn, ListenGroupStart, 1))
+
+	consumers := []Listener{hub.Subscribe(), hub.Subscribe(WithQueueSize(8))}
+	counts := make([]atomic.Int32, len(consumers))
+	done := make(chan error, len(consumers))
+	for i, c := range consumers {
+		c.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) { counts[i].Add(1) })
+		go func() { done <- c.Listen(context.Background()) }()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hubDone := make(chan error)
+	go func() { hubDone <- hub.Run(ctx) }()
+
+	if req := <-conn.writes; req.Device != 1 {
+		t.Fatalf("hub requested device %d, want 1", req.Device)
+	}
+
+	for range 3 {
+		conn.reads <- []byte(`{"type":"obs_st","device_id":1}`)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for counts[0].Load() != 3 || counts[1].Load() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("consumers handled %d and %d messages, want 3 each", counts[0].Load(), counts[1].Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-hubDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	for range consumers {
+		if err := <-done; !errors.Is(err, io.EOF) {
+			t.Errorf("consumer Listen() error = %v, want io.EOF once the hub stops", err)
+		}
+	}
+}
+
+func TestHubSlowConsumerDropsOldest(t *testing.T) {
+	hub := NewHub(NewEventListener(newFakeConn(), ListenGroupStart, 1))
+
+	var dropped atomic.Int32
+	consumer := hub.Subscribe(WithQueueSize(2), WithErrorHandler(func(ctx context.Context, err error) {
+		if errors.Is(err, ErrDropped) {
+			dropped.Add(1)
+		}
+	}))
+
+	for i := range 5 {
+		hub.broadcast(context.Background(), []byte{byte(i)})
+	}
+
+	c := consumer.(*EventListener).c
+	var got []byte
+	for range 2 {
+		b, _ := c.Read(context.Background())
+		got = append(got, b...)
+	}
+
+	if string(got) != string([]byte{3, 4}) {
+		t.Errorf("buffered = %v, want the newest messages [3 4]", got)
+	}
+	if n := dropped.Load(); n != 3 {
+		t.Errorf("dropped = %d, want 3", n)
+	}
+}
+
+func TestHubSharesSubscriptions(t *testing.T) {
+	conn := newFakeConn()
+	hub := NewHub(NewEventListener(conn, ListenGroupStart, 1))
+	a, b := hub.Subscribe(), hub.Subscribe()
+
+	if got := a.Subscriptions(); len(got) != 1 || got[0] != (Subscription{Group: ListenGroupStart, ID: 1}) {
+		t.Errorf("consumer subscriptions = %v, want the hub's", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	<-conn.writes
+
+	for _, c := range []Listener{a, b} {
+		go c.Listen(ctx)
+		waitRunning(t, c.(*EventListener))
+	}
+
+	steps := []struct {
+		name  string
+		do    func() error
+		wants string
+	}{
+		{name: "first consumer starts rapid wind", do: func() error { return a.Subscribe(ctx, ListenGroupRapidStart, 1) }, wants: string(ListenGroupRapidStart)},
+		{name: "second consumer shares it", do: func() error { return b.Subscribe(ctx, ListenGroupRapidStart, 1) }},
+		{name: "first consumer leaves it running", do: func() error { return a.Unsubscribe(ctx, ListenGroupRapidStart, 1) }},
+		{name: "last consumer stops it", do: func() error { return b.Unsubscribe(ctx, ListenGroupRapidStart, 1) }, wants: string(ListenGroupRapidStop)},
+		{name: "the hub's own subscription is kept", do: func() error { return a.Unsubscribe(ctx, ListenGroupStart, 1) }},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		select {
+		case req := <-conn.writes:
+			if req.Type != step.wants {
+				t.Errorf("%s: hub sent %s, want %q", step.name, req.Type, step.wants)
+			}
+		case <-time.After(20 * time.Millisecond):
+			if step.wants != "" {
+				t.Errorf("%s: hub sent nothing, want %s", step.name, step.wants)
+			}
+		}
+	}
+}
+
+func TestHubConsumerAcks(t *testing.T) {
+	conn := newFakeConn()
+	hub := NewHub(NewEventListener(conn, ListenGroupStart, 1, WithAckTimeout(time.Second)))
+	consumer := hub.Subscribe(WithAckTimeout(100 * time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	conn.ack(t)
+
+	done := make(chan error, 1)
+	go func() { done <- consumer.Listen(ctx) }()
+	waitRunning(t, consumer.(*EventListener))
+
+	subscribed := make(chan error, 1)
+	go func() { subscribed <- consumer.Subscribe(ctx, ListenGroupRapidStart, 1) }()
+	conn.ack(t)
+
+	if err := <-subscribed; err != nil {
+		t.Errorf("Subscribe() error = %v, want the consumer's request acked", err)
+	}
+
+	select {
+	case err := <-done:
+		t.Errorf("consumer Listen() error = %v, want its initial requests acked", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func waitRunning(t *testing.T, l *EventListener) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		l.mu.RLock()
+		running := l.running
+		l.mu.RUnlock()
+		if running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("listener did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -3,6 +3,13 @@ package tempest
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/kdwils/weatherstation/pkg/api"
@@ -11,81 +18,254 @@ import (
 
 type Handler func(ctx context.Context, b []byte)
 
+// ErrNotListening is returned when injecting a message into a listener that is not running
+var ErrNotListening = errors.New("listener is not running")
+
 // Listener describes how to listen to weather station device events
 type Listener interface {
 	Listen(ctx context.Context) error
 	RegisterHandler(e Event, hs ...Handler) error
+	AddHandler(e Event, h Handler) (remove func())
+	Use(mw ...Middleware)
+	Subscribe(ctx context.Context, group ListenGroup, ids ...int) error
+	Unsubscribe(ctx context.Context, group ListenGroup, ids ...int) error
+	Subscriptions() []Subscription
+	Inject(ctx context.Context, b []byte) error
+	Events(ctx context.Context, filters ...Filter) <-chan api.Event
+	Err() error
+	Stats() (connection.Stats, bool)
 }
 
-// EventListener implements the listener
+// EventListener implements the listener. ListenGroup, Devices and Stations are the subscriptions made when Listen starts,
+// use Subscribe and Unsubscribe to change them at runtime.
 type EventListener struct {
 	c           connection.Connection
-	Handlers    map[string][]Handler
 	ListenGroup ListenGroup
-	Device      int
+	Devices     []int
+	Stations    []int
+	onError     func(ctx context.Context, err error)
+	queueSize   int
+	overflow    OverflowPolicy
+	mu          sync.RWMutex
+	handlers    map[Event][]*registration
+	nextID      uint64
+	middleware  []Middleware
+	ackTimeout  time.Duration
+	ackMu       sync.Mutex
+	acks        map[string]chan error
+	subs        []Subscription
+	running     bool
+	dispatcher  *dispatcher
+	malformed   MalformedPolicy
+	deadLetters DeadLetterSink
+	unhandled   Handler
+	dedup       *Deduplicator
+	streams     map[*stream]struct{}
+	err         error
+}
+
+// Option configures optional behavior of an EventListener
+type Option func(*EventListener)
+
+// WithDevices subscribes the listener to additional devices on the same connection
+func WithDevices(devices ...int) Option {
+	return func(l *EventListener) {
+		l.Devices = appendIDs(l.Devices, devices...)
+	}
+}
+
+// WithStations subscribes the listener to station events (listen_start_events) for the given stations
+func WithStations(stations ...int) Option {
+	return func(l *EventListener) {
+		l.Stations = appendIDs(l.Stations, stations...)
+	}
+}
+
+// WithErrorHandler sets the function errors from handlers, such as typed handler decode failures, are reported to. Errors are logged by default.
+func WithErrorHandler(fn func(ctx context.Context, err error)) Option {
+	return func(l *EventListener) {
+		l.onError = fn
+	}
+}
+
+// defaultErrorHandler logs errors when no error handler is configured
+func defaultErrorHandler(ctx context.Context, err error) {
+	log.Printf("tempest: %v", err)
 }
 
-// NewEventListener creates a new listener from a connection
-func NewEventListener(c connection.Connection, ListenGroup ListenGroup, device int) Listener {
-	return &EventListener{
+// NewEventListener creates a new listener from a connection. A device of 0 is ignored, which allows listening on stations only.
+func NewEventListener(c connection.Connection, ListenGroup ListenGroup, device int, opts ...Option) Listener {
+	l := &EventListener{
 		c:           c,
-		Handlers:    make(map[string][]Handler),
+		handlers:    make(map[Event][]*registration),
 		ListenGroup: ListenGroup,
-		Device:      device,
+		Devices:     appendIDs(nil, device),
+		onError:     defaultErrorHandler,
+		acks:        make(map[string]chan error),
+		streams:     make(map[*stream]struct{}),
 	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	for _, device := range l.Devices {
+		l.subs = append(l.subs, Subscription{Group: l.ListenGroup, ID: device})
+	}
+	for _, station := range l.Stations {
+		l.subs = append(l.subs, Subscription{Group: ListenGroupStartEvents, ID: station})
+	}
+
+	return l
 }
 
 type RequestMessage struct {
-	Type   string `json:"type"`
-	ID     string `json:"id"`
-	Device int    `json:"device_id"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Device  int    `json:"device_id,omitempty"`
+	Station int    `json:"station_id,omitempty"`
 }
 
-func NewRequestMessage(Event ListenGroup, device int) RequestMessage {
-	return RequestMessage{
-		Type:   string(Event),
-		Device: device,
-		ID:     uuid.New().String(),
+// NewRequestMessage creates a request for a listen group. The id is sent as a station id for the station event groups and as a device id otherwise.
+func NewRequestMessage(Event ListenGroup, id int) RequestMessage {
+	r := RequestMessage{
+		Type: string(Event),
+		ID:   uuid.New().String(),
 	}
+
+	switch Event {
+	case ListenGroupStartEvents, ListenGroupStopEvents:
+		r.Station = id
+	default:
+		r.Device = id
+	}
+
+	return r
 }
 
-// Listen listens for new events and passes them each handler of that event type
-func (l *EventListener) Listen(ctx context.Context) error {
+// Listen listens for new events and passes them each handler of that event type.
+// Messages of the same event type are handled in order, one at a time, with handlers called in the order they were registered.
+// A message that is not valid json is handled by the malformed message policy, which stops Listen by default.
+// With an ack timeout, Listen returns a *SubscriptionError if any listen request is rejected or not acknowledged in time.
+func (l *EventListener) Listen(ctx context.Context) (err error) {
+	// runs last, once every dispatched message has been handled
+	defer func() { l.finish(err) }()
 	defer l.c.Close(ctx)
 
-	if err := l.c.Write(ctx, NewRequestMessage(l.ListenGroup, l.Device)); err != nil {
-		return err
+	ctx, cancel := context.WithCancelCause(ctx)
+	d := newDispatcher(l.queueSize, l.overflow, l.onError)
+	// handlers waiting on the context are released before the dispatcher waits for them
+	defer func() {
+		cancel(nil)
+		d.close()
+	}()
+
+	l.mu.Lock()
+	l.running = true
+	l.dispatcher = d
+	l.err = nil
+	subs := slices.Clone(l.subs)
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.running = false
+		l.dispatcher = nil
+		l.mu.Unlock()
+	}()
+
+	for _, sub := range subs {
+		wait, err := l.request(ctx, NewRequestMessage(sub.Group, sub.ID))
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			if err := wait(ctx); err != nil {
+				cancel(err)
+			}
+		}()
 	}
 
 	for {
 		b, err := l.c.Read(ctx)
 		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
 			return err
 		}
 
 		var o api.Observation
 		err = json.Unmarshal(b, &o)
 		if err != nil {
-			return err
-		}
-
-		hs, ok := l.Handlers[o.Type]
-		if !ok {
+			if err := l.reject(ctx, b, err); err != nil {
+				return err
+			}
 			continue
 		}
 
-		for _, h := range hs {
-			go h(ctx, b)
+		l.dispatch(ctx, d, b, o)
+	}
+}
+
+// dispatch passes a decoded message to the handlers of its event type
+func (l *EventListener) dispatch(ctx context.Context, d *dispatcher, b []byte, o api.Observation) {
+	m := newMessage(b, Event(o.Type))
+	m.decoded[reflect.TypeFor[api.Observation]()] = decoded{v: o}
+	mctx := withMessage(ctx, m, l.onError)
+
+	l.resolveAck(mctx, b)
+
+	if l.dedup != nil {
+		if dm, err := Decode[dedupMessage](mctx, b); err == nil && l.dedup.duplicate(dm) {
+			if r, ok := l.c.(connection.DuplicateRecorder); ok {
+				r.RecordDuplicate()
+			}
+			return
 		}
 	}
+
+	hs := l.handlersFor(Event(o.Type))
+	if len(hs) == 0 {
+		return
+	}
+
+	d.dispatch(job{ctx: mctx, event: Event(o.Type), b: b, handlers: hs})
 }
 
-// RegisterHandler registers new handlers for a given event type
-func (l *EventListener) RegisterHandler(Event Event, hs ...Handler) error {
-	if l.Handlers == nil {
-		l.Handlers = make(map[string][]Handler)
+// Inject passes a message to the handlers of a running listener as if it was read from the connection.
+// It is used for synthetic events and for messages recovered from other sources.
+func (l *EventListener) Inject(ctx context.Context, b []byte) error {
+	var o api.Observation
+	if err := json.Unmarshal(b, &o); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+
+	l.mu.RLock()
+	d := l.dispatcher
+	l.mu.RUnlock()
+
+	if d == nil {
+		return ErrNotListening
 	}
 
-	l.Handlers[string(Event)] = append(l.Handlers[string(Event)], hs...)
+	l.dispatch(ctx, d, b, o)
 	return nil
 }
+
+// Stats returns the statistics of the underlying connection, if the connection reports them
+func (l *EventListener) Stats() (connection.Stats, bool) {
+	return connection.StatsOf(l.c)
+}
+
+// appendIDs appends the non-zero ids that are not already present
+func appendIDs(ids []int, add ...int) []int {
+	for _, id := range add {
+		if id == 0 || slices.Contains(ids, id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
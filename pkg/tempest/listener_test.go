@@ -0,0 +1,223 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/tempest/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNewRequestMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		group       ListenGroup
+		id          int
+		wantDevice  int
+		wantStation int
+	}{
+		{
+			name:       "device group",
+			group:      ListenGroupStart,
+			id:         123,
+			wantDevice: 123,
+		},
+		{
+			name:       "rapid wind group",
+			group:      ListenGroupRapidStart,
+			id:         123,
+			wantDevice: 123,
+		},
+		{
+			name:        "station events group",
+			group:       ListenGroupStartEvents,
+			id:          456,
+			wantStation: 456,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewRequestMessage(tt.group, tt.id)
+			if got.Type != string(tt.group) {
+				t.Errorf("NewRequestMessage() type = %s, want %s", got.Type, tt.group)
+			}
+			if got.Device != tt.wantDevice {
+				t.Errorf("NewRequestMessage() device = %d, want %d", got.Device, tt.wantDevice)
+			}
+			if got.Station != tt.wantStation {
+				t.Errorf("NewRequestMessage() station = %d, want %d", got.Station, tt.wantStation)
+			}
+			if got.ID == "" {
+				t.Error("NewRequestMessage() id should not be empty")
+			}
+		})
+	}
+}
+
+func TestNewEventListener(t *testing.T) {
+	tests := []struct {
+		name         string
+		device       int
+		opts         []Option
+		wantDevices  []int
+		wantStations []int
+	}{
+		{
+			name:        "single device",
+			device:      1,
+			wantDevices: []int{1},
+		},
+		{
+			name:        "multiple devices",
+			device:      1,
+			opts:        []Option{WithDevices(2, 3, 1)},
+			wantDevices: []int{1, 2, 3},
+		},
+		{
+			name:         "stations only",
+			device:       0,
+			opts:         []Option{WithStations(10, 11)},
+			wantStations: []int{10, 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewEventListener(nil, ListenGroupStart, tt.device, tt.opts...).(*EventListener)
+			if !slices.Equal(l.Devices, tt.wantDevices) {
+				t.Errorf("devices = %v, want %v", l.Devices, tt.wantDevices)
+			}
+			if !slices.Equal(l.Stations, tt.wantStations) {
+				t.Errorf("stations = %v, want %v", l.Stations, tt.wantStations)
+			}
+		})
+	}
+}
+
+func TestEventListener_ListenSubscribesAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	conn := mocks.NewMockConnection(ctrl)
+
+	var written []RequestMessage
+	conn.EXPECT().Write(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, data any) error {
+		written = append(written, data.(RequestMessage))
+		return nil
+	}).Times(3)
+	conn.EXPECT().Read(gomock.Any()).Return(nil, errors.New("closed"))
+	conn.EXPECT().Close(gomock.Any()).Return(nil)
+
+	l := NewEventListener(conn, ListenGroupStart, 1, WithDevices(2), WithStations(10))
+	if err := l.Listen(context.Background()); err == nil {
+		t.Fatal("expected read error")
+	}
+
+	want := []RequestMessage{
+		{Type: string(ListenGroupStart), Device: 1},
+		{Type: string(ListenGroupStart), Device: 2},
+		{Type: string(ListenGroupStartEvents), Station: 10},
+	}
+	for i, w := range want {
+		if written[i].Type != w.Type || written[i].Device != w.Device || written[i].Station != w.Station {
+			t.Errorf("request %d = %+v, want %+v", i, written[i], w)
+		}
+	}
+}
+
+func TestRouteByDevice(t *testing.T) {
+	var got []int
+	route := func(id int) Handler {
+		return func(ctx context.Context, b []byte) {
+			got = append(got, id)
+		}
+	}
+
+	h := RouteByDevice(map[int]Handler{1: route(1), 2: route(2)})
+	h(context.Background(), []byte(`{"type":"obs_st","device_id":2}`))
+	h(context.Background(), []byte(`{"type":"obs_st","device_id":3}`))
+	h(context.Background(), []byte(`{"type":"obs_st","device_id":1}`))
+
+	if !slices.Equal(got, []int{2, 1}) {
+		t.Errorf("routed = %v, want [2 1]", got)
+	}
+}
+
+func TestForDevices(t *testing.T) {
+	var calls int
+	h := ForDevices(func(ctx context.Context, b []byte) { calls++ }, 1, 2)
+
+	h(context.Background(), []byte(`{"type":"obs_st","device_id":1}`))
+	h(context.Background(), []byte(`{"type":"obs_st","device_id":5}`))
+	h(context.Background(), []byte(`not json`))
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestInject(t *testing.T) {
+	conn := newFakeConn()
+	l := NewEventListener(conn, ListenGroupStart, 1)
+
+	if err := l.Inject(context.Background(), []byte(`{"type":"evt_device_stale","device_id":1}`)); !errors.Is(err, ErrNotListening) {
+		t.Fatalf("Inject() before Listen error = %v, want ErrNotListening", err)
+	}
+
+	received := make(chan []byte, 1)
+	l.AddHandler(EventDeviceStale, func(ctx context.Context, b []byte) { received <- b })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Listen(ctx)
+	<-conn.writes
+
+	msg := `{"type":"evt_device_stale","device_id":1}`
+	if err := l.Inject(ctx, []byte(msg)); err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+
+	select {
+	case b := <-received:
+		if string(b) != msg {
+			t.Errorf("handler received %s, want %s", b, msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("injected message was not dispatched")
+	}
+
+	if err := l.Inject(ctx, []byte(`{"type":`)); err == nil {
+		t.Error("Inject() of invalid json did not fail")
+	}
+}
+
+func TestListenReleasesHandlersOnReadError(t *testing.T) {
+	conn := scriptedConnection(t, `{"type":"obs_st","device_id":1}`)
+	l := NewEventListener(conn, ListenGroupStart, 1)
+
+	handling := make(chan struct{})
+	l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) {
+		close(handling)
+		<-ctx.Done()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- l.Listen(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Listen() error = nil, want the read error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Listen() did not return while a handler waited on its context")
+	}
+
+	select {
+	case <-handling:
+	default:
+		t.Error("the observation read before the error was not handled")
+	}
+}
@@ -0,0 +1,178 @@
+package tempest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/connection"
+)
+
+// MalformedPolicy decides what Listen does with a message that is not valid json
+type MalformedPolicy int
+
+const (
+	// MalformedFail stops Listen with a *MalformedError
+	MalformedFail MalformedPolicy = iota
+	// MalformedSkip discards the message and keeps listening
+	MalformedSkip
+	// MalformedSkipAndLog logs the message before discarding it
+	MalformedSkipAndLog
+)
+
+var malformedPolicies = map[string]MalformedPolicy{
+	"fail": MalformedFail,
+	"skip": MalformedSkip,
+	"log":  MalformedSkipAndLog,
+}
+
+// ParseMalformedPolicy parses a policy from its name: fail, skip or log
+func ParseMalformedPolicy(s string) (MalformedPolicy, error) {
+	p, ok := malformedPolicies[strings.ToLower(s)]
+	if !ok {
+		return MalformedFail, fmt.Errorf("unknown malformed message policy %q: expected fail, skip or log", s)
+	}
+	return p, nil
+}
+
+func (p MalformedPolicy) String() string {
+	for name, policy := range malformedPolicies {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("MalformedPolicy(%d)", int(p))
+}
+
+// MalformedError is returned by Listen when a message cannot be decoded and the policy is MalformedFail
+type MalformedError struct {
+	Payload []byte
+	Err     error
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("malformed message: %v", e.Err)
+}
+
+func (e *MalformedError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetter is a message rejected by the listener
+type DeadLetter struct {
+	Payload  []byte    `json:"payload"`
+	Error    string    `json:"error"`
+	Received time.Time `json:"received"`
+}
+
+// DeadLetterSink stores rejected messages so they can be inspected later
+type DeadLetterSink interface {
+	Store(ctx context.Context, d DeadLetter) error
+}
+
+// WithMalformedPolicy sets what happens when a message cannot be decoded. Defaults to MalformedFail.
+func WithMalformedPolicy(p MalformedPolicy) Option {
+	return func(l *EventListener) {
+		l.malformed = p
+	}
+}
+
+// WithDeadLetterSink stores every message that cannot be decoded in the sink, whatever the malformed message policy
+func WithDeadLetterSink(s DeadLetterSink) Option {
+	return func(l *EventListener) {
+		l.deadLetters = s
+	}
+}
+
+// reject handles a message that could not be decoded. It returns the error Listen should stop with, if any.
+func (l *EventListener) reject(ctx context.Context, b []byte, err error) error {
+	if r, ok := l.c.(connection.DecodeFailureRecorder); ok {
+		r.RecordDecodeFailure()
+	}
+
+	if l.deadLetters != nil {
+		d := DeadLetter{Payload: b, Error: err.Error(), Received: time.Now()}
+		if serr := l.deadLetters.Store(ctx, d); serr != nil {
+			log.Printf("tempest: storing dead letter: %v", serr)
+		}
+	}
+
+	switch l.malformed {
+	case MalformedSkip:
+		return nil
+	case MalformedSkipAndLog:
+		log.Printf("tempest: skipping malformed message %q: %v", b, err)
+		return nil
+	default:
+		return &MalformedError{Payload: b, Err: err}
+	}
+}
+
+// DeadLetterBuffer keeps the most recent dead letters in memory
+type DeadLetterBuffer struct {
+	mu      sync.Mutex
+	size    int
+	letters []DeadLetter
+}
+
+// NewDeadLetterBuffer creates a buffer holding up to size dead letters, discarding the oldest when full
+func NewDeadLetterBuffer(size int) *DeadLetterBuffer {
+	return &DeadLetterBuffer{size: max(size, 1)}
+}
+
+// Store adds a dead letter to the buffer
+func (b *DeadLetterBuffer) Store(ctx context.Context, d DeadLetter) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d.Payload = append([]byte(nil), d.Payload...)
+	b.letters = append(b.letters, d)
+	if len(b.letters) > b.size {
+		b.letters = b.letters[len(b.letters)-b.size:]
+	}
+	return nil
+}
+
+// Letters returns the buffered dead letters, oldest first
+func (b *DeadLetterBuffer) Letters() []DeadLetter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	letters := make([]DeadLetter, len(b.letters))
+	copy(letters, b.letters)
+	return letters
+}
+
+// DeadLetterWriter writes dead letters to w as newline delimited json
+type DeadLetterWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewDeadLetterWriter creates a sink that appends each dead letter to w as a json line
+func NewDeadLetterWriter(w io.Writer) *DeadLetterWriter {
+	return &DeadLetterWriter{w: w}
+}
+
+// Store writes a dead letter as a json line. The payload is written as a string, since it is usually not valid json.
+func (w *DeadLetterWriter) Store(ctx context.Context, d DeadLetter) error {
+	b, err := json.Marshal(struct {
+		Payload  string    `json:"payload"`
+		Error    string    `json:"error"`
+		Received time.Time `json:"received"`
+	}{string(d.Payload), d.Error, d.Received})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.w.Write(append(b, '\n'))
+	return err
+}
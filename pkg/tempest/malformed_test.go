@@ -0,0 +1,115 @@
+package tempest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestListenMalformedPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    MalformedPolicy
+		wantFail  bool
+		wantCalls int32
+	}{
+		{name: "fail stops listen", policy: MalformedFail, wantFail: true, wantCalls: 0},
+		{name: "skip keeps listening", policy: MalformedSkip, wantCalls: 1},
+		{name: "skip and log keeps listening", policy: MalformedSkipAndLog, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := []string{`{"type":`, `{"type":"obs_st","device_id":1}`}
+			if tt.wantFail {
+				messages = messages[:1]
+			}
+			conn := scriptedConnection(t, messages...)
+			sink := NewDeadLetterBuffer(10)
+
+			var calls atomic.Int32
+			l := NewEventListener(conn, ListenGroupStart, 1, WithMalformedPolicy(tt.policy), WithDeadLetterSink(sink))
+			l.AddHandler(EventObservationTempest, func(ctx context.Context, b []byte) {
+				calls.Add(1)
+			})
+
+			err := l.Listen(context.Background())
+
+			var malformed *MalformedError
+			if got := errors.As(err, &malformed); got != tt.wantFail {
+				t.Fatalf("Listen() error = %v, want malformed error %v", err, tt.wantFail)
+			}
+			if tt.wantFail && string(malformed.Payload) != `{"type":` {
+				t.Errorf("payload = %q, want the malformed message", malformed.Payload)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", got, tt.wantCalls)
+			}
+
+			letters := sink.Letters()
+			if len(letters) != 1 || string(letters[0].Payload) != `{"type":` || letters[0].Error == "" {
+				t.Errorf("dead letters = %+v, want the malformed message with its error", letters)
+			}
+		})
+	}
+}
+
+func TestParseMalformedPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    MalformedPolicy
+		wantErr bool
+	}{
+		{in: "fail", want: MalformedFail},
+		{in: "Skip", want: MalformedSkip},
+		{in: "log", want: MalformedSkipAndLog},
+		{in: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMalformedPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMalformedPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMalformedPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetterBufferKeepsNewest(t *testing.T) {
+	b := NewDeadLetterBuffer(2)
+	for _, p := range []string{"a", "b", "c"} {
+		b.Store(context.Background(), DeadLetter{Payload: []byte(p)})
+	}
+
+	letters := b.Letters()
+	if len(letters) != 2 || string(letters[0].Payload) != "b" || string(letters[1].Payload) != "c" {
+		t.Errorf("letters = %+v, want b and c", letters)
+	}
+}
+
+func TestDeadLetterWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewDeadLetterWriter(&buf)
+
+	if err := w.Store(context.Background(), DeadLetter{Payload: []byte(`{"type":`), Error: "unexpected end of JSON input"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	var got struct {
+		Payload string `json:"payload"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("dead letter is not a json line: %v", err)
+	}
+	if got.Payload != `{"type":` || got.Error != "unexpected end of JSON input" {
+		t.Errorf("dead letter = %+v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package tempest
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// Middleware wraps a handler with cross-cutting behavior
+type Middleware func(next Handler) Handler
+
+// Use adds middleware that wraps every handler. Middleware is applied when a message is dispatched,
+// so it also wraps handlers registered before Use was called. The first middleware added is the outermost.
+func (l *EventListener) Use(mw ...Middleware) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.middleware = append(l.middleware, mw...)
+}
+
+// chain wraps a handler with the listener's middleware
+func chain(h Handler, mw []Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// EventFromContext returns the event type of the message being handled
+func EventFromContext(ctx context.Context) (Event, bool) {
+	m, ok := ctx.Value(messageKey{}).(*message)
+	if !ok {
+		return "", false
+	}
+	return m.event, true
+}
+
+// ForEvents applies a middleware only to handlers of the given event types
+func ForEvents(mw Middleware, events ...Event) Middleware {
+	return func(next Handler) Handler {
+		wrapped := mw(next)
+		return func(ctx context.Context, b []byte) {
+			if e, ok := EventFromContext(ctx); ok && slices.Contains(events, e) {
+				wrapped(ctx, b)
+				return
+			}
+			next(ctx, b)
+		}
+	}
+}
+
+// FilterDevices only passes messages from the given devices to handlers
+func FilterDevices(devices ...int) Middleware {
+	return func(next Handler) Handler {
+		return ForDevices(next, devices...)
+	}
+}
+
+// Logging logs each handled message with its event type, device and how long the handler took
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, b []byte) {
+			start := time.Now()
+			next(ctx, b)
+
+			e, _ := EventFromContext(ctx)
+			attrs := []slog.Attr{
+				slog.String("event", string(e)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if o, err := Decode[api.Observation](ctx, b); err == nil && o.Device != 0 {
+				attrs = append(attrs, slog.Int("device_id", o.Device))
+			}
+
+			logger.LogAttrs(ctx, slog.LevelDebug, "handled tempest message", attrs...)
+		}
+	}
+}
+
+// Recover recovers a panicking handler and passes the panic value to fn, so the remaining handlers of the message still run
+func Recover(fn func(ctx context.Context, e Event, v any)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, b []byte) {
+			defer func() {
+				if r := recover(); r != nil {
+					e, _ := EventFromContext(ctx)
+					fn(ctx, e, r)
+				}
+			}()
+			next(ctx, b)
+		}
+	}
+}
+
+// Latency reports how long each handler took per event type
+func Latency(observe func(e Event, d time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, b []byte) {
+			start := time.Now()
+			defer func() {
+				e, _ := EventFromContext(ctx)
+				observe(e, time.Since(start))
+			}()
+			next(ctx, b)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tempest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dispatchContext returns a context as seen by handlers during dispatch of b
+func dispatchContext(e Event, b []byte) context.Context {
+	return withMessage(context.Background(), newMessage(b, e), nil)
+}
+
+func TestChainOrder(t *testing.T) {
+	var got []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, b []byte) {
+				got = append(got, name)
+				next(ctx, b)
+			}
+		}
+	}
+
+	h := chain(func(ctx context.Context, b []byte) { got = append(got, "handler") }, []Middleware{mw("first"), mw("second")})
+	h(context.Background(), nil)
+
+	want := []string{"first", "second", "handler"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestForEvents(t *testing.T) {
+	var wrapped []Event
+	mw := ForEvents(func(next Handler) Handler {
+		return func(ctx context.Context, b []byte) {
+			e, _ := EventFromContext(ctx)
+			wrapped = append(wrapped, e)
+			next(ctx, b)
+		}
+	}, EventRapidWind)
+
+	var calls int
+	h := mw(func(ctx context.Context, b []byte) { calls++ })
+
+	b := []byte(`{}`)
+	h(dispatchContext(EventRapidWind, b), b)
+	h(dispatchContext(EventObservationTempest, b), b)
+
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	if !slices.Equal(wrapped, []Event{EventRapidWind}) {
+		t.Errorf("wrapped = %v, want [%s]", wrapped, EventRapidWind)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var (
+		gotEvent Event
+		gotValue any
+	)
+	h := Recover(func(ctx context.Context, e Event, v any) {
+		gotEvent, gotValue = e, v
+	})(func(ctx context.Context, b []byte) {
+		panic("boom")
+	})
+
+	b := []byte(`{}`)
+	h(dispatchContext(EventLightingStrike, b), b)
+
+	if gotEvent != EventLightingStrike || gotValue != "boom" {
+		t.Errorf("recovered %s %v, want %s boom", gotEvent, gotValue, EventLightingStrike)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	var (
+		gotEvent Event
+		gotTime  time.Duration
+	)
+	h := Latency(func(e Event, d time.Duration) {
+		gotEvent, gotTime = e, d
+	})(func(ctx context.Context, b []byte) {
+		time.Sleep(5 * time.Millisecond)
+	})
+
+	b := []byte(`{}`)
+	h(dispatchContext(EventObservationTempest, b), b)
+
+	if gotEvent != EventObservationTempest {
+		t.Errorf("event = %s, want %s", gotEvent, EventObservationTempest)
+	}
+	if gotTime < 5*time.Millisecond {
+		t.Errorf("latency = %s, want at least 5ms", gotTime)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	h := Logging(logger)(func(ctx context.Context, b []byte) {})
+
+	b := []byte(`{"type":"obs_st","device_id":42}`)
+	h(dispatchContext(EventObservationTempest, b), b)
+
+	out := buf.String()
+	for _, want := range []string{"event=obs_st", "device_id=42", "duration="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestUseWrapsRegisteredHandlers(t *testing.T) {
+	conn := scriptedConnection(t, `{"type":"obs_st","device_id":1}`, `{"type":"obs_st","device_id":2}`)
+
+	var got []int
+	l := NewEventListener(conn, ListenGroupStart, 1)
+	l.RegisterHandler(EventObservationTempest, func(ctx context.Context, b []byte) {
+		o, _ := Decode[struct {
+			Device int `json:"device_id"`
+		}](ctx, b)
+		got = append(got, o.Device)
+	})
+	l.Use(FilterDevices(2))
+
+	l.Listen(context.Background())
+
+	if !slices.Equal(got, []int{2}) {
+		t.Errorf("handled devices = %v, want [2]", got)
+	}
+}
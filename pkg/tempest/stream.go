@@ -0,0 +1,190 @@
+package tempest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// Filter selects the events passed to an event stream
+type Filter func(v api.Event) bool
+
+// Types only passes events of the given types
+func Types(events ...Event) Filter {
+	return func(v api.Event) bool {
+		return slices.Contains(events, Event(v.EventType()))
+	}
+}
+
+var (
+	eventTypesMu sync.RWMutex
+	eventTypes   = make(map[Event]func(ctx context.Context, b []byte) (api.Event, error))
+)
+
+// RegisterEventType makes event streams decode messages of T's event type into T
+func RegisterEventType[T api.Event]() {
+	var zero T
+
+	eventTypesMu.Lock()
+	defer eventTypesMu.Unlock()
+
+	eventTypes[Event(zero.EventType())] = func(ctx context.Context, b []byte) (api.Event, error) {
+		return Decode[T](ctx, b)
+	}
+}
+
+func init() {
+	RegisterEventType[api.Ack]()
+	RegisterEventType[api.ConnectionOpened]()
+	RegisterEventType[api.ObservationTempest]()
+	RegisterEventType[api.RapidWind]()
+	RegisterEventType[api.LightningStrikeEvent]()
+	RegisterEventType[api.PrecipitationEvent]()
+	RegisterEventType[api.DeviceOnline]()
+	RegisterEventType[api.DeviceOffline]()
+	RegisterEventType[api.DeviceStale]()
+	RegisterEventType[api.StationOnline]()
+	RegisterEventType[api.StationOffline]()
+}
+
+// decodeEvent decodes a message into the type registered for its event type
+func decodeEvent(ctx context.Context, e Event, b []byte) (api.Event, bool, error) {
+	eventTypesMu.RLock()
+	decode, ok := eventTypes[e]
+	eventTypesMu.RUnlock()
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	v, err := decode(ctx, b)
+	return v, true, err
+}
+
+// stream is a buffered channel of events that can be closed while a handler is sending on it
+type stream struct {
+	ch   chan api.Event
+	done chan struct{}
+	once sync.Once
+	// mu is held for reading while sending so ch is never closed during a send
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newStream(size int) *stream {
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	return &stream{
+		ch:   make(chan api.Event, size),
+		done: make(chan struct{}),
+	}
+}
+
+// send waits for room in the buffer, so a slow consumer holds up the messages of the same event type. An event with room
+// in the buffer is always sent, even once ctx is done, so the messages drained when Listen returns still reach the stream.
+func (s *stream) send(ctx context.Context, v api.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- v:
+		return
+	default:
+	}
+
+	select {
+	case s.ch <- v:
+	case <-s.done:
+	case <-ctx.Done():
+	}
+}
+
+func (s *stream) close() {
+	s.once.Do(func() {
+		close(s.done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.ch)
+	})
+}
+
+// Events returns a channel of typed events that pass every filter. Messages are decoded into the type registered for their
+// event type with RegisterEventType, messages of other types are skipped. The channel buffers as many events as the listener's queue size.
+// It is closed when ctx is cancelled or Listen returns, after which Err reports why the listener stopped.
+//
+//	for e := range listener.Events(ctx, tempest.Types(tempest.EventObservationTempest)) {
+//		obs := e.(api.ObservationTempest)
+//	}
+func (l *EventListener) Events(ctx context.Context, filters ...Filter) <-chan api.Event {
+	s := newStream(l.queueSize)
+
+	remove := l.AddHandler(EventAll, func(ctx context.Context, b []byte) {
+		e, _ := EventFromContext(ctx)
+		v, ok, err := decodeEvent(ctx, e, b)
+		if !ok {
+			return
+		}
+		if err != nil {
+			reportError(ctx, fmt.Errorf("decoding %s: %w", e, err))
+			return
+		}
+
+		for _, f := range filters {
+			if !f(v) {
+				return
+			}
+		}
+		s.send(ctx, v)
+	})
+
+	l.mu.Lock()
+	l.streams[s] = struct{}{}
+	l.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+
+		remove()
+		l.mu.Lock()
+		delete(l.streams, s)
+		l.mu.Unlock()
+		s.close()
+	}()
+
+	return s.ch
+}
+
+// Err returns the error Listen returned, or nil while it has not returned
+func (l *EventListener) Err() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.err
+}
+
+// finish records the error Listen returned and closes every event stream
+func (l *EventListener) finish(err error) {
+	l.mu.Lock()
+	l.err = err
+	streams := make([]*stream, 0, len(l.streams))
+	for s := range l.streams {
+		streams = append(streams, s)
+	}
+	l.mu.Unlock()
+
+	for _, s := range streams {
+		s.close()
+	}
+}
@@ -0,0 +1,96 @@
+package tempest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    []string
+	}{
+		{
+			name: "every registered type",
+			want: []string{"obs_st", "rapid_wind", "evt_strike"},
+		},
+		{
+			name:    "filtered by type",
+			filters: []Filter{Types(EventRapidWind, EventLightingStrike)},
+			want:    []string{"rapid_wind", "evt_strike"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := scriptedConnection(t,
+				`{"type":"obs_st","device_id":1}`,
+				`{"type":"hub_status","serial_number":"HB-1"}`,
+				`{"type":"rapid_wind","device_id":1,"ob":[1588948614,0.27,144]}`,
+				`{"type":"evt_strike","device_id":1,"evt":[1493322445,27,3848]}`,
+			)
+
+			l := NewEventListener(conn, ListenGroupStart, 1)
+			events := l.Events(context.Background(), tt.filters...)
+
+			done := make(chan error)
+			go func() { done <- l.Listen(context.Background()) }()
+
+			var got []string
+			for e := range events {
+				got = append(got, e.EventType())
+				if w, ok := e.(api.RapidWind); ok && w.Data.WindDirectionDegrees != 144 {
+					t.Errorf("rapid wind = %+v, want a typed event", w)
+				}
+			}
+
+			err := <-done
+			if !sameElements(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+			if l.Err() == nil || l.Err().Error() != err.Error() {
+				t.Errorf("Err() = %v, want %v", l.Err(), err)
+			}
+		})
+	}
+}
+
+func TestEventsClosedOnCancel(t *testing.T) {
+	conn := newFakeConn()
+	l := NewEventListener(conn, ListenGroupStart, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := l.Events(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("received an event after cancelling")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("events were not closed after cancelling")
+	}
+
+	if n := len(l.(*EventListener).handlersFor(EventRapidWind)); n != 0 {
+		t.Errorf("handlers = %d, want the stream's handler removed", n)
+	}
+}
+
+// sameElements reports whether a and b hold the same values in any order, since events of different types are dispatched concurrently
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			return false
+		}
+	}
+	return true
+}
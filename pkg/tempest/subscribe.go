@@ -0,0 +1,133 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Subscription is an active listen group for a device, or a station for the station event groups
+type Subscription struct {
+	Group ListenGroup `json:"group"`
+	ID    int         `json:"id"`
+}
+
+// stopGroups maps each start group to the group that stops it
+var stopGroups = map[ListenGroup]ListenGroup{
+	ListenGroupStart:       ListenGroupStop,
+	ListenGroupStartEvents: ListenGroupStopEvents,
+	ListenGroupRapidStart:  ListenGroupRapidStop,
+}
+
+// StopGroup returns the group that stops a start group
+func StopGroup(g ListenGroup) (ListenGroup, bool) {
+	stop, ok := stopGroups[g]
+	return stop, ok
+}
+
+// Subscribe starts a listen group for each id. While the listener is running the requests are sent over the existing connection,
+// waiting for their acks when an ack timeout is configured. Otherwise they are sent when Listen starts.
+func (l *EventListener) Subscribe(ctx context.Context, group ListenGroup, ids ...int) error {
+	if _, ok := stopGroups[group]; !ok {
+		return fmt.Errorf("cannot subscribe to %s, expected a start group", group)
+	}
+
+	var errs []error
+	for _, id := range ids {
+		sub := Subscription{Group: group, ID: id}
+		if l.hasSubscription(sub) {
+			continue
+		}
+
+		add := func() {
+			if !slices.Contains(l.subs, sub) {
+				l.subs = append(l.subs, sub)
+			}
+		}
+		if l.pending(add) {
+			continue
+		}
+
+		if err := l.send(ctx, NewRequestMessage(group, id)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		l.mu.Lock()
+		add()
+		l.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Unsubscribe stops a listen group for each id. The group is the start group that was subscribed to, the matching stop group is sent.
+func (l *EventListener) Unsubscribe(ctx context.Context, group ListenGroup, ids ...int) error {
+	stop, ok := stopGroups[group]
+	if !ok {
+		return fmt.Errorf("cannot unsubscribe from %s, expected a start group", group)
+	}
+
+	var errs []error
+	for _, id := range ids {
+		sub := Subscription{Group: group, ID: id}
+		if !l.hasSubscription(sub) {
+			continue
+		}
+
+		remove := func() {
+			l.subs = slices.DeleteFunc(l.subs, func(s Subscription) bool { return s == sub })
+		}
+		if l.pending(remove) {
+			continue
+		}
+
+		if err := l.send(ctx, NewRequestMessage(stop, id)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		l.mu.Lock()
+		remove()
+		l.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Subscriptions returns the active subscriptions
+func (l *EventListener) Subscriptions() []Subscription {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Clone(l.subs)
+}
+
+func (l *EventListener) hasSubscription(sub Subscription) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Contains(l.subs, sub)
+}
+
+// pending applies a change to the subscriptions when the listener is not running, so it is sent when Listen starts, and
+// reports whether it did. The check and the change are made under the lock Listen takes its snapshot of the subscriptions
+// and sets running under, so a change is either in that snapshot or sent over the running connection.
+func (l *EventListener) pending(change func()) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running {
+		return false
+	}
+	change()
+	return true
+}
+
+// send writes a request over the running connection and waits for its ack
+func (l *EventListener) send(ctx context.Context, r RequestMessage) error {
+	wait, err := l.request(ctx, r)
+	if err != nil {
+		return err
+	}
+	return wait(ctx)
+}
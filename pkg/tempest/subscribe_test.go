@@ -0,0 +1,129 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSubscribeBeforeListen(t *testing.T) {
+	f := newFakeConn()
+	l := NewEventListener(f, ListenGroupStart, 1)
+
+	if err := l.Subscribe(context.Background(), ListenGroupRapidStart, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Subscription{{Group: ListenGroupStart, ID: 1}, {Group: ListenGroupRapidStart, ID: 1}}
+	if got := l.Subscriptions(); !slices.Equal(got, want) {
+		t.Errorf("subscriptions = %v, want %v", got, want)
+	}
+
+	close(f.reads)
+	l.Listen(context.Background())
+
+	for _, w := range want {
+		r := <-f.writes
+		if r.Type != string(w.Group) || r.Device != w.ID {
+			t.Errorf("request = %+v, want %+v", r, w)
+		}
+	}
+}
+
+func TestSubscribeRacingListen(t *testing.T) {
+	for range 100 {
+		f := newFakeConn()
+		l := NewEventListener(f, ListenGroupStart, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			l.Listen(ctx)
+			close(done)
+		}()
+
+		if err := l.Subscribe(ctx, ListenGroupRapidStart, 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// the subscription is either in the snapshot Listen sends or sent over the running connection
+		got := make(map[string]bool)
+		for len(got) < 2 {
+			select {
+			case r := <-f.writes:
+				got[r.Type] = true
+			case <-time.After(time.Second):
+				t.Fatalf("requests = %v, want %s and %s", got, ListenGroupStart, ListenGroupRapidStart)
+			}
+		}
+
+		cancel()
+		<-done
+	}
+}
+
+func TestSubscribeWhileListening(t *testing.T) {
+	f := newFakeConn()
+	l := NewEventListener(f, ListenGroupStart, 1, WithAckTimeout(time.Second))
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- l.Listen(context.Background())
+	}()
+	f.ack(t)
+
+	ctx := context.Background()
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- l.Subscribe(ctx, ListenGroupRapidStart, 1)
+	}()
+	f.ack(t)
+	if err := <-subscribed; err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	if !slices.Contains(l.Subscriptions(), Subscription{Group: ListenGroupRapidStart, ID: 1}) {
+		t.Errorf("subscriptions = %v, want rapid wind subscription", l.Subscriptions())
+	}
+
+	unsubscribed := make(chan error, 1)
+	go func() {
+		unsubscribed <- l.Unsubscribe(ctx, ListenGroupRapidStart, 1)
+	}()
+
+	select {
+	case r := <-f.writes:
+		if r.Type != string(ListenGroupRapidStop) || r.Device != 1 {
+			t.Errorf("request = %+v, want rapid stop for device 1", r)
+		}
+		f.reads <- []byte(`{"type":"ack","id":"` + r.ID + `"}`)
+	case <-time.After(time.Second):
+		t.Fatal("expected a stop request")
+	}
+
+	if err := <-unsubscribed; err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if slices.Contains(l.Subscriptions(), Subscription{Group: ListenGroupRapidStart, ID: 1}) {
+		t.Errorf("subscriptions = %v, rapid wind should be removed", l.Subscriptions())
+	}
+
+	close(f.reads)
+	if err := <-errs; !errors.Is(err, io.EOF) {
+		t.Errorf("Listen() error = %v, want EOF", err)
+	}
+}
+
+func TestSubscribeInvalidGroup(t *testing.T) {
+	l := NewEventListener(newFakeConn(), ListenGroupStart, 1)
+
+	if err := l.Subscribe(context.Background(), ListenGroupStop, 1); err == nil {
+		t.Error("expected error subscribing to a stop group")
+	}
+	if err := l.Unsubscribe(context.Background(), ListenGroupRapidStop, 1); err == nil {
+		t.Error("expected error unsubscribing from a stop group")
+	}
+}
@@ -0,0 +1,84 @@
+package tempesttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/coder/websocket"
+	"github.com/kdwils/weatherstation/pkg/simulate"
+)
+
+// ErrConnClosed is returned by Conn.Send once the connection is closed
+var ErrConnClosed = errors.New("tempesttest: connection closed")
+
+// Conn is an in-memory connection.Connection for testing listeners without a server. Read returns the messages passed to
+// Send in order, then io.EOF once End or Close is called and every buffered message has been read. Writes are discarded.
+type Conn struct {
+	reads chan []byte
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewConn creates a connection buffering up to buffer sent messages. With no buffer Send waits for each message to be read.
+func NewConn(buffer int) *Conn {
+	return &Conn{
+		reads: make(chan []byte, buffer),
+		done:  make(chan struct{}),
+	}
+}
+
+// Send queues a message to be read, encoded by simulate.Encode. It waits for room in the buffer.
+func (c *Conn) Send(ctx context.Context, v any) error {
+	b, err := simulate.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.done:
+		return ErrConnClosed
+	default:
+	}
+
+	select {
+	case c.reads <- b:
+		return nil
+	case <-c.done:
+		return ErrConnClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// End ends the stream of messages, so Read returns io.EOF once the buffered messages are read
+func (c *Conn) End() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// Read returns the next message sent on the connection
+func (c *Conn) Read(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-c.reads:
+		return b, nil
+	case <-c.done:
+		select {
+		case b := <-c.reads:
+			return b, nil
+		default:
+			return nil, io.EOF
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Write discards the message
+func (c *Conn) Write(ctx context.Context, v any) error { return nil }
+
+// Close ends the connection like End
+func (c *Conn) Close(ctx context.Context, codes ...websocket.StatusCode) error {
+	c.End()
+	return nil
+}
@@ -0,0 +1,87 @@
+package tempesttest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// LoadFixture reads newline delimited json messages, such as a capture of a station's feed, for WithScript or Play.
+// Blank lines are skipped.
+func LoadFixture(r io.Reader) ([]any, error) {
+	var messages []any
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		if !json.Valid(b) {
+			return nil, fmt.Errorf("line %d is not valid json", line)
+		}
+		messages = append(messages, json.RawMessage(bytes.Clone(b)))
+	}
+
+	return messages, scanner.Err()
+}
+
+// Observation returns a mild, dry obs_st observation of a device at a time
+func Observation(device int, t time.Time) api.ObservationTempest {
+	return api.ObservationTempest{
+		Type:   string(tempest.EventObservationTempest),
+		Device: device,
+		Data: api.ObservationTempestData{
+			TimeEpoch:                  int(t.Unix()),
+			WindLull:                   0.8,
+			WindAverage:                1.9,
+			WindGust:                   3.1,
+			WindDirectionDegrees:       210,
+			WindSampleInterval:         3,
+			StationPressure:            1013.2,
+			AirTemperature:             18.5,
+			RelativeHumidity:           62,
+			Illuminance:                24000,
+			UltraviolentIndex:          2.1,
+			SolarRadiation:             200,
+			BatteryVolts:               2.6,
+			ReportInterval:             1,
+			PrecipitationAnalysisType:  1,
+			LocalDailyRainAccumulation: 0,
+		},
+	}
+}
+
+// RapidWind returns a rapid_wind event of a device, speed in meters per second and direction in degrees
+func RapidWind(device int, t time.Time, speed, direction float64) api.RapidWind {
+	return api.RapidWind{
+		Type:   string(tempest.EventRapidWind),
+		Device: device,
+		Data:   api.RapidWindData{TimeEpoch: int(t.Unix()), WindSpeed: speed, WindDirectionDegrees: direction},
+	}
+}
+
+// Strike returns an evt_strike event of a device
+func Strike(device int, t time.Time, distanceKM, energy int) api.LightningStrikeEvent {
+	return api.LightningStrikeEvent{
+		Type:   string(tempest.EventLightingStrike),
+		Device: device,
+		Strike: api.LightningStrike{TimeEpoch: int(t.Unix()), DistanceInKM: distanceKM, Energy: energy},
+	}
+}
+
+// RainStart returns an evt_precip event of a device
+func RainStart(device int, t time.Time) api.PrecipitationEvent {
+	return api.PrecipitationEvent{
+		Type:   string(tempest.EventPrecipitation),
+		Device: device,
+		Data:   api.PrecipitationData{TimeEpoch: int(t.Unix())},
+	}
+}
@@ -0,0 +1,76 @@
+// Package tempesttest provides an in-process fake of the WeatherFlow websocket api and in-memory connections, so listeners
+// and the commands built on them can be tested end to end without a station or network access. A hub's udp broadcasts are
+// imitated by simulate.UDPHub.
+package tempesttest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/simulate"
+)
+
+// DefaultPath is the path the websocket api is served on, the same as the WeatherFlow api
+const DefaultPath = simulate.DefaultPath
+
+// Server is a simulate.Server started on a local port
+type Server struct {
+	*simulate.Server
+	// URL is the websocket url of the server, including the token, for connection.NewWebsocket
+	URL string
+	// Host and the Path of the server configure connection.NewConnection with the ws scheme
+	Host string
+
+	srv *httptest.Server
+}
+
+// Option configures a Server
+type Option = simulate.ServerOption
+
+// WithToken rejects connections that do not pass the token as their token query parameter
+func WithToken(token string) Option { return simulate.WithToken(token) }
+
+// WithScript sends messages to each connection once its first listen request is acknowledged, see simulate.WithScript
+func WithScript(messages ...any) Option { return simulate.WithScript(messages...) }
+
+// WithInterval waits between scripted messages, the first message is sent without waiting
+func WithInterval(d time.Duration) Option { return simulate.WithInterval(d) }
+
+// WithoutAcks never acknowledges listen requests, for testing ack timeouts
+func WithoutAcks() Option { return simulate.WithoutAcks() }
+
+// NewServer starts a server on a local port. Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{Server: simulate.NewServer(opts...)}
+
+	mux := http.NewServeMux()
+	mux.Handle(s.Path, s.Server)
+	s.srv = httptest.NewServer(mux)
+
+	u, _ := url.Parse(s.srv.URL)
+	s.Host = u.Host
+
+	u.Scheme = "ws"
+	u.Path = s.Path
+	qps := make(url.Values)
+	qps.Set("token", s.Token)
+	u.RawQuery = qps.Encode()
+	s.URL = u.String()
+
+	return s
+}
+
+// Dial opens a websocket connection to the server
+func (s *Server) Dial(ctx context.Context) (connection.Connection, error) {
+	return connection.NewWebsocket(ctx, s.URL, nil)
+}
+
+// Close closes every connection and shuts the server down
+func (s *Server) Close() {
+	s.Disconnect()
+	s.srv.Close()
+}
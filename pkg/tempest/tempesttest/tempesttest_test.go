@@ -0,0 +1,138 @@
+package tempesttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+func TestServer(t *testing.T) {
+	now := time.Unix(1588948614, 0)
+	s := NewServer(
+		WithToken("secret"),
+		WithScript(Observation(1, now), Observation(2, now), RapidWind(1, now, 2, 90), Observation(1, now.Add(time.Minute))),
+	)
+	defer s.Close()
+
+	conn, err := connection.NewConnection(context.Background(), "ws", s.Host, s.Path, "secret")
+	if err != nil {
+		t.Fatalf("NewConnection() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1, tempest.WithAckTimeout(time.Second))
+	events := l.Events(ctx)
+	go l.Listen(ctx)
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case e := <-events:
+			got = append(got, e.EventType())
+			if o, ok := e.(api.ObservationTempest); ok && o.Device != 1 {
+				t.Errorf("received an observation of device %d, which is not subscribed", o.Device)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("received %v, want connection_opened, ack and two observations", got)
+		}
+	}
+
+	want := []string{"connection_opened", "ack", "obs_st"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("received %v, want %v", got, want)
+	}
+
+	select {
+	case e := <-events:
+		if e.EventType() != "obs_st" {
+			t.Errorf("received %s without listening for rapid wind, want the second observation", e.EventType())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second observation was not received")
+	}
+
+	if reqs := s.Requests(); len(reqs) != 1 || reqs[0].Type != string(tempest.ListenGroupStart) || reqs[0].Device != 1 {
+		t.Errorf("requests = %+v, want one listen_start for device 1", reqs)
+	}
+}
+
+func TestServerRejectsToken(t *testing.T) {
+	s := NewServer(WithToken("secret"))
+	defer s.Close()
+
+	if _, err := connection.NewConnection(context.Background(), "ws", s.Host, s.Path, "wrong"); err == nil {
+		t.Error("NewConnection() with the wrong token succeeded")
+	}
+}
+
+func TestServerWithoutAcks(t *testing.T) {
+	s := NewServer(WithoutAcks())
+	defer s.Close()
+
+	conn, err := s.Dial(context.Background())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+
+	l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1, tempest.WithAckTimeout(50*time.Millisecond))
+	var subErr *tempest.SubscriptionError
+	if err := l.Listen(context.Background()); !errors.As(err, &subErr) {
+		t.Errorf("Listen() error = %v, want a SubscriptionError", err)
+	}
+}
+
+func TestConn(t *testing.T) {
+	now := time.Unix(1588948614, 0)
+	conn := NewConn(2)
+
+	l := tempest.NewEventListener(conn, tempest.ListenGroupStart, 1)
+	var got []int
+	tempest.On(l, func(ctx context.Context, obs api.ObservationTempest) {
+		got = append(got, obs.Data.TimeEpoch)
+	})
+
+	for _, obs := range []api.ObservationTempest{Observation(1, now), Observation(1, now.Add(time.Minute))} {
+		if err := conn.Send(context.Background(), obs); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	conn.End()
+
+	if err := l.Listen(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("Listen() error = %v, want io.EOF once the buffered messages are read", err)
+	}
+	if len(got) != 2 || got[0] != 1588948614 || got[1] != 1588948674 {
+		t.Errorf("observations = %v, want both buffered observations", got)
+	}
+
+	if err := conn.Send(context.Background(), Observation(1, now)); !errors.Is(err, ErrConnClosed) {
+		t.Errorf("Send() after End error = %v, want ErrConnClosed", err)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	fixture := `{"type":"evt_precip","device_id":1,"evt":[1493322445]}
+
+{"type":"rapid_wind","device_id":1,"ob":[1588948614,0.27,144]}
+`
+	got, err := LoadFixture(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("LoadFixture() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("loaded %d messages, want 2", len(got))
+	}
+
+	if _, err := LoadFixture(strings.NewReader("{\"type\":\n")); err == nil {
+		t.Error("LoadFixture() of invalid json succeeded")
+	}
+}
@@ -0,0 +1,106 @@
+package tempest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// message carries a raw message through dispatch so each payload type is decoded at most once, no matter how many handlers need it
+type message struct {
+	raw     []byte
+	event   Event
+	mu      sync.Mutex
+	decoded map[reflect.Type]decoded
+}
+
+type decoded struct {
+	v   any
+	err error
+}
+
+type messageKey struct{}
+
+type errorHandlerKey struct{}
+
+func newMessage(raw []byte, e Event) *message {
+	return &message{
+		raw:     raw,
+		event:   e,
+		decoded: make(map[reflect.Type]decoded),
+	}
+}
+
+// withMessage returns a context carrying the message being dispatched and the listener's error handler
+func withMessage(ctx context.Context, m *message, onError func(context.Context, error)) context.Context {
+	ctx = context.WithValue(ctx, messageKey{}, m)
+	return context.WithValue(ctx, errorHandlerKey{}, onError)
+}
+
+// decode unmarshals the raw message into T, reusing the result of an earlier decode of the same message
+func (m *message) decode(t reflect.Type, fn func() (any, error)) (any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if d, ok := m.decoded[t]; ok {
+		return d.v, d.err
+	}
+
+	v, err := fn()
+	m.decoded[t] = decoded{v: v, err: err}
+	return v, err
+}
+
+// Decode unmarshals b into T. When called from a handler with the bytes it was passed, the result is shared with every other handler of the same message.
+func Decode[T any](ctx context.Context, b []byte) (T, error) {
+	unmarshal := func() (any, error) {
+		var v T
+		err := json.Unmarshal(b, &v)
+		return v, err
+	}
+
+	m, ok := ctx.Value(messageKey{}).(*message)
+	if !ok || !sameBytes(m.raw, b) {
+		v, err := unmarshal()
+		return v.(T), err
+	}
+
+	v, err := m.decode(reflect.TypeFor[T](), unmarshal)
+	return v.(T), err
+}
+
+// On registers a typed handler and returns a func that removes it. The event type is taken from T and each message is decoded once before being passed to fn.
+// Decode errors are passed to the listener's error handler.
+//
+//	tempest.On(listener, func(ctx context.Context, obs api.ObservationTempest) { ... })
+func On[T api.Event](l Listener, fn func(ctx context.Context, v T)) (remove func()) {
+	var zero T
+	e := Event(zero.EventType())
+
+	return l.AddHandler(e, func(ctx context.Context, b []byte) {
+		v, err := Decode[T](ctx, b)
+		if err != nil {
+			reportError(ctx, fmt.Errorf("decoding %s: %w", e, err))
+			return
+		}
+		fn(ctx, v)
+	})
+}
+
+// reportError passes err to the error handler of the listener dispatching the current message
+func reportError(ctx context.Context, err error) {
+	if onError, ok := ctx.Value(errorHandlerKey{}).(func(context.Context, error)); ok && onError != nil {
+		onError(ctx, err)
+		return
+	}
+	defaultErrorHandler(ctx, err)
+}
+
+// sameBytes reports whether a and b are the same slice
+func sameBytes(a, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
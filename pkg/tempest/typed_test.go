@@ -0,0 +1,151 @@
+package tempest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+var decodes atomic.Int32
+
+// countingEvent counts how many times it is unmarshaled
+type countingEvent struct {
+	Device int
+}
+
+func (countingEvent) EventType() string { return "obs_st" }
+
+func (c *countingEvent) UnmarshalJSON(b []byte) error {
+	decodes.Add(1)
+	c.Device = 1
+	return nil
+}
+
+// failingEvent never decodes
+type failingEvent struct{}
+
+func (failingEvent) EventType() string { return "obs_st" }
+
+func (f *failingEvent) UnmarshalJSON(b []byte) error {
+	return errors.New("bad payload")
+}
+
+// scriptedConnection returns a mock connection that yields each message followed by a read error
+func scriptedConnection(t *testing.T, messages ...string) *mocks.MockConnection {
+	ctrl := gomock.NewController(t)
+	conn := mocks.NewMockConnection(ctrl)
+	conn.EXPECT().Write(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	conn.EXPECT().Close(gomock.Any()).Return(nil).AnyTimes()
+
+	calls := make([]any, 0, len(messages)+1)
+	for _, m := range messages {
+		calls = append(calls, conn.EXPECT().Read(gomock.Any()).Return([]byte(m), nil))
+	}
+	calls = append(calls, conn.EXPECT().Read(gomock.Any()).Return(nil, errors.New("closed")).AnyTimes())
+	gomock.InOrder(calls...)
+
+	return conn
+}
+
+func TestOnDecodesOncePerMessage(t *testing.T) {
+	decodes.Store(0)
+	conn := scriptedConnection(t, `{"type":"obs_st","device_id":1}`)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	l := NewEventListener(conn, ListenGroupStart, 1)
+	for range 3 {
+		On(l, func(ctx context.Context, v countingEvent) {
+			defer wg.Done()
+			if v.Device != 1 {
+				t.Errorf("device = %d, want 1", v.Device)
+			}
+		})
+	}
+
+	l.Listen(context.Background())
+	waitTimeout(t, &wg)
+
+	if got := decodes.Load(); got != 1 {
+		t.Errorf("decodes = %d, want 1", got)
+	}
+}
+
+func TestOnReportsDecodeErrors(t *testing.T) {
+	conn := scriptedConnection(t, `{"type":"obs_st","device_id":1}`)
+
+	errs := make(chan error, 1)
+	l := NewEventListener(conn, ListenGroupStart, 1, WithErrorHandler(func(ctx context.Context, err error) {
+		errs <- err
+	}))
+
+	On(l, func(ctx context.Context, v failingEvent) {
+		t.Error("handler should not be called")
+	})
+
+	l.Listen(context.Background())
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected decode error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected error handler to be called")
+	}
+}
+
+func TestOnTypedPayload(t *testing.T) {
+	conn := scriptedConnection(t, `{"type":"rapid_wind","device_id":7,"ob":[1588948614,0.27,144]}`)
+
+	got := make(chan api.RapidWind, 1)
+	l := NewEventListener(conn, ListenGroupStart, 7)
+	On(l, func(ctx context.Context, v api.RapidWind) {
+		got <- v
+	})
+
+	l.Listen(context.Background())
+
+	select {
+	case v := <-got:
+		if v.Device != 7 || v.Data.TimeEpoch != 1588948614 || v.Data.WindSpeed != 0.27 || v.Data.WindDirectionDegrees != 144 {
+			t.Errorf("rapid wind = %+v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected handler to be called")
+	}
+}
+
+func TestDecodeWithoutListener(t *testing.T) {
+	o, err := Decode[api.Observation](context.Background(), []byte(`{"type":"obs_st","device_id":3}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.Device != 3 {
+		t.Errorf("device = %d, want 3", o.Device)
+	}
+}
+
+func waitTimeout(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for handlers")
+	}
+}
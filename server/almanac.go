@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/almanac"
+)
+
+// HandleAlmanac returns the almanac of a device as a json array of summaries, today's highs and lows and the records of this
+// month, this year and all time. The device query parameter defaults to the first aggregated device, period selects a single
+// day, month, year or all summary, and date is an RFC3339 time in the period to summarize, now by default.
+func (s *Server) HandleAlmanac() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.almanac == nil {
+			http.Error(w, "rollups are not aggregated", http.StatusNotFound)
+			return
+		}
+
+		params := r.URL.Query()
+
+		var device int
+		if devices := s.almanac.Devices(); len(devices) > 0 {
+			device = devices[0]
+		}
+		if v := params.Get("device"); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			device = d
+		}
+
+		t := time.Now()
+		if v := params.Get("date"); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			t = parsed
+		}
+
+		var summaries []almanac.Summary
+		var err error
+		if v := params.Get("period"); v != "" {
+			p, perr := almanac.ParsePeriod(v)
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			var summary almanac.Summary
+			summary, err = s.almanac.Summary(r.Context(), device, p, t)
+			summaries = []almanac.Summary{summary}
+		} else {
+			summaries, err = s.almanac.Summaries(r.Context(), device, t)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summaries)
+	}
+}
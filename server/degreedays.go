@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+)
+
+// degreeDays is the response of /degree-days
+type degreeDays struct {
+	degreedays.Summary
+	Days []degreedays.Day `json:"days"`
+}
+
+// HandleDegreeDays returns the degree days of a device as json: those of the day containing date, an RFC3339 time defaulting
+// to now, its season to date, the config they are computed with, and each day between start and end, RFC3339 times defaulting
+// to the season to date. The device query parameter defaults to the first aggregated device.
+func (s *Server) HandleDegreeDays() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.degreeDays == nil {
+			http.Error(w, "degree days are not computed", http.StatusNotFound)
+			return
+		}
+
+		params := r.URL.Query()
+
+		var device int
+		if devices := s.degreeDays.Devices(); len(devices) > 0 {
+			device = devices[0]
+		}
+		if v := params.Get("device"); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			device = d
+		}
+
+		date, start, end := time.Now(), time.Time{}, time.Time{}
+		for name, t := range map[string]*time.Time{"date": &date, "start": &start, "end": &end} {
+			v := params.Get(name)
+			if v == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+		if start.IsZero() {
+			start = s.degreeDays.SeasonStart(date)
+		}
+
+		var response degreeDays
+		var err error
+		if response.Summary, err = s.degreeDays.Summary(r.Context(), device, date); err == nil {
+			response.Days, err = s.degreeDays.Days(r.Context(), device, start, end)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
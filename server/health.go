@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/presence"
+)
+
+// staleAfter is how long a connection can go without a message before it is reported as degraded
+const staleAfter = 5 * time.Minute
+
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+	HealthUnknown  = "unknown"
+)
+
+// Health describes whether data is flowing from the weather station
+type Health struct {
+	Status     string            `json:"status"`
+	Connection *connection.Stats `json:"connection,omitempty"`
+	Devices    []presence.Status `json:"devices,omitempty"`
+}
+
+// health derives the health of the listener's connection and devices at the given time. It is degraded while any device is not online.
+func (s *Server) health(now time.Time) Health {
+	stats, ok := s.listener.Stats()
+	if !ok {
+		return Health{Status: HealthUnknown}
+	}
+
+	h := Health{
+		Status:     HealthOK,
+		Connection: &stats,
+	}
+	if s.presence != nil {
+		h.Devices = s.presence.Devices()
+	}
+
+	switch {
+	case stats.State != connection.StateOpen:
+		h.Status = HealthDown
+	case stats.LastMessage.IsZero(), now.Sub(stats.LastMessage) > staleAfter:
+		h.Status = HealthDegraded
+	}
+
+	for _, d := range h.Devices {
+		if h.Status == HealthOK && d.State != presence.StateOnline {
+			h.Status = HealthDegraded
+		}
+	}
+
+	return h
+}
+
+// HandleHealth reports the connection health as json. It responds with 503 when the connection is down.
+func (s *Server) HandleHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := s.health(time.Now())
+
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status == HealthDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := json.NewEncoder(w).Encode(h); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HandleMetrics exposes the connection statistics in the prometheus text format
+func (s *Server) HandleMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		stats, ok := s.listener.Stats()
+		if !ok {
+			return
+		}
+
+		up := 0
+		if stats.State == connection.StateOpen {
+			up = 1
+		}
+
+		var lastMessage float64
+		if !stats.LastMessage.IsZero() {
+			lastMessage = float64(stats.LastMessage.UnixNano()) / float64(time.Second)
+		}
+
+		metrics := []struct {
+			name  string
+			kind  string
+			help  string
+			value any
+		}{
+			{"weatherstation_connection_up", "gauge", "Whether the connection is open.", up},
+			{"weatherstation_connection_messages_read_total", "counter", "Messages read from the connection.", stats.MessagesRead},
+			{"weatherstation_connection_messages_written_total", "counter", "Messages written to the connection.", stats.MessagesWritten},
+			{"weatherstation_connection_bytes_read_total", "counter", "Bytes read from the connection.", stats.BytesRead},
+			{"weatherstation_connection_bytes_written_total", "counter", "Bytes written to the connection.", stats.BytesWritten},
+			{"weatherstation_connection_decode_failures_total", "counter", "Messages that could not be decoded.", stats.DecodeFailures},
+			{"weatherstation_connection_duplicates_total", "counter", "Messages discarded as duplicates.", stats.Duplicates},
+			{"weatherstation_connection_reconnects_total", "counter", "Reconnects of the underlying transport.", stats.Reconnects},
+			{"weatherstation_connection_last_message_timestamp_seconds", "gauge", "Unix time of the last message read.", lastMessage},
+		}
+
+		for _, m := range metrics {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+		}
+
+		if s.presence == nil {
+			return
+		}
+
+		fmt.Fprintf(w, "# HELP weatherstation_device_online Whether the device is reporting.\n# TYPE weatherstation_device_online gauge\n")
+		for _, d := range s.presence.Devices() {
+			online := 0
+			if d.State == presence.StateOnline {
+				online = 1
+			}
+			fmt.Fprintf(w, "weatherstation_device_online{device_id=\"%d\"} %d\n", d.Device, online)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/store"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// defaultHistory is how far back /history reaches when no start is given
+const defaultHistory = 24 * time.Hour
+
+// HandleHistory returns the stored messages of a device as a json array. The device query parameter defaults to the first
+// stored device, type is a comma separated list of message types defaulting to obs_st, and start and end are RFC3339 times
+// defaulting to the last 24 hours.
+func (s *Server) HandleHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.store == nil {
+			http.Error(w, "history is not stored", http.StatusNotFound)
+			return
+		}
+
+		q, err := historyQuery(r, s.store.Devices(), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		records, err := s.store.Query(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		messages := make([]json.RawMessage, 0, len(records))
+		for _, rec := range records {
+			messages = append(messages, rec.Data)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(messages)
+	}
+}
+
+// historyQuery reads a store query from the request's query parameters
+func historyQuery(r *http.Request, devices []int, now time.Time) (store.Query, error) {
+	params := r.URL.Query()
+	q := store.Query{
+		Types: []string{string(tempest.EventObservationTempest)},
+		Start: now.Add(-defaultHistory),
+	}
+
+	if len(devices) > 0 {
+		q.Device = devices[0]
+	}
+	if v := params.Get("device"); v != "" {
+		device, err := strconv.Atoi(v)
+		if err != nil {
+			return q, err
+		}
+		q.Device = device
+	}
+
+	if v := params.Get("type"); v != "" {
+		q.Types = strings.Split(v, ",")
+	}
+
+	for name, t := range map[string]*time.Time{"start": &q.Start, "end": &q.End} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, err
+		}
+		*t = parsed
+	}
+
+	return q, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/rain"
+)
+
+// defaultRainRange is how far back /rain lists daily totals and events when no start is given
+const defaultRainRange = 30 * 24 * time.Hour
+
+// rainLedger is the response of /rain
+type rainLedger struct {
+	Totals []rain.Totals `json:"totals"`
+	Daily  []rain.Totals `json:"daily"`
+	Events []rain.Event  `json:"events"`
+}
+
+// HandleRain returns the rain ledger of a device as json: the totals of the day, month, year and water year containing date,
+// an RFC3339 time defaulting to now, and the daily totals and rain events between start and end, RFC3339 times defaulting
+// to the last 30 days. The device query parameter defaults to the first aggregated device.
+func (s *Server) HandleRain() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.rain == nil {
+			http.Error(w, "rain is not kept", http.StatusNotFound)
+			return
+		}
+
+		params := r.URL.Query()
+		now := time.Now()
+
+		var device int
+		if devices := s.rollups.Devices(); len(devices) > 0 {
+			device = devices[0]
+		}
+		if v := params.Get("device"); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			device = d
+		}
+
+		date, start, end := now, now.Add(-defaultRainRange), time.Time{}
+		for name, t := range map[string]*time.Time{"date": &date, "start": &start, "end": &end} {
+			v := params.Get(name)
+			if v == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+
+		var ledger rainLedger
+		var err error
+		if ledger.Totals, err = s.rain.Summary(r.Context(), device, date); err == nil {
+			if ledger.Daily, err = s.rain.Daily(r.Context(), device, start, end); err == nil {
+				ledger.Events, err = s.rain.Events(r.Context(), device, start, end)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ledger)
+	}
+}
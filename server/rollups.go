@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/rollup"
+)
+
+// defaultRollupRange is how far back /rollups reaches for each resolution when no start is given
+var defaultRollupRange = map[rollup.Resolution]time.Duration{
+	rollup.Minute: 24 * time.Hour,
+	rollup.Hour:   30 * 24 * time.Hour,
+	rollup.Day:    365 * 24 * time.Hour,
+}
+
+// HandleRollups returns the rollup buckets of a device as a json array. The resolution query parameter is minute, hour or day,
+// defaulting to hour, device defaults to the first aggregated device, and start and end are RFC3339 times defaulting to the last
+// day of minutes, 30 days of hours or year of days.
+func (s *Server) HandleRollups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.rollups == nil {
+			http.Error(w, "rollups are not aggregated", http.StatusNotFound)
+			return
+		}
+
+		q, err := rollupQuery(r, s.rollups.Devices(), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		buckets, err := s.rollups.Query(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if buckets == nil {
+			buckets = []rollup.Bucket{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buckets)
+	}
+}
+
+// rollupQuery reads a rollup query from the request's query parameters
+func rollupQuery(r *http.Request, devices []int, now time.Time) (rollup.Query, error) {
+	params := r.URL.Query()
+	q := rollup.Query{Resolution: rollup.Hour}
+
+	if v := params.Get("resolution"); v != "" {
+		resolution, err := rollup.ParseResolution(v)
+		if err != nil {
+			return q, err
+		}
+		q.Resolution = resolution
+	}
+	q.Start = now.Add(-defaultRollupRange[q.Resolution])
+
+	if len(devices) > 0 {
+		q.Device = devices[0]
+	}
+	if v := params.Get("device"); v != "" {
+		device, err := strconv.Atoi(v)
+		if err != nil {
+			return q, err
+		}
+		q.Device = device
+	}
+
+	for name, t := range map[string]*time.Time{"start": &q.Start, "end": &q.End} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, err
+		}
+		*t = parsed
+	}
+
+	return q, nil
+}
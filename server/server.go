@@ -3,84 +3,109 @@ package server
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"sync"
 
+	"github.com/kdwils/weatherstation/pkg/almanac"
 	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+	"github.com/kdwils/weatherstation/pkg/presence"
+	"github.com/kdwils/weatherstation/pkg/rain"
+	"github.com/kdwils/weatherstation/pkg/rollup"
+	"github.com/kdwils/weatherstation/pkg/store"
 	"github.com/kdwils/weatherstation/pkg/tempest"
 	"github.com/kdwils/weatherstation/templates"
 )
 
 type Server struct {
 	listener          tempest.Listener
+	presence          *presence.Tracker
 	latestObservation *api.ObservationTempest
 	mu                sync.RWMutex
-	clients           map[chan api.ObservationTempest]bool
-	events            chan api.ObservationTempest
-	clientsMu         sync.RWMutex
 	port              int
+	windMu            sync.Mutex
+	windClients       int
+	store             *store.Store
+	rollups           *rollup.Aggregator
+	almanac           *almanac.Almanac
+	rain              *rain.Ledger
+	degreeDays        *degreedays.Calculator
 }
 
-// New creates a new dashboard expecting a configured tempest listener
-func New(listener tempest.Listener, port int) *Server {
+// Option configures optional behavior of a Server
+type Option func(*Server)
+
+// WithStore serves the history kept in a store on /history
+func WithStore(st *store.Store) Option {
+	return func(s *Server) {
+		s.store = st
+	}
+}
+
+// WithRollups serves the rollups of an aggregator on /rollups, and the almanac of its day rollups on /almanac and the dashboard
+func WithRollups(a *rollup.Aggregator) Option {
+	return func(s *Server) {
+		s.rollups = a
+	}
+}
+
+// WithRain serves the rain ledger on /rain and the dashboard. It totals the day rollups, so it is only served along with them.
+func WithRain(l *rain.Ledger) Option {
+	return func(s *Server) {
+		s.rain = l
+	}
+}
+
+// WithDegreeDays serves degree days on /degree-days and the dashboard. They are computed from the day rollups, so they are
+// only served along with them.
+func WithDegreeDays(c *degreedays.Calculator) Option {
+	return func(s *Server) {
+		s.degreeDays = c
+	}
+}
+
+// New creates a new dashboard expecting a configured tempest listener. The caller runs the listener, which can be a hub consumer.
+func New(listener tempest.Listener, port int, opts ...Option) *Server {
 	s := &Server{
 		listener:          listener,
+		presence:          presence.New(listener),
 		mu:                sync.RWMutex{},
 		latestObservation: &api.ObservationTempest{},
-		events:            make(chan api.ObservationTempest),
-		clients:           make(map[chan api.ObservationTempest]bool),
 		port:              port,
 	}
 
-	// Start global event handler
-	go s.handleEvents()
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.rollups != nil {
+		s.almanac = almanac.New(s.rollups)
+	} else {
+		s.rain, s.degreeDays = nil, nil
+	}
 
 	// Register global observation handler
-	s.listener.RegisterHandler(tempest.EventObservationTempest, s.handleObservation)
+	tempest.On(s.listener, s.handleObservation)
 
-	// Start listener in background
-	go func() {
-		if err := s.listener.Listen(context.Background()); err != nil {
-			log.Printf("global listener error: %v", err)
-		}
-	}()
+	go s.presence.Run(context.Background())
 
 	return s
 }
 
-func (s *Server) handleObservation(ctx context.Context, b []byte) {
-	var obs api.ObservationTempest
-	if err := json.Unmarshal(b, &obs); err != nil {
-		log.Printf("error unmarshaling observation: %v", err)
+func (s *Server) handleObservation(ctx context.Context, obs api.ObservationTempest) {
+	if obs.Source == api.SourceBackfill {
 		return
 	}
 
 	s.mu.Lock()
 	s.latestObservation = &obs
 	s.mu.Unlock()
-
-	s.events <- obs
-}
-
-func (s *Server) handleEvents() {
-	for obs := range s.events {
-		s.clientsMu.RLock()
-		for clientChan := range s.clients {
-			select {
-			case clientChan <- obs:
-			default:
-			}
-		}
-		s.clientsMu.RUnlock()
-	}
 }
 
 func (s *Server) HandleHome() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := templates.Dashboard(s.latestObservation, s.port).Render(r.Context(), w)
+		err := templates.Dashboard(s.latestObservation, s.presence.Devices(), s.summaries(r.Context(), s.latestObservation.Device), s.port).Render(r.Context(), w)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -96,16 +121,14 @@ func (s *Server) HandleEvents() http.HandlerFunc {
 
 		clientChan := make(chan api.ObservationTempest, 1)
 
-		s.clientsMu.Lock()
-		s.clients[clientChan] = true
-		s.clientsMu.Unlock()
-
-		defer func() {
-			s.clientsMu.Lock()
-			delete(s.clients, clientChan)
-			close(clientChan)
-			s.clientsMu.Unlock()
-		}()
+		// Each stream gets its own handler, removed once the browser disconnects
+		remove := tempest.On(s.listener, func(ctx context.Context, obs api.ObservationTempest) {
+			select {
+			case clientChan <- obs:
+			default:
+			}
+		})
+		defer remove()
 
 		for {
 			select {
@@ -113,7 +136,7 @@ func (s *Server) HandleEvents() http.HandlerFunc {
 				return
 			case obs := <-clientChan:
 				var buf bytes.Buffer
-				if err := templates.Dashboard(&obs, s.port).Render(r.Context(), &buf); err != nil {
+				if err := templates.Dashboard(&obs, s.presence.Devices(), s.summaries(r.Context(), obs.Device), s.port).Render(r.Context(), &buf); err != nil {
 					log.Printf("error rendering template: %v", err)
 					continue
 				}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kdwils/weatherstation/templates"
+)
+
+// recentRain is how far back the dashboard looks for the last rain event
+const recentRain = 30 * 24 * time.Hour
+
+// summaries returns the almanac, rain totals and degree days of a device for the dashboard, leaving out what is not kept or has nothing to show
+func (s *Server) summaries(ctx context.Context, device int) templates.Summaries {
+	var summaries templates.Summaries
+	now := time.Now()
+
+	if s.almanac != nil {
+		almanac, err := s.almanac.Summaries(ctx, device, now)
+		if err != nil {
+			log.Printf("error summarizing almanac: %v", err)
+		} else if almanac[len(almanac)-1].Days > 0 {
+			summaries.Almanac = almanac
+		}
+	}
+
+	if s.rain != nil {
+		totals, err := s.rain.Summary(ctx, device, now)
+		if err != nil {
+			log.Printf("error totaling rain: %v", err)
+		} else if totals[len(totals)-1].Days > 0 {
+			summaries.Rain = totals
+		}
+
+		events, err := s.rain.Events(ctx, device, now.Add(-recentRain), time.Time{})
+		if err != nil {
+			log.Printf("error reading rain events: %v", err)
+		} else if len(events) > 0 {
+			summaries.LastRain = &events[len(events)-1]
+		}
+	}
+
+	if s.degreeDays != nil {
+		degreeDays, err := s.degreeDays.Summary(ctx, device, now)
+		if err != nil {
+			log.Printf("error computing degree days: %v", err)
+		} else if degreeDays.Season.Days > 0 {
+			summaries.DegreeDays = &degreeDays
+		}
+	}
+
+	return summaries
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/tempest"
+)
+
+// windDevices returns the devices the listener observes, which are the devices rapid wind is requested for
+func (s *Server) windDevices() []int {
+	var devices []int
+	for _, sub := range s.listener.Subscriptions() {
+		if sub.Group == tempest.ListenGroupStart {
+			devices = append(devices, sub.ID)
+		}
+	}
+	return devices
+}
+
+// watchWind subscribes to rapid wind when the first client connects
+func (s *Server) watchWind(ctx context.Context) error {
+	s.windMu.Lock()
+	defer s.windMu.Unlock()
+
+	if s.windClients == 0 {
+		if err := s.listener.Subscribe(ctx, tempest.ListenGroupRapidStart, s.windDevices()...); err != nil {
+			return err
+		}
+	}
+	s.windClients++
+	return nil
+}
+
+// unwatchWind unsubscribes from rapid wind when the last client disconnects
+func (s *Server) unwatchWind(ctx context.Context) {
+	s.windMu.Lock()
+	defer s.windMu.Unlock()
+
+	s.windClients--
+	if s.windClients > 0 {
+		return
+	}
+
+	if err := s.listener.Unsubscribe(ctx, tempest.ListenGroupRapidStart, s.windDevices()...); err != nil {
+		log.Printf("error unsubscribing from rapid wind: %v", err)
+	}
+}
+
+// HandleWindEvents streams rapid wind events as json. Rapid wind is only requested from the station while at least one client is connected.
+func (s *Server) HandleWindEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.watchWind(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer s.unwatchWind(context.Background())
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		clientChan := make(chan api.RapidWind, 1)
+		remove := tempest.On(s.listener, func(ctx context.Context, wind api.RapidWind) {
+			select {
+			case clientChan <- wind:
+			default:
+			}
+		})
+		defer remove()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case wind := <-clientChan:
+				b, err := json.Marshal(wind)
+				if err != nil {
+					log.Printf("error encoding rapid wind: %v", err)
+					continue
+				}
+
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+					log.Printf("error writing SSE data: %v", err)
+					return
+				}
+
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+		}
+	}
+}
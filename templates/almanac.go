@@ -0,0 +1,84 @@
+package templates
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/almanac"
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// almanacRow is a row of the almanac table, formatting one extreme of each summary
+type almanacRow struct {
+	label string
+	value func(almanac.Summary) string
+	time  func(almanac.Summary) time.Time
+}
+
+var almanacRows = []almanacRow{
+	{
+		label: "High",
+		value: func(s almanac.Summary) string { return fmt.Sprintf("%.1f°F", api.CelsiusToFahrenheit(s.High.Value)) },
+		time:  func(s almanac.Summary) time.Time { return s.High.Time },
+	},
+	{
+		label: "Low",
+		value: func(s almanac.Summary) string { return fmt.Sprintf("%.1f°F", api.CelsiusToFahrenheit(s.Low.Value)) },
+		time:  func(s almanac.Summary) time.Time { return s.Low.Time },
+	},
+	{
+		label: "Peak Gust",
+		value: func(s almanac.Summary) string {
+			return fmt.Sprintf("%.1f mph %s", api.MetersPerSecondToMilesPerHour(s.PeakGust.Speed), api.CompassDirection(s.PeakGust.Direction))
+		},
+		time: func(s almanac.Summary) time.Time { return s.PeakGust.Time },
+	},
+	{
+		label: "Max Rain Rate",
+		value: func(s almanac.Summary) string {
+			return fmt.Sprintf("%.2f in/hr", api.MillimetersToInches(s.MaxRainRate.Value))
+		},
+		time: func(s almanac.Summary) time.Time { return s.MaxRainRate.Time },
+	},
+	{
+		label: "Max UV",
+		value: func(s almanac.Summary) string { return fmt.Sprintf("%.1f", s.MaxUV.Value) },
+		time:  func(s almanac.Summary) time.Time { return s.MaxUV.Time },
+	},
+	{
+		label: "Min Pressure",
+		value: func(s almanac.Summary) string { return fmt.Sprintf("%.1f mb", s.MinPressure.Value) },
+		time:  func(s almanac.Summary) time.Time { return s.MinPressure.Time },
+	},
+	{
+		label: "Max Pressure",
+		value: func(s almanac.Summary) string { return fmt.Sprintf("%.1f mb", s.MaxPressure.Value) },
+		time:  func(s almanac.Summary) time.Time { return s.MaxPressure.Time },
+	},
+}
+
+// periodTitle is the column heading of a period
+func periodTitle(p almanac.Period) string {
+	switch p {
+	case almanac.Day:
+		return "Today"
+	case almanac.Month:
+		return "This Month"
+	case almanac.Year:
+		return "This Year"
+	default:
+		return "All Time"
+	}
+}
+
+// almanacWhen formats when an extreme was observed, the time of day for a day and the date it was set for a record
+func almanacWhen(p almanac.Period, t time.Time) string {
+	switch {
+	case t.IsZero():
+		return ""
+	case p == almanac.Day:
+		return t.Format("3:04 PM")
+	default:
+		return t.Format("Jan 2, 2006")
+	}
+}
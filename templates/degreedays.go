@@ -0,0 +1,36 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+)
+
+// degreeDayRow is a row of the degree days table, picking one kind of degree day of a day and a total
+type degreeDayRow struct {
+	label string
+	day   func(degreedays.Day) float64
+	total func(degreedays.Total) float64
+}
+
+var degreeDayRows = []degreeDayRow{
+	{label: "Heating", day: func(d degreedays.Day) float64 { return d.Heating }, total: func(t degreedays.Total) float64 { return t.Heating }},
+	{label: "Cooling", day: func(d degreedays.Day) float64 { return d.Cooling }, total: func(t degreedays.Total) float64 { return t.Cooling }},
+	{label: "Growing", day: func(d degreedays.Day) float64 { return d.Growing }, total: func(t degreedays.Total) float64 { return t.Growing }},
+}
+
+// seasonTitle is the column heading of the season to date
+func seasonTitle(t degreedays.Total) string {
+	return "Since " + t.Start.Format("Jan 2")
+}
+
+// degreeDayBases describes the base temperatures and growing degree day method of a config
+func degreeDayBases(c degreedays.Config) string {
+	unit := "°" + strings.ToUpper(string(c.Unit))
+	growing := fmt.Sprintf("%g%s", c.GrowingBase, unit)
+	if c.Method != degreedays.Average {
+		growing = fmt.Sprintf("%g-%g%s", c.GrowingBase, c.Upper, unit)
+	}
+	return fmt.Sprintf("Heating below %g%s, cooling above %g%s, growing above %s by the %s method", c.HeatingBase, unit, c.CoolingBase, unit, growing, c.Method)
+}
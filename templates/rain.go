@@ -0,0 +1,46 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kdwils/weatherstation/pkg/api"
+	"github.com/kdwils/weatherstation/pkg/rain"
+)
+
+// rainTitle is the label of a rain total. A water year is named for the year it ends in.
+func rainTitle(t rain.Totals) string {
+	switch t.Period {
+	case rain.Day:
+		return "Today"
+	case rain.Month:
+		return "This Month"
+	case rain.Year:
+		return "This Year"
+	default:
+		return fmt.Sprintf("Water Year %d", t.End.Add(-time.Second).Year())
+	}
+}
+
+// rainInches formats an amount of rain in millimeters as inches
+func rainInches(mm float64) string {
+	return fmt.Sprintf("%.2f in", api.MillimetersToInches(mm))
+}
+
+// rainChecked notes how many days of a total were corrected by Rain Check
+func rainChecked(t rain.Totals) string {
+	if t.Checked == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d days Rain Checked", t.Checked, t.Days)
+}
+
+// rainEvent describes a rain event: its total, when it started, and how long it lasted or that it is ongoing
+func rainEvent(e rain.Event) string {
+	started := e.Start.Format("Jan 2 3:04 PM")
+	if e.Ongoing {
+		return fmt.Sprintf("%s since %s, peaking at %.2f in/hr", rainInches(e.Total), started, api.MillimetersToInches(e.PeakIntensity))
+	}
+	return fmt.Sprintf("%s on %s over %s, peaking at %.2f in/hr", rainInches(e.Total), started, strings.TrimSuffix(e.Duration().Round(time.Minute).String(), "0s"), api.MillimetersToInches(e.PeakIntensity))
+}
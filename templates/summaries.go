@@ -0,0 +1,15 @@
+package templates
+
+import (
+	"github.com/kdwils/weatherstation/pkg/almanac"
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+	"github.com/kdwils/weatherstation/pkg/rain"
+)
+
+// Summaries are the summaries of a device's history the dashboard shows, each left empty when it is not kept
+type Summaries struct {
+	Almanac    []almanac.Summary
+	Rain       []rain.Totals
+	LastRain   *rain.Event
+	DegreeDays *degreedays.Summary
+}
@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/kdwils/weatherstation/pkg/almanac"
+	"github.com/kdwils/weatherstation/pkg/api"
+)
+
+// summarize updates the almanac and degree days of a device
+func (m *model) summarize(device int) {
+	summaries, err := m.almanac.Summaries(context.Background(), device, time.Now())
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.summaries = summaries
+
+	degreeDays, err := m.degreeDays.Summary(context.Background(), device, time.Now())
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.degreeDaySummary = degreeDays
+}
+
+// renderAlmanac renders today's highs and lows and the records of this month, this year and all time as a table
+func (m *model) renderAlmanac(width int, titleStyle, labelStyle, valueStyle, detailsStyle lipgloss.Style) string {
+	title := titleStyle.Render(centerText("Almanac (a)", width))
+	if len(m.summaries) == 0 || m.summaries[len(m.summaries)-1].Days == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, title, detailsStyle.Render("Waiting for observations..."))
+	}
+
+	rows := []struct {
+		label string
+		value func(almanac.Summary) (string, time.Time)
+	}{
+		{"High", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.1f°F", api.CelsiusToFahrenheit(s.High.Value)), s.High.Time
+		}},
+		{"Low", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.1f°F", api.CelsiusToFahrenheit(s.Low.Value)), s.Low.Time
+		}},
+		{"Peak Gust", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.1f mph %s", api.MetersPerSecondToMilesPerHour(s.PeakGust.Speed), api.CompassDirection(s.PeakGust.Direction)), s.PeakGust.Time
+		}},
+		{"Max Rain Rate", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.2f in/hr", api.MillimetersToInches(s.MaxRainRate.Value)), s.MaxRainRate.Time
+		}},
+		{"Max UV", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.1f", s.MaxUV.Value), s.MaxUV.Time
+		}},
+		{"Min Pressure", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.1f mb", s.MinPressure.Value), s.MinPressure.Time
+		}},
+		{"Max Pressure", func(s almanac.Summary) (string, time.Time) {
+			return fmt.Sprintf("%.1f mb", s.MaxPressure.Value), s.MaxPressure.Time
+		}},
+	}
+
+	cell := lipgloss.NewStyle().Width(width / (len(m.summaries) + 1))
+
+	labels := []string{cell.Render("")}
+	for _, row := range rows {
+		labels = append(labels, cell.Render(labelStyle.Render(row.label)))
+	}
+	columns := []string{lipgloss.JoinVertical(lipgloss.Left, labels...)}
+
+	for _, s := range m.summaries {
+		cells := []string{cell.Render(labelStyle.Render(periodTitle(s.Period)))}
+		for _, row := range rows {
+			if s.Days == 0 {
+				cells = append(cells, cell.Render(detailsStyle.Render("-")))
+				continue
+			}
+			value, t := row.value(s)
+			cells = append(cells, cell.Render(valueStyle.Render(value)+" "+detailsStyle.Render(when(s.Period, t))))
+		}
+		columns = append(columns, lipgloss.JoinVertical(lipgloss.Left, cells...))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, lipgloss.JoinHorizontal(lipgloss.Top, columns...))
+}
+
+// periodTitle is the column heading of a period
+func periodTitle(p almanac.Period) string {
+	switch p {
+	case almanac.Day:
+		return "Today"
+	case almanac.Month:
+		return "This Month"
+	case almanac.Year:
+		return "This Year"
+	default:
+		return "All Time"
+	}
+}
+
+// when formats when an extreme was observed, the time of day for a day and the date it was set for a record
+func when(p almanac.Period, t time.Time) string {
+	switch {
+	case t.IsZero():
+		return ""
+	case p == almanac.Day:
+		return t.Format("3:04 PM")
+	default:
+		return t.Format("Jan 2 2006")
+	}
+}
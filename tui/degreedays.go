@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+)
+
+// renderDegreeDays renders today's heating, cooling and growing degree days and those of the season to date as a table
+func (m *model) renderDegreeDays(width int, titleStyle, labelStyle, valueStyle, detailsStyle lipgloss.Style) string {
+	title := titleStyle.Render(centerText("Degree Days (d)", width))
+	s := m.degreeDaySummary
+	if s.Season.Days == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, title, detailsStyle.Render("Waiting for observations..."))
+	}
+
+	rows := []struct {
+		label       string
+		day, season float64
+	}{
+		{"Heating", s.Day.Heating, s.Season.Heating},
+		{"Cooling", s.Day.Cooling, s.Season.Cooling},
+		{"Growing", s.Day.Growing, s.Season.Growing},
+	}
+
+	cell := lipgloss.NewStyle().Width(width / 3)
+	labels := []string{cell.Render("")}
+	today := []string{cell.Render(labelStyle.Render("Today"))}
+	season := []string{cell.Render(labelStyle.Render("Since " + s.Season.Start.Format("Jan 2")))}
+	for _, row := range rows {
+		labels = append(labels, cell.Render(labelStyle.Render(row.label)))
+		if s.Day.Observations > 0 {
+			today = append(today, cell.Render(valueStyle.Render(fmt.Sprintf("%.1f", row.day))))
+		} else {
+			today = append(today, cell.Render(detailsStyle.Render("-")))
+		}
+		season = append(season, cell.Render(valueStyle.Render(fmt.Sprintf("%.1f", row.season))))
+	}
+
+	c := s.Config
+	unit := "°" + strings.ToUpper(string(c.Unit))
+	growing := fmt.Sprintf("%g%s", c.GrowingBase, unit)
+	if c.Method != degreedays.Average {
+		growing = fmt.Sprintf("%g-%g%s", c.GrowingBase, c.Upper, unit)
+	}
+	bases := fmt.Sprintf("Heating below %g%s, cooling above %g%s, growing above %s by the %s method",
+		c.HeatingBase, unit, c.CoolingBase, unit, growing, c.Method)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		lipgloss.JoinHorizontal(lipgloss.Top,
+			lipgloss.JoinVertical(lipgloss.Left, labels...),
+			lipgloss.JoinVertical(lipgloss.Left, today...),
+			lipgloss.JoinVertical(lipgloss.Left, season...),
+		),
+		"",
+		detailsStyle.Render(bases),
+	)
+}
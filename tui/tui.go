@@ -2,17 +2,22 @@ package tui
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/guptarohit/asciigraph"
 
+	"github.com/kdwils/weatherstation/pkg/almanac"
 	"github.com/kdwils/weatherstation/pkg/api"
 	"github.com/kdwils/weatherstation/pkg/connection"
+	"github.com/kdwils/weatherstation/pkg/degreedays"
+	"github.com/kdwils/weatherstation/pkg/presence"
+	"github.com/kdwils/weatherstation/pkg/rollup"
 	"github.com/kdwils/weatherstation/pkg/tempest"
 )
 
@@ -22,33 +27,75 @@ const (
 
 type model struct {
 	listener         tempest.Listener
+	presence         *presence.Tracker
+	device           int
 	observation      *api.ObservationTempest
+	lastStrike       *api.LightningStrikeEvent
+	rapidWind        *api.RapidWind
+	rapidWindOn      bool
 	spinner          spinner.Model
 	err              error
 	quitting         bool
 	updates          chan tea.Msg // Add channel for updates
 	width            int
 	height           int
+	historyEpochs    []int
 	tempHistory      []float64
 	windSpeedHistory []float64
 	pressureHistory  []float64
 	humidityHistory  []float64
 	dewPointHistory  []float64
 	feelsLikeHistory []float64
+	rollups          *rollup.Aggregator
+	almanac          *almanac.Almanac
+	summaries        []almanac.Summary
+	showAlmanac      bool
+	degreeDays       *degreedays.Calculator
+	degreeDaySummary degreedays.Summary
+	showDegreeDays   bool
 }
 
 // InitialModel creates and returns a new model instance configured for the specified Tempest device connection.
-func InitialModel(conn connection.Connection, device int) *model {
+func InitialModel(conn connection.Connection, device int, opts ...tempest.Option) *model {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
-	return &model{
-		listener:    tempest.NewEventListener(conn, tempest.ListenGroupStart, device),
+	listener := tempest.NewEventListener(conn, tempest.ListenGroupStart, device, opts...)
+
+	m := &model{
+		listener:    listener,
+		presence:    presence.New(listener),
+		device:      device,
 		spinner:     s,
 		updates:     make(chan tea.Msg),
 		tempHistory: make([]float64, 0, 30), // Keep last 30 readings
 	}
+	m.SetRollups(rollup.New())
+	return m
+}
+
+// SetRollups sets the aggregator the almanac and degree days views are built from, by default one in memory in the local time
+// zone. Call it before StartListener.
+func (m *model) SetRollups(rollups *rollup.Aggregator) {
+	config := degreedays.DefaultConfig(degreedays.Fahrenheit)
+	if m.degreeDays != nil {
+		config = m.degreeDays.Config()
+	}
+
+	m.rollups = rollups
+	m.almanac = almanac.New(rollups)
+	m.degreeDays = degreedays.New(rollups, config)
+}
+
+// SetDegreeDays sets how the degree days view computes degree days. Call it before StartListener.
+func (m *model) SetDegreeDays(c degreedays.Config) {
+	m.degreeDays = degreedays.New(m.rollups, c)
+}
+
+// Listener returns the listener the model is updated from, so more handlers can be registered before it starts
+func (m *model) Listener() tempest.Listener {
+	return m.listener
 }
 
 func (m model) Init() tea.Cmd {
@@ -70,6 +117,17 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.quitting = true
 			return m, tea.Quit
 		}
+		if msg.String() == "w" && m.device != 0 {
+			return m, m.toggleRapidWind(!m.rapidWindOn)
+		}
+		if msg.String() == "a" {
+			m.showAlmanac, m.showDegreeDays = !m.showAlmanac, false
+			return m, nil
+		}
+		if msg.String() == "d" {
+			m.showDegreeDays, m.showAlmanac = !m.showDegreeDays, false
+			return m, nil
+		}
 
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -82,19 +140,35 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case observationMsg:
-		m.observation = msg.observation
-		m.tempHistory = appendAndTrim(m.tempHistory, m.observation.TemperatureInFarneheit(), maxHistory)
-		m.feelsLikeHistory = appendAndTrim(m.feelsLikeHistory, m.observation.FeelsLikeFarenheit(), maxHistory)
-		m.windSpeedHistory = appendAndTrim(m.windSpeedHistory, m.observation.WindSpeedAverageMPH(), maxHistory)
-		m.pressureHistory = appendAndTrim(m.pressureHistory, m.observation.Data.StationPressure, maxHistory)
-		m.humidityHistory = appendAndTrim(m.humidityHistory, float64(m.observation.Data.RelativeHumidity), maxHistory)
-		m.dewPointHistory = appendAndTrim(m.dewPointHistory, m.observation.DewPointFarenheit(), maxHistory)
+		// backfilled observations fill in the history without replacing the current conditions
+		if msg.observation.Source != api.SourceBackfill {
+			m.observation = msg.observation
+		}
+		m.record(msg.observation)
+		m.summarize(msg.observation.Device)
 		return m, m.waitForUpdate
 
 	case lightningStrikeMsg:
-		m.updates <- lightningStrikeMsg{strike: msg.strike}
+		m.lastStrike = msg.strike
+		return m, m.waitForUpdate
+
+	case rapidWindMsg:
+		if m.rapidWindOn {
+			m.rapidWind = msg.wind
+		}
 		return m, m.waitForUpdate
 
+	case rapidWindToggledMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.rapidWindOn = msg.on
+		if !msg.on {
+			m.rapidWind = nil
+		}
+		return m, nil
+
 	case errMsg:
 		m.err = msg.err
 		return m, m.waitForUpdate
@@ -103,9 +177,25 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// appendAndTrim appends a value to a slice and trims it to the specified maximum length by removing the oldest element if necessary.
-func appendAndTrim[T any](slice []T, value T, max int) []T {
-	slice = append(slice, value)
+// record adds an observation to the history in the order of its epoch, so backfilled observations land where they were missed
+func (m *model) record(obs *api.ObservationTempest) {
+	i, _ := slices.BinarySearch(m.historyEpochs, obs.Data.TimeEpoch)
+	if len(m.historyEpochs) >= maxHistory && i == 0 {
+		return
+	}
+
+	m.historyEpochs = insertAndTrim(m.historyEpochs, i, obs.Data.TimeEpoch, maxHistory)
+	m.tempHistory = insertAndTrim(m.tempHistory, i, obs.TemperatureInFarneheit(), maxHistory)
+	m.feelsLikeHistory = insertAndTrim(m.feelsLikeHistory, i, obs.FeelsLikeFarenheit(), maxHistory)
+	m.windSpeedHistory = insertAndTrim(m.windSpeedHistory, i, obs.WindSpeedAverageMPH(), maxHistory)
+	m.pressureHistory = insertAndTrim(m.pressureHistory, i, obs.Data.StationPressure, maxHistory)
+	m.humidityHistory = insertAndTrim(m.humidityHistory, i, float64(obs.Data.RelativeHumidity), maxHistory)
+	m.dewPointHistory = insertAndTrim(m.dewPointHistory, i, obs.DewPointFarenheit(), maxHistory)
+}
+
+// insertAndTrim inserts a value into a slice at index i and trims it to the specified maximum length by removing the oldest element if necessary.
+func insertAndTrim[T any](slice []T, i int, value T, max int) []T {
+	slice = slices.Insert(slice, i, value)
 	if len(slice) > max {
 		slice = slice[1:]
 	}
@@ -114,10 +204,15 @@ func appendAndTrim[T any](slice []T, value T, max int) []T {
 
 func (m *model) View() string {
 	if m.observation == nil {
+		view := m.spinner.View()
+		if m.err != nil {
+			view = fmt.Sprintf("Error: %v", m.err)
+		}
+
 		return lipgloss.Place(m.width, m.height,
 			lipgloss.Center,
 			lipgloss.Center,
-			m.spinner.View())
+			view)
 	}
 	mainContainerStyle := lipgloss.NewStyle()
 
@@ -167,11 +262,20 @@ func (m *model) View() string {
 		),
 	)
 
+	liveWind := "Live: off (w)"
+	if m.rapidWindOn {
+		liveWind = "Live: waiting (w)"
+	}
+	if m.rapidWind != nil {
+		liveWind = fmt.Sprintf("Live: %s at %.1f mph (w)", m.rapidWind.WindDirection(), m.rapidWind.WindSpeedMPH())
+	}
+
 	windSection := sectionStyle.Render(
 		lipgloss.JoinVertical(lipgloss.Left,
 			labelStyle.Render("Wind"),
 			valueStyle.Render(fmt.Sprintf("%s at %.1f mph", m.observation.WindDirection(), m.observation.WindSpeedAverageMPH())),
 			detailsStyle.Render(fmt.Sprintf("Gust: %.1f mph", m.observation.WindSpeedGustMPH())),
+			detailsStyle.Render(liveWind),
 		),
 	)
 
@@ -213,11 +317,16 @@ func (m *model) View() string {
 		),
 	)
 
+	lastStrikeDistance := m.observation.AverageLightningStrikeDistanceInMiles()
+	if m.lastStrike != nil {
+		lastStrikeDistance = m.lastStrike.DistanceInMiles()
+	}
+
 	lightningSection := sectionStyle.Render(
 		lipgloss.JoinVertical(lipgloss.Top,
 			labelStyle.Render("Lightning Strikes"),
 			valueStyle.Render(fmt.Sprintf("%d strikes/hr", m.observation.Summary.StrikeCountOneHour)),
-			detailsStyle.Render(fmt.Sprintf("Last Strike: %.1f miles", m.observation.AverageLightningStrikeDistanceInMiles())),
+			detailsStyle.Render(fmt.Sprintf("Last Strike: %.1f miles", lastStrikeDistance)),
 			detailsStyle.Render(fmt.Sprintf("3hr Total: %d strikes", m.observation.Summary.StrikeCountThreeHour)),
 		),
 	)
@@ -304,6 +413,17 @@ func (m *model) View() string {
 		pressureGraph,
 		windGraph,
 	)
+	bottomStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#fafafa")).
+		Width(quadrantWidth*2 + 2).
+		Height(quadrantHeight)
+	if m.showAlmanac {
+		bottomRow = bottomStyle.Render(contentStyle.Render(m.renderAlmanac(quadrantWidth*2-4, titleStyle, labelStyle, valueStyle, detailsStyle)))
+	}
+	if m.showDegreeDays {
+		bottomRow = bottomStyle.Render(contentStyle.Render(m.renderDegreeDays(quadrantWidth*2-4, titleStyle, labelStyle, valueStyle, detailsStyle)))
+	}
 
 	allQuadrants := lipgloss.JoinVertical(lipgloss.Center,
 		topRow,
@@ -314,6 +434,7 @@ func (m *model) View() string {
 
 	fullView := lipgloss.JoinVertical(lipgloss.Center,
 		mainContainer,
+		detailsStyle.Render(m.statusLine(time.Now())),
 	)
 
 	return lipgloss.Place(m.width, m.height,
@@ -327,7 +448,16 @@ type observationMsg struct {
 }
 
 type lightningStrikeMsg struct {
-	strike *api.LightningStrike
+	strike *api.LightningStrikeEvent
+}
+
+type rapidWindMsg struct {
+	wind *api.RapidWind
+}
+
+type rapidWindToggledMsg struct {
+	on  bool
+	err error
 }
 
 type errMsg struct {
@@ -335,29 +465,45 @@ type errMsg struct {
 }
 
 func (m model) StartListener() {
-	m.listener.RegisterHandler(tempest.EventObservationTempest, m.handleObservation)
-	m.listener.RegisterHandler(tempest.EventLightingStrike, m.handleLightningStrike)
-	m.listener.Listen(context.Background())
-	return
-}
+	tempest.On(m.listener, m.handleObservation)
+	tempest.On(m.listener, m.handleLightningStrike)
+	tempest.On(m.listener, m.handleRapidWind)
 
-func (m *model) handleObservation(ctx context.Context, b []byte) {
-	var obs api.ObservationTempest
-	if err := json.Unmarshal(b, &obs); err != nil {
-		return
+	go m.presence.Run(context.Background())
+
+	if err := m.listener.Listen(context.Background()); err != nil {
+		m.updates <- errMsg{err: err}
 	}
+}
 
+func (m *model) handleObservation(ctx context.Context, obs api.ObservationTempest) {
+	// aggregated before the model is updated, so the almanac and degree days include the observation
+	if err := m.rollups.Add(ctx, obs); err != nil {
+		m.updates <- errMsg{err: err}
+	}
 	m.updates <- observationMsg{observation: &obs}
 }
 
-func (m *model) handleLightningStrike(ctx context.Context, b []byte) {
-	var obs api.LightningStrike
-	err := json.Unmarshal(b, &obs)
-	if err != nil {
-		return
-	}
+func (m *model) handleLightningStrike(ctx context.Context, evt api.LightningStrikeEvent) {
+	m.updates <- lightningStrikeMsg{strike: &evt}
+}
+
+func (m *model) handleRapidWind(ctx context.Context, evt api.RapidWind) {
+	m.updates <- rapidWindMsg{wind: &evt}
+}
 
-	m.updates <- errMsg{err: err}
+// toggleRapidWind subscribes to or unsubscribes from the rapid wind events of the device on the running listener
+func (m *model) toggleRapidWind(on bool) tea.Cmd {
+	listener, device := m.listener, m.device
+	return func() tea.Msg {
+		var err error
+		if on {
+			err = listener.Subscribe(context.Background(), tempest.ListenGroupRapidStart, device)
+		} else {
+			err = listener.Unsubscribe(context.Background(), tempest.ListenGroupRapidStart, device)
+		}
+		return rapidWindToggledMsg{on: on, err: err}
+	}
 }
 
 func (m *model) renderWindGraph(width, height int) string {
@@ -406,6 +552,31 @@ func (m *model) renderTemperatureGraph(width, height int) string {
 	return temp
 }
 
+// statusLine summarizes the state of the connection and how recently data arrived
+func (m *model) statusLine(now time.Time) string {
+	if m.err != nil {
+		return fmt.Sprintf("Error: %v", m.err)
+	}
+
+	stats, ok := m.listener.Stats()
+	if !ok {
+		return ""
+	}
+
+	last := "never"
+	if !stats.LastMessage.IsZero() {
+		last = fmt.Sprintf("%s ago", now.Sub(stats.LastMessage).Truncate(time.Second))
+	}
+
+	line := fmt.Sprintf("Connection: %s | Messages: %d | Decode Failures: %d | Duplicates: %d | Reconnects: %d | Last Message: %s",
+		stats.State, stats.MessagesRead, stats.DecodeFailures, stats.Duplicates, stats.Reconnects, last)
+
+	for _, d := range m.presence.Devices() {
+		line += fmt.Sprintf(" | Device %d: %s", d.Device, d.State)
+	}
+	return line
+}
+
 // centerText returns the input text centered within the specified width by adding left padding.
 // If the text is longer than the width, it is returned unchanged.
 func centerText(text string, width int) string {